@@ -2,14 +2,19 @@ package application
 
 import (
 	// native packages
+	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 	// local packages
+	"auth"
+	"config"
+	"logging"
+	"migrations"
 	"recipes"
+	"tracing"
 	// GitHub packages
 	"github.com/gorilla/mux"
 	// Standard SQL Override
@@ -20,6 +25,48 @@ import (
 type App struct {
 	Router *mux.Router
 	DB     *sql.DB
+	Store  recipes.RecipeStore
+
+	// AutoMigrate applies any pending schema migrations during Initialize
+	AutoMigrate bool
+	// MaxPageSize is the most items a client may ask for in one page,
+	// config.Default().MaxPageSize if zero
+	MaxPageSize int
+	// Server holds the HTTP server timeouts; config.Default().Server is
+	// used for any which are zero
+	Server config.ServerConfig
+	// StoreTimeout limits each store operation, unless StoreTimeouts has
+	// an entry for it (such as "GetRecipesRated"); zero means no limit.
+	// A request whose operation runs out of time gets a 504.
+	StoreTimeout  time.Duration
+	StoreTimeouts map[string]time.Duration
+	// PingTimeout limits the database check of /readyz,
+	// config.Default().Database.PingTimeout if zero
+	PingTimeout time.Duration
+	// Tokens signs the tokens given to users when they log in; a random
+	// key is used if nil
+	Tokens *auth.Signer
+	// TokenTTL is how long a token is valid for,
+	// config.Default().Auth.TokenTTL if zero
+	TokenTTL time.Duration
+	// TrustedKeys verify tokens from other issuers, as well as Tokens
+	TrustedKeys []*auth.Signer
+	// APIKeys are the static keys given to other services
+	APIKeys []auth.APIKey
+	// RequireReadScope makes reads need the recipes:read scope; otherwise
+	// they may be anonymous
+	RequireReadScope bool
+	// Log receives the access log, and the errors of each request along
+	// with its ID; logging.Default() is used if nil
+	Log *logging.Logger
+	// Tracer traces requests and the queries made for them; they are not
+	// traced if nil
+	Tracer *tracing.Tracer
+
+	metrics       *appMetrics
+	authenticator *auth.Authenticator
+	// scopes holds the scope each route requires, if any
+	scopes map[*mux.Route]string
 }
 
 func (a *App) getRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
@@ -30,46 +77,39 @@ func (a *App) getRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	r := recipes.Recipe{ID: id}
-	if err := r.GetRecipe(a.DB); err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			respondWithError(w, http.StatusNotFound, "Recipe not found")
-		default:
-			respondWithError(w, http.StatusInternalServerError, err.Error())
-		}
+	if err := a.Store.GetRecipe(req.Context(), &r); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
 		return
 	}
 	respondWithJSON(w, http.StatusOK, r)
 }
 
 func (a *App) getRecipesEndpoint(w http.ResponseWriter, req *http.Request) {
-	count, _ := strconv.Atoi(req.FormValue("count"))
-	start, _ := strconv.Atoi(req.FormValue("start"))
-
-	if count > 10 || count < 1 {
-		count = 10
-	}
-	if start < 0 {
-		start = 0
+	p, e := a.page(req.FormValue("cursor"), req.FormValue("count"))
+	if e != nil {
+		respondWithProblem(w, e)
+		return
 	}
-	recipes, err := recipes.GetRecipes(a.DB, start, count)
+	recipes, info, err := a.Store.GetRecipes(req.Context(), p)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithStoreError(w, err, "Recipe not found")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, recipes)
+	respondWithPage(w, req, req.URL.Query(), recipes, p, info)
 }
 
 func (a *App) createRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
+	if !a.checkAllowed(w, req, canCreateRecipe, "Viewers may not create recipes") {
+		return
+	}
 	var r recipes.Recipe
-	decoder := json.NewDecoder(req.Body)
-	if err := decoder.Decode(&r); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if e := decodePayload(req, &r); e != nil {
+		respondWithProblem(w, e)
 		return
 	}
-	defer req.Body.Close()
-	if err := r.CreateRecipe(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	r.OwnerID = auth.FromContext(req.Context()).UserID
+	if err := a.Store.CreateRecipe(req.Context(), &r); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
 		return
 	}
 	respondWithJSON(w, http.StatusCreated, r)
@@ -82,16 +122,17 @@ func (a *App) modifyRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
+	if !a.checkRecipeChange(w, req, id) {
+		return
+	}
 	var r recipes.Recipe
-	decoder := json.NewDecoder(req.Body)
-	if err := decoder.Decode(&r); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if e := decodePayload(req, &r); e != nil {
+		respondWithProblem(w, e)
 		return
 	}
-	defer req.Body.Close()
 	r.ID = id
-	if _, err := r.UpdateRecipe(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if err := a.Store.UpdateRecipe(req.Context(), &r); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
 		return
 	}
 	respondWithJSON(w, http.StatusOK, r)
@@ -104,101 +145,182 @@ func (a *App) deleteRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
+	if !a.checkRecipeChange(w, req, id) {
+		return
+	}
 	r := recipes.Recipe{ID: id}
-	if _, err := r.DeleteRecipe(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	if err := a.Store.DeleteRecipe(req.Context(), &r); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
 		return
 	}
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
-func (a *App) addRatingEndpoint(w http.ResponseWriter, req *http.Request) {
-	params := mux.Vars(req)
-	recipeID, err := strconv.Atoi(params["recipe_id"])
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
-		return
-	}
-	rr := recipes.RecipeRating{RecipeID: recipeID}
-	decoder := json.NewDecoder(req.Body)
-	if err := decoder.Decode(&rr); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
-		return
-	}
-	defer req.Body.Close()
-	if err := rr.AddRecipeRating(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	if sw, ok := w.(*statusWriter); ok && sw.span != nil {
+		_, span := tracing.Start(tracing.ContextWithSpan(context.Background(), sw.span), "encode JSON", tracing.Internal)
+		defer span.End()
 	}
-	respondWithJSON(w, http.StatusCreated, rr)
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(response)
 }
 
-func (a *App) searchRecipesEndpoint(w http.ResponseWriter, req *http.Request) {
-	count, _ := strconv.Atoi(req.FormValue("count"))
-	start, _ := strconv.Atoi(req.FormValue("start"))
+// Initialize sets up the database connection, router, and routes for the app
+func (a *App) Initialize(cfg config.Config) {
 
-	var preptime32 float32
-	if req.FormValue("preptime") == "" {
-		preptime32 = 9999.99 // random large value
-	} else {
-		preptime64, _ := strconv.ParseFloat(req.FormValue("preptime"), 32)
-		preptime32 = float32(preptime64)
+	var err error
+
+	a.DB, err = OpenDB(cfg.Database)
+	if err != nil {
+		a.fatal("cannot connect to the database", err)
 	}
 
-	if count > 10 || count < 1 {
-		count = 10
+	if err := a.loadKeys(cfg.Auth); err != nil {
+		a.fatal("cannot load the signing keys", err)
 	}
-	if start < 0 {
-		start = 0
+	if a.Tracer, err = newTracer(cfg.Tracing); err != nil {
+		a.fatal("cannot set up tracing", err)
 	}
 
-	recipesRated, err := recipes.GetRecipesRated(a.DB, start, count, preptime32)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+	if a.AutoMigrate {
+		applied, err := migrations.Up(a.DB)
+		if err != nil {
+			a.fatal("cannot migrate the database", err)
+		}
+		for _, m := range applied {
+			a.logger().Info("applied migration", "version", m.Version, "name", m.Name)
+		}
 	}
-	respondWithJSON(w, http.StatusOK, recipesRated)
-}
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+	a.InitializeWithStore(recipes.NewSQLStore(a.DB))
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, _ := json.Marshal(payload)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	w.Write(response)
-}
-
-// Initialize sets up the database connection, router, and routes for the app
-func (a *App) Initialize(user, dbname string) {
-
-	connectionString := fmt.Sprintf("postgres://%s@cockroach-backend:26257/%s?sslmode=disable", user, dbname)
+// InitializeWithStore sets up the router and routes for the app using the specified store
+func (a *App) InitializeWithStore(store recipes.RecipeStore) {
 
-	var err error
+	a.Store = store
+	if a.MaxPageSize == 0 {
+		a.MaxPageSize = config.Default().MaxPageSize
+	}
+	if a.Tokens == nil {
+		a.logger().Warn("no HMAC key is configured, tokens will not survive a restart")
+		a.Tokens = auth.NewHMACSigner(auth.RandomKey())
+	}
+	a.Server = withServerDefaults(a.Server)
+	if a.PingTimeout == 0 {
+		a.PingTimeout = config.Default().Database.PingTimeout.Duration
+	}
+	if a.TokenTTL == 0 {
+		a.TokenTTL = config.Default().Auth.TokenTTL.Duration
+	}
 
-	a.DB, err = sql.Open("postgres", connectionString)
-	if err != nil {
-		log.Fatal(err)
+	a.authenticator = &auth.Authenticator{
+		Signers: append([]*auth.Signer{a.Tokens}, a.TrustedKeys...),
+		APIKeys: a.APIKeys,
 	}
+	a.scopes = map[*mux.Route]string{}
+
+	a.initMetrics()
+	a.Store = recipes.ObservedStore{Store: store, Observe: a.observeQuery,
+		Timeout: a.StoreTimeout, Timeouts: a.StoreTimeouts}
 
 	a.Router = mux.NewRouter()
+	// the middlewares are only run for requests which match a route
+	a.Router.NotFoundHandler = requestIDMiddleware(a.tracingMiddleware(a.accessLogMiddleware(a.metricsMiddleware(http.HandlerFunc(notFoundHandler)))))
+	a.Router.Use(requestIDMiddleware)
+	a.Router.Use(a.tracingMiddleware)
+	a.Router.Use(a.accessLogMiddleware)
+	a.Router.Use(a.metricsMiddleware)
+
+	a.Router.HandleFunc("/healthz", a.healthzEndpoint).Methods("GET")
+	a.Router.HandleFunc("/readyz", a.readyzEndpoint).Methods("GET")
+	a.Router.Handle("/metrics", a.metrics.registry).Methods("GET")
 
 	v1 := a.Router.PathPrefix("/v1").Subrouter()
 
-	v1.HandleFunc("/recipes", a.getRecipesEndpoint).Methods("GET")
-	v1.HandleFunc("/recipes", a.createRecipeEndpoint).Methods("POST")
-	v1.HandleFunc("/recipes/{id:[0-9]+}", a.getRecipeEndpoint).Methods("GET")
-	v1.HandleFunc("/recipes/{id:[0-9]+}", a.modifyRecipeEndpoint).Methods("PUT")
-	v1.HandleFunc("/recipes/{id:[0-9]+}", a.modifyRecipeEndpoint).Methods("PATCH")
-	v1.HandleFunc("/recipes/{id:[0-9]+}", a.deleteRecipeEndpoint).Methods("DELETE")
-	v1.HandleFunc("/recipes/{recipe_id:[0-9]+}/rating", a.addRatingEndpoint).Methods("POST")
-	v1.HandleFunc("/recipes/search", a.searchRecipesEndpoint).Methods("POST")
+	v1.Use(a.authMiddleware)
+
+	a.handle(v1, "/openapi.json", "", a.openAPIEndpoint, "GET")
+	a.handle(v1, "/docs", "", a.docsEndpoint, "GET")
+
+	a.handle(v1, "/users", "", a.registerUserEndpoint, "POST")
+	a.handle(v1, "/users/login", "", a.loginEndpoint, "POST")
+	a.handle(v1, "/users/me", "", a.getCurrentUserEndpoint, "GET")
+	a.handle(v1, "/users/{user_id:[0-9]+}/role", auth.ScopeUsersAdmin, a.setUserRoleEndpoint, "PUT")
+	a.handle(v1, "/recipes", auth.ScopeRecipesRead, a.getRecipesEndpoint, "GET")
+	a.handle(v1, "/recipes", auth.ScopeRecipesWrite, a.createRecipeEndpoint, "POST")
+	a.handle(v1, "/recipes/search", auth.ScopeRecipesRead, a.searchRecipesEndpoint, "GET", "POST")
+	a.handle(v1, "/recipes/{id:[0-9]+}", auth.ScopeRecipesRead, a.getRecipeEndpoint, "GET")
+	a.handle(v1, "/recipes/{id:[0-9]+}", auth.ScopeRecipesWrite, a.modifyRecipeEndpoint, "PUT")
+	a.handle(v1, "/recipes/{id:[0-9]+}", auth.ScopeRecipesWrite, a.patchRecipeEndpoint, "PATCH")
+	a.handle(v1, "/recipes/{id:[0-9]+}", auth.ScopeRecipesWrite, a.deleteRecipeEndpoint, "DELETE")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/rating", auth.ScopeRecipesRead, a.getRatingStatsEndpoint, "GET")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/rating", auth.ScopeRatingsWrite, a.addRatingEndpoint, "POST")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/rating", auth.ScopeRatingsWrite, a.withdrawRatingEndpoint, "DELETE")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/rating/{rating_id:[0-9]+}", auth.ScopeRatingsModerate, a.deleteRatingEndpoint, "DELETE")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/ingredients", auth.ScopeRecipesRead, a.getIngredientsEndpoint, "GET")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/ingredients", auth.ScopeRecipesWrite, a.addIngredientEndpoint, "POST")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/ingredients/{ingredient_id:[0-9]+}", auth.ScopeRecipesRead, a.getIngredientEndpoint, "GET")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/ingredients/{ingredient_id:[0-9]+}", auth.ScopeRecipesWrite, a.modifyIngredientEndpoint, "PUT")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/ingredients/{ingredient_id:[0-9]+}", auth.ScopeRecipesWrite, a.deleteIngredientEndpoint, "DELETE")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/steps", auth.ScopeRecipesRead, a.getStepsEndpoint, "GET")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/steps", auth.ScopeRecipesWrite, a.addStepEndpoint, "POST")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/steps/order", auth.ScopeRecipesWrite, a.reorderStepsEndpoint, "PUT")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/steps/{step_id:[0-9]+}", auth.ScopeRecipesWrite, a.modifyStepEndpoint, "PUT")
+	a.handle(v1, "/recipes/{recipe_id:[0-9]+}/steps/{step_id:[0-9]+}", auth.ScopeRecipesWrite, a.deleteStepEndpoint, "DELETE")
+}
+
+// handle registers a route, which callers must have been granted the
+// scope to use; an empty scope means the route is open to anyone.
+func (a *App) handle(r *mux.Router, path string, scope string, f http.HandlerFunc, methods ...string) {
+	a.scopes[r.HandleFunc(path, f).Methods(methods...)] = scope
+}
+
+// OpenDB connects to the database, waiting up to its StartupTimeout for it
+// to respond, without applying any migrations.
+func OpenDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := WaitForDB(db, cfg.StartupTimeout.Duration); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openDB connects to the first of the configured hosts that responds,
+// or to the first host if none of them do.
+func openDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	dsns := cfg.DataSourceNames()
+	for i, dsn := range dsns {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		if len(dsns) > 1 {
+			if err := db.Ping(); err != nil {
+				logging.Default().Warn("CockroachDB host is not responding", "host", cfg.Hosts[i], "error", err)
+				db.Close()
+				continue
+			}
+		}
+		setPoolLimits(db, cfg)
+		return db, nil
+	}
+	db, err := sql.Open("postgres", dsns[0])
+	if err != nil {
+		return nil, err
+	}
+	setPoolLimits(db, cfg)
+	return db, nil
 }
 
-// Run starts the app and serves on the specified port
-func (a *App) Run(port string) {
-	log.Print("Now serving recipes ...")
-	log.Fatal(http.ListenAndServe(":"+port, a.Router))
+func setPoolLimits(db *sql.DB, cfg config.DatabaseConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
 }
@@ -0,0 +1,108 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strings"
+	// local packages
+	"auth"
+	"config"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+// loadKeys reads the configured signing keys and API keys. The RSA private
+// key signs tokens if there is one; the HMAC key still verifies the tokens
+// it signed before.
+func (a *App) loadKeys(cfg config.AuthConfig) error {
+	if cfg.HMACKeyFile != "" {
+		key, err := auth.LoadHMACKey(cfg.HMACKeyFile)
+		if err != nil {
+			return err
+		}
+		a.Tokens = auth.NewHMACSigner(key)
+	}
+	if cfg.RSAPrivateKeyFile != "" {
+		key, err := auth.LoadRSAPrivateKey(cfg.RSAPrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		if a.Tokens != nil {
+			a.TrustedKeys = append(a.TrustedKeys, a.Tokens)
+		}
+		a.Tokens = auth.NewRSASigner(key)
+	}
+	if cfg.RSAPublicKeyFile != "" {
+		key, err := auth.LoadRSAPublicKey(cfg.RSAPublicKeyFile)
+		if err != nil {
+			return err
+		}
+		a.TrustedKeys = append(a.TrustedKeys, auth.NewRSAVerifier(key))
+	}
+	if cfg.APIKeysFile != "" {
+		keys, err := auth.LoadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return err
+		}
+		a.APIKeys = keys
+	}
+	return nil
+}
+
+// authMiddleware identifies the caller of each request and checks they
+// have been granted the scope of the route. Reads may be anonymous unless
+// RequireReadScope is set.
+func (a *App) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, err := a.authenticator.Authenticate(req)
+		switch err {
+		case nil:
+		case auth.ErrInvalidAPIKey:
+			respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+			return
+		default:
+			respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		scope := a.scopes[mux.CurrentRoute(req)]
+		// what anyone may read, a caller whose credentials lack the scope may read too
+		if scope == auth.ScopeRecipesRead && !a.RequireReadScope {
+			scope = ""
+		}
+		if scope != "" {
+			if p == nil {
+				respondWithError(w, http.StatusUnauthorized, "Authentication is required")
+				return
+			}
+			if !p.HasScope(scope) {
+				respondWithError(w, http.StatusForbidden, "The "+scope+" scope is required")
+				return
+			}
+		}
+		if p != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), p))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// currentUser returns the user making a request, or an error if the caller
+// is anonymous or is not a user.
+func currentUser(req *http.Request) (*auth.Principal, *Error) {
+	p := auth.FromContext(req.Context())
+	if p == nil {
+		return nil, &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized,
+			Detail: "Authentication is required"}
+	}
+	if p.UserID == 0 {
+		return nil, &Error{Status: http.StatusForbidden, Code: CodeForbidden,
+			Detail: "Only users may do this, not API keys"}
+	}
+	return p, nil
+}
+
+// scopeClaim returns the scopes as the space-separated scope claim of a token.
+func scopeClaim(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
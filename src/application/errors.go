@@ -0,0 +1,227 @@
+package application
+
+import (
+	// native packages
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	// local packages
+	"logging"
+	"recipes"
+)
+
+// Stable, machine-readable error codes. Clients should rely on these
+// rather than on the status code or the human-readable detail.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeValidationFailed     = "validation_failed"
+	CodeRetryable            = "retryable"
+	CodeUnavailable          = "unavailable"
+	CodeTimeout              = "timeout"
+	CodeInternal             = "internal_error"
+)
+
+// Error is an API error, which is sent to the client as an RFC 7807
+// application/problem+json document. The Cause is only ever logged.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	Cause  error
+}
+
+// FieldError describes a problem with a single field of a request payload.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Code + ": " + e.Detail + ": " + e.Cause.Error()
+	}
+	return e.Code + ": " + e.Detail
+}
+
+// problem is the RFC 7807 representation of an Error.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// codeForStatus returns the default error code for an HTTP status.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+// storeError maps an error from the recipes store to an API error, using
+// the specified detail if the recipe (or ingredient or step) was not found.
+func storeError(err error, notFound string) *Error {
+	switch {
+	case err == recipes.ErrNotFound:
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: notFound}
+	case err == recipes.ErrInvalidCursor:
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid cursor"}
+	case err == context.DeadlineExceeded:
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout,
+			Detail: "The database did not respond in time", Cause: err}
+	case err == context.Canceled:
+		// the client went away, or the server is shutting down
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable,
+			Detail: "The request was cancelled", Cause: err}
+	case recipes.IsRetryable(err):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeRetryable,
+			Detail: "The request conflicted with another, please retry", Cause: err}
+	case recipes.IsUnavailable(err):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable,
+			Detail: "The database is unavailable", Cause: err}
+	case recipes.IsConflict(err):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict,
+			Detail: "The request conflicts with existing data", Cause: err}
+	case recipes.IsConstraintViolation(err):
+		return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed,
+			Detail: "The request payload is invalid", Cause: err}
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: CodeInternal,
+			Detail: "Internal server error", Cause: err}
+	}
+}
+
+// respondWithStoreError responds with the API error for an error from the store.
+func respondWithStoreError(w http.ResponseWriter, err error, notFound string) {
+	respondWithProblem(w, storeError(err, notFound))
+}
+
+// newError returns an API error with the default code for the status.
+func newError(code int, message string) *Error {
+	return &Error{Status: code, Code: codeForStatus(code), Detail: message}
+}
+
+// respondWithError responds with an API error with the default code for the status.
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithProblem(w, newError(code, message))
+}
+
+// respondWithProblem sends an API error as application/problem+json. Its
+// code and cause (if any) are written to the access log of the request.
+func respondWithProblem(w http.ResponseWriter, e *Error) {
+	requestID := w.Header().Get(requestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+		w.Header().Set(requestIDHeader, requestID)
+	}
+	if sw, ok := w.(*statusWriter); ok {
+		sw.problem = e
+	} else if e.Cause != nil {
+		logging.Default().Error("request failed", "request_id", requestID, "status", e.Status, "code", e.Code, "error", e.Cause)
+	}
+	if e.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	if e.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="recipes"`)
+	}
+
+	response, _ := json.Marshal(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	})
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(e.Status)
+	w.Write(response)
+}
+
+const requestIDHeader = "X-Request-ID"
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// requestIDMiddleware gives every request an ID, which is returned in the
+// X-Request-ID header and included in any error response and log entry.
+// An ID sent by the client (or a proxy) in X-Request-ID is kept, so that
+// a request can be followed across services.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requestIDKey, id)))
+	})
+}
+
+// RequestID returns the ID of the request being served, if there is one.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// validRequestID reports whether a request ID sent by a client is safe
+// to log and echo: up to 128 letters, digits, '-', '_', '.' or ':'.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// notFoundHandler responds to requests which do not match any route.
+func notFoundHandler(w http.ResponseWriter, req *http.Request) {
+	respondWithError(w, http.StatusNotFound, "No such resource")
+}
@@ -0,0 +1,82 @@
+package application
+
+import (
+	// native packages
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+	// local packages
+	"logging"
+	"migrations"
+)
+
+// readiness is the body of a /readyz response: the overall status, and
+// the result of each check.
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthzEndpoint tells the orchestrator the process is alive. It does not
+// touch the database, so that an outage there does not get us restarted.
+func (a *App) healthzEndpoint(w http.ResponseWriter, req *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyzEndpoint tells the orchestrator whether to send us traffic: the
+// database must answer within PingTimeout, and have every migration applied,
+// which is checked within the same time without writing anything.
+// An app without a database (using an in-memory store) is always ready.
+func (a *App) readyzEndpoint(w http.ResponseWriter, req *http.Request) {
+	r := readiness{Status: "ready", Checks: map[string]string{}}
+	if a.DB != nil {
+		r.Checks["database"] = "ok"
+		r.Checks["migrations"] = "ok"
+		ctx, cancel := context.WithTimeout(req.Context(), a.PingTimeout)
+		defer cancel()
+		// the causes of failures are logged rather than shown to anonymous callers
+		log := logging.FromContext(req.Context())
+		if err := a.DB.PingContext(ctx); err != nil {
+			log.Warn("readiness check failed", "check", "database", "error", err)
+			r.Status = "unavailable"
+			r.Checks["database"] = "unreachable"
+			r.Checks["migrations"] = "unknown"
+		} else if pending, err := migrations.PendingContext(ctx, a.DB); err != nil {
+			log.Warn("readiness check failed", "check", "migrations", "error", err)
+			r.Status = "unavailable"
+			r.Checks["migrations"] = "error"
+		} else if len(pending) > 0 {
+			r.Status = "unavailable"
+			r.Checks["migrations"] = fmt.Sprintf("%d pending, from version %d", len(pending), pending[0].Version)
+		}
+	}
+	if r.Status != "ready" {
+		respondWithJSON(w, http.StatusServiceUnavailable, r)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, r)
+}
+
+// WaitForDB pings the database until it responds, backing off exponentially
+// between attempts, and returns the last error if it has not responded
+// within timeout. A timeout of zero means a single attempt.
+func WaitForDB(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 100 * time.Millisecond
+	for {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("the database did not respond within %v: %v", timeout, err)
+		}
+		logging.Default().Info("waiting for the database", "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strconv"
+	// local packages
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+func (a *App) getIngredientsEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	ingredients, err := a.Store.GetIngredients(req.Context(), recipeID)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ingredients)
+}
+
+func (a *App) getIngredientEndpoint(w http.ResponseWriter, req *http.Request) {
+	i, ok := ingredientFromRequest(w, req)
+	if !ok {
+		return
+	}
+	if err := a.Store.GetIngredient(req.Context(), &i); err != nil {
+		respondWithStoreError(w, err, "Ingredient not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, i)
+}
+
+func (a *App) addIngredientEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	if !a.checkRecipeChange(w, req, recipeID) {
+		return
+	}
+	var i recipes.Ingredient
+	if e := decodePayload(req, &i); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	i.RecipeID = recipeID
+	if err := a.Store.AddIngredient(req.Context(), &i); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, i)
+}
+
+func (a *App) modifyIngredientEndpoint(w http.ResponseWriter, req *http.Request) {
+	target, ok := ingredientFromRequest(w, req)
+	if !ok || !a.checkRecipeChange(w, req, target.RecipeID) {
+		return
+	}
+	var i recipes.Ingredient
+	if e := decodePayload(req, &i); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	i.RecipeID, i.ID = target.RecipeID, target.ID
+	if err := a.Store.UpdateIngredient(req.Context(), &i); err != nil {
+		respondWithStoreError(w, err, "Ingredient not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, i)
+}
+
+func (a *App) deleteIngredientEndpoint(w http.ResponseWriter, req *http.Request) {
+	i, ok := ingredientFromRequest(w, req)
+	if !ok || !a.checkRecipeChange(w, req, i.RecipeID) {
+		return
+	}
+	if err := a.Store.DeleteIngredient(req.Context(), &i); err != nil {
+		respondWithStoreError(w, err, "Ingredient not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+// ingredientFromRequest parses the recipe and ingredient IDs from the path,
+// responding with an error if either is invalid.
+func ingredientFromRequest(w http.ResponseWriter, req *http.Request) (recipes.Ingredient, bool) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return recipes.Ingredient{}, false
+	}
+	id, err := strconv.Atoi(params["ingredient_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ingredient ID")
+		return recipes.Ingredient{}, false
+	}
+	return recipes.Ingredient{ID: id, RecipeID: recipeID}, true
+}
@@ -0,0 +1,95 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"os"
+	"time"
+	// local packages
+	"logging"
+	"tracing"
+)
+
+// logger returns the logger of the app, for messages which do not belong
+// to a request.
+func (a *App) logger() *logging.Logger {
+	if a.Log != nil {
+		return a.Log
+	}
+	return logging.Default()
+}
+
+// fatal logs that the app cannot start, with the error which stopped
+// it, and exits.
+func (a *App) fatal(msg string, err error) {
+	a.logger().Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// accessLogMiddleware writes an entry for every request once it has been
+// served, and gives the request a logger carrying its ID (and trace ID,
+// if it is traced). Requests which
+// failed with a server error are logged at Error level, with the cause.
+func (a *App) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		log := a.logger().With("request_id", RequestID(req.Context()))
+		if sc := tracing.SpanFromContext(req.Context()).SpanContext(); sc.IsValid() {
+			log = log.With("trace_id", sc.TraceID)
+		}
+		sw := wrapWriter(w)
+		next.ServeHTTP(sw, req.WithContext(logging.NewContext(req.Context(), log)))
+
+		fields := []interface{}{
+			"method", req.Method,
+			"route", routeName(req),
+			"path", req.URL.Path,
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration_ms", time.Since(start),
+			"remote_addr", req.RemoteAddr,
+		}
+		if e := sw.problem; e != nil {
+			fields = append(fields, "code", e.Code)
+			if e.Cause != nil {
+				fields = append(fields, "error", e.Cause)
+			}
+		}
+		level := logging.Info
+		if sw.status >= http.StatusInternalServerError {
+			level = logging.Error
+		}
+		log.Log(level, "request", fields...)
+	})
+}
+
+// statusWriter remembers the status code and size of a response, and the
+// API error it reported, if any. It also holds the span of the request,
+// if it is traced, so that writing the response can be traced.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int
+	problem *Error
+	span    *tracing.Span
+}
+
+// wrapWriter returns w if it is already a statusWriter, so that the
+// middlewares share one.
+func wrapWriter(w http.ResponseWriter) *statusWriter {
+	if sw, ok := w.(*statusWriter); ok {
+		return sw
+	}
+	return &statusWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
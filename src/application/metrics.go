@@ -0,0 +1,108 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strconv"
+	"time"
+	// local packages
+	"metrics"
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+// unmatchedRoute labels requests which did not match any route, so that
+// scanning for random paths cannot create unbounded label values.
+const unmatchedRoute = "unmatched"
+
+// appMetrics are the metrics served on /metrics.
+type appMetrics struct {
+	registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	queryDuration   *metrics.HistogramVec
+}
+
+// initMetrics registers the HTTP, store and database metrics.
+func (a *App) initMetrics() {
+	r := metrics.NewRegistry()
+	a.metrics = &appMetrics{
+		registry: r,
+		requests: r.NewCounterVec("http_requests_total",
+			"HTTP requests served, by method, route template and status code.",
+			"method", "route", "status"),
+		requestDuration: r.NewHistogramVec("http_request_duration_seconds",
+			"Time taken to serve HTTP requests, by method and route template.",
+			metrics.DefaultBuckets, "method", "route"),
+		queryDuration: r.NewHistogramVec("recipes_store_operation_duration_seconds",
+			"Time taken by the database work of each store operation, by operation and result (ok, not_found, rejected or error).",
+			metrics.DefaultBuckets, "operation", "result"),
+	}
+
+	r.NewCounterFunc("recipes_transactions_total", "Transactions run by the store, including their retries.",
+		func() float64 { return float64(recipes.GetTxStats().Transactions) })
+	r.NewCounterFunc("recipes_transaction_retries_total", "Transactions retried after a retryable error.",
+		func() float64 { return float64(recipes.GetTxStats().Retries) })
+	r.NewCounterFunc("recipes_transaction_retries_exhausted_total", "Transactions which failed after the most retries allowed.",
+		func() float64 { return float64(recipes.GetTxStats().Exhausted) })
+
+	if a.DB != nil {
+		db := a.DB
+		r.NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.",
+			func() float64 { return float64(db.Stats().MaxOpenConnections) })
+		r.NewGaugeFunc("db_open_connections", "Established connections to the database, in use or idle.",
+			func() float64 { return float64(db.Stats().OpenConnections) })
+		r.NewGaugeFunc("db_in_use_connections", "Connections to the database currently in use.",
+			func() float64 { return float64(db.Stats().InUse) })
+		r.NewGaugeFunc("db_idle_connections", "Idle connections to the database.",
+			func() float64 { return float64(db.Stats().Idle) })
+		r.NewCounterFunc("db_wait_count_total", "Times a connection had to be waited for.",
+			func() float64 { return float64(db.Stats().WaitCount) })
+		r.NewCounterFunc("db_wait_duration_seconds_total", "Total time spent waiting for a connection.",
+			func() float64 { return db.Stats().WaitDuration.Seconds() })
+		r.NewCounterFunc("db_max_idle_closed_total", "Connections closed because of the idle connection limit.",
+			func() float64 { return float64(db.Stats().MaxIdleClosed) })
+		r.NewCounterFunc("db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+			func() float64 { return float64(db.Stats().MaxLifetimeClosed) })
+	}
+}
+
+// observeQuery records the time taken by a store operation.
+func (a *App) observeQuery(operation string, d time.Duration, err error) {
+	result := "ok"
+	switch {
+	case err == recipes.ErrNotFound:
+		result = "not_found"
+	case recipes.IsRejection(err):
+		result = "rejected"
+	case err != nil:
+		result = "error"
+	}
+	a.metrics.queryDuration.Observe(d.Seconds(), operation, result)
+}
+
+// metricsMiddleware counts and times requests by the template of the route
+// they matched, such as /v1/recipes/{id:[0-9]+}.
+func (a *App) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := wrapWriter(w)
+		next.ServeHTTP(sw, req)
+
+		route := routeName(req)
+		a.metrics.requests.Inc(req.Method, route, strconv.Itoa(sw.status))
+		a.metrics.requestDuration.Observe(time.Since(start).Seconds(), req.Method, route)
+	})
+}
+
+// routeName returns the template of the route a request matched, or
+// unmatchedRoute.
+func routeName(req *http.Request) string {
+	if r := mux.CurrentRoute(req); r != nil {
+		if tpl, err := r.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
@@ -0,0 +1,852 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+)
+
+// openAPIEndpoint serves the OpenAPI 3 description of the API.
+func (a *App) openAPIEndpoint(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(openAPIDocument))
+}
+
+// docsEndpoint serves a page rendering the OpenAPI document with Swagger UI,
+// whose scripts are loaded from a CDN.
+func (a *App) docsEndpoint(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Recipes API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@3/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@3/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      window.ui = SwaggerUIBundle({url: "/v1/openapi.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+// openAPIDocument describes every route registered by InitializeWithStore.
+// The tests check that the two agree, and that the responses match it.
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Recipes API",
+    "version": "1.0.0",
+    "description": "Recipes, with their ingredients, steps and ratings, stored in CockroachDB. Errors are RFC 7807 problem documents. Reads may be anonymous unless the service requires the recipes:read scope; the scope each operation requires is given in its description."
+  },
+  "servers": [{"url": "/"}],
+  "security": [{}, {"bearerAuth": []}, {"apiKey": []}],
+  "tags": [
+    {"name": "recipes"},
+    {"name": "ingredients"},
+    {"name": "steps"},
+    {"name": "ratings"},
+    {"name": "users"},
+    {"name": "operations"}
+  ],
+  "paths": {
+    "/healthz": {
+      "get": {
+        "tags": ["operations"],
+        "operationId": "healthz",
+        "summary": "Liveness check, which does not touch the database",
+        "security": [],
+        "responses": {
+          "200": {"description": "The process is serving", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Health"}}}}
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "tags": ["operations"],
+        "operationId": "readyz",
+        "summary": "Readiness check of the database and schema migrations",
+        "security": [],
+        "responses": {
+          "200": {"description": "Ready for traffic", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Readiness"}}}},
+          "503": {"description": "Not ready", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Readiness"}}}}
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "tags": ["operations"],
+        "operationId": "metrics",
+        "summary": "Prometheus metrics",
+        "security": [],
+        "responses": {
+          "200": {"description": "Metrics in the Prometheus text format", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/v1/openapi.json": {
+      "get": {
+        "tags": ["operations"],
+        "operationId": "getOpenAPI",
+        "summary": "This document",
+        "security": [],
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/v1/docs": {
+      "get": {
+        "tags": ["operations"],
+        "operationId": "getDocs",
+        "summary": "This document, rendered by Swagger UI",
+        "security": [],
+        "responses": {
+          "200": {"description": "An HTML page", "content": {"text/html": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/v1/users": {
+      "post": {
+        "tags": ["users"],
+        "operationId": "registerUser",
+        "summary": "Register a user, who is given the author role",
+        "security": [{}],
+        "requestBody": {"$ref": "#/components/requestBodies/Credentials"},
+        "responses": {
+          "201": {"description": "The user", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "409": {"$ref": "#/components/responses/Conflict"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/users/login": {
+      "post": {
+        "tags": ["users"],
+        "operationId": "login",
+        "summary": "Log in, for a token carrying the scopes of the user's role",
+        "security": [{}],
+        "requestBody": {"$ref": "#/components/requestBodies/Credentials"},
+        "responses": {
+          "200": {"description": "A bearer token", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Token"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/users/me": {
+      "get": {
+        "tags": ["users"],
+        "operationId": "getCurrentUser",
+        "summary": "The user the token was issued to",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The user", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/users/{user_id}/role": {
+      "parameters": [{"$ref": "#/components/parameters/UserID"}],
+      "put": {
+        "tags": ["users"],
+        "operationId": "setUserRole",
+        "summary": "Change the role of a user",
+        "description": "Requires the users:admin scope.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RoleChange"}}}},
+        "responses": {
+          "200": {"description": "The user", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes": {
+      "get": {
+        "tags": ["recipes"],
+        "operationId": "listRecipes",
+        "summary": "A page of recipes, in ID order",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous. The pages either side are linked in the Link header.",
+        "parameters": [{"$ref": "#/components/parameters/Cursor"}, {"$ref": "#/components/parameters/Count"}],
+        "responses": {
+          "200": {"description": "A page of recipes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipePage"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "post": {
+        "tags": ["recipes"],
+        "operationId": "createRecipe",
+        "summary": "Create a recipe, with its ingredients and steps",
+        "description": "Requires the recipes:write scope; viewers may not create recipes.",
+        "requestBody": {"$ref": "#/components/requestBodies/Recipe"},
+        "responses": {
+          "201": {"description": "The recipe", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Recipe"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/search": {
+      "get": {
+        "tags": ["recipes"],
+        "operationId": "searchRecipes",
+        "summary": "Find rated recipes, with criteria in the query string",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "parameters": [
+          {"name": "q", "in": "query", "schema": {"type": "string", "maxLength": 200}, "description": "Words (runs of letters and digits) to find in the name, ignoring case and plurals. Each of the first 10 words must be a word of the name, or the beginning of one. Unless sort is given, the most relevant recipes come first."},
+          {"name": "min_difficulty", "in": "query", "schema": {"type": "integer", "minimum": 0, "maximum": 3}},
+          {"name": "max_difficulty", "in": "query", "schema": {"type": "integer", "minimum": 0, "maximum": 3}},
+          {"name": "vegetarian", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "min_rating", "in": "query", "schema": {"type": "number", "minimum": 0, "maximum": 5}},
+          {"name": "min_preptime", "in": "query", "schema": {"type": "number", "minimum": 0}},
+          {"name": "max_preptime", "in": "query", "schema": {"type": "number", "minimum": 0}},
+          {"name": "preptime", "in": "query", "schema": {"type": "number", "minimum": 0}, "description": "An exclusive upper bound of the preparation time"},
+          {"name": "ingredients", "in": "query", "schema": {"type": "array", "items": {"type": "string"}, "maxItems": 20}, "style": "form", "explode": true},
+          {"name": "exclude_ingredients", "in": "query", "schema": {"type": "array", "items": {"type": "string"}, "maxItems": 20}, "style": "form", "explode": true},
+          {"name": "sort", "in": "query", "schema": {"$ref": "#/components/schemas/Sort"}},
+          {"$ref": "#/components/parameters/Cursor"},
+          {"$ref": "#/components/parameters/Count"}
+        ],
+        "responses": {
+          "200": {"description": "A page of rated recipes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipeRatedPage"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "post": {
+        "tags": ["recipes"],
+        "operationId": "searchRecipesWithBody",
+        "summary": "Find rated recipes, with criteria in a JSON body or form",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous. Form fields have the same names as the members of the JSON body; ingredients and exclude_ingredients are repeated for each ingredient. The cursor and count may also be given in the query string.",
+        "parameters": [{"$ref": "#/components/parameters/Cursor"}, {"$ref": "#/components/parameters/Count"}],
+        "requestBody": {
+          "content": {
+            "application/json": {"schema": {"$ref": "#/components/schemas/RecipeSearch"}},
+            "multipart/form-data": {"schema": {"$ref": "#/components/schemas/RecipeSearch"}},
+            "application/x-www-form-urlencoded": {"schema": {"$ref": "#/components/schemas/RecipeSearch"}}
+          }
+        },
+        "responses": {
+          "200": {"description": "A page of rated recipes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipeRatedPage"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/ID"}],
+      "get": {
+        "tags": ["recipes"],
+        "operationId": "getRecipe",
+        "summary": "A recipe, with its ingredients and steps",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "responses": {
+          "200": {"description": "The recipe", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Recipe"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "put": {
+        "tags": ["recipes"],
+        "operationId": "updateRecipe",
+        "summary": "Replace a recipe and its ingredients and steps",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "requestBody": {"$ref": "#/components/requestBodies/Recipe"},
+        "responses": {
+          "200": {"description": "The recipe", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Recipe"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "patch": {
+        "tags": ["recipes"],
+        "operationId": "patchRecipe",
+        "summary": "Change some fields of a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it. The body is a JSON Merge Patch (RFC 7396), or a JSON Patch (RFC 6902) if its type is application/json-patch+json. Only name, preptime, difficulty, vegetarian and derive_preptime may be changed.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/merge-patch+json": {"schema": {"$ref": "#/components/schemas/RecipePatch"}},
+            "application/json": {"schema": {"$ref": "#/components/schemas/RecipePatch"}},
+            "application/json-patch+json": {"schema": {"$ref": "#/components/schemas/JSONPatch"}}
+          }
+        },
+        "responses": {
+          "200": {"description": "The recipe", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Recipe"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "409": {"$ref": "#/components/responses/Conflict"},
+          "415": {"$ref": "#/components/responses/UnsupportedMediaType"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "delete": {
+        "tags": ["recipes"],
+        "operationId": "deleteRecipe",
+        "summary": "Delete a recipe, with its ingredients, steps and ratings",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may delete it.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Success"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/rating": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}],
+      "get": {
+        "tags": ["ratings"],
+        "operationId": "getRatingStats",
+        "summary": "The ratings of a recipe",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "responses": {
+          "200": {"description": "The rating statistics", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RatingStats"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "post": {
+        "tags": ["ratings"],
+        "operationId": "rateRecipe",
+        "summary": "Rate a recipe, replacing the user's earlier rating of it",
+        "description": "Requires the ratings:write scope, in a user's token.",
+        "security": [{"bearerAuth": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipeRating"}}}},
+        "responses": {
+          "200": {"description": "The user's earlier rating, replaced", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipeRating"}}}},
+          "201": {"description": "The rating", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RecipeRating"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "delete": {
+        "tags": ["ratings"],
+        "operationId": "withdrawRating",
+        "summary": "Withdraw the user's rating of a recipe",
+        "description": "Requires the ratings:write scope, in a user's token.",
+        "security": [{"bearerAuth": []}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Success"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/rating/{rating_id}": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}, {"$ref": "#/components/parameters/RatingID"}],
+      "delete": {
+        "tags": ["ratings"],
+        "operationId": "deleteRating",
+        "summary": "Delete any rating of a recipe",
+        "description": "Requires the ratings:moderate scope.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Success"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/ingredients": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}],
+      "get": {
+        "tags": ["ingredients"],
+        "operationId": "listIngredients",
+        "summary": "The ingredients of a recipe",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "responses": {
+          "200": {"description": "The ingredients", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Ingredient"}}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "post": {
+        "tags": ["ingredients"],
+        "operationId": "addIngredient",
+        "summary": "Add an ingredient to a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "requestBody": {"$ref": "#/components/requestBodies/Ingredient"},
+        "responses": {
+          "201": {"description": "The ingredient", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ingredient"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/ingredients/{ingredient_id}": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}, {"$ref": "#/components/parameters/IngredientID"}],
+      "get": {
+        "tags": ["ingredients"],
+        "operationId": "getIngredient",
+        "summary": "An ingredient of a recipe",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "responses": {
+          "200": {"description": "The ingredient", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ingredient"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "put": {
+        "tags": ["ingredients"],
+        "operationId": "updateIngredient",
+        "summary": "Replace an ingredient of a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "requestBody": {"$ref": "#/components/requestBodies/Ingredient"},
+        "responses": {
+          "200": {"description": "The ingredient", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ingredient"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "delete": {
+        "tags": ["ingredients"],
+        "operationId": "deleteIngredient",
+        "summary": "Remove an ingredient from a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Success"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/steps": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}],
+      "get": {
+        "tags": ["steps"],
+        "operationId": "listSteps",
+        "summary": "The steps of a recipe, in order",
+        "description": "Requires the recipes:read scope, if reads may not be anonymous.",
+        "responses": {
+          "200": {"description": "The steps", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Step"}}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "post": {
+        "tags": ["steps"],
+        "operationId": "addStep",
+        "summary": "Add a step to the end of a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "requestBody": {"$ref": "#/components/requestBodies/Step"},
+        "responses": {
+          "201": {"description": "The step", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Step"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/steps/order": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}],
+      "put": {
+        "tags": ["steps"],
+        "operationId": "reorderSteps",
+        "summary": "Put the steps of a recipe in a new order",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it. Every step of the recipe must be listed, once.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StepOrder"}}}},
+        "responses": {
+          "200": {"description": "The steps, in their new order", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Step"}}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    },
+    "/v1/recipes/{recipe_id}/steps/{step_id}": {
+      "parameters": [{"$ref": "#/components/parameters/RecipeID"}, {"$ref": "#/components/parameters/StepID"}],
+      "put": {
+        "tags": ["steps"],
+        "operationId": "updateStep",
+        "summary": "Replace a step of a recipe, keeping its position",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "requestBody": {"$ref": "#/components/requestBodies/Step"},
+        "responses": {
+          "200": {"description": "The step", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Step"}}}},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "422": {"$ref": "#/components/responses/ValidationFailed"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      },
+      "delete": {
+        "tags": ["steps"],
+        "operationId": "deleteStep",
+        "summary": "Remove a step from a recipe",
+        "description": "Requires the recipes:write scope; only the owner of the recipe, or a moderator, may change it.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Success"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "default": {"$ref": "#/components/responses/ServerError"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT", "description": "A token from /v1/users/login, or signed by a trusted key"},
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key", "description": "A key given to another service, with its own scopes"}
+    },
+    "parameters": {
+      "ID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}, "description": "The ID of the recipe"},
+      "RecipeID": {"name": "recipe_id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "IngredientID": {"name": "ingredient_id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "StepID": {"name": "step_id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "RatingID": {"name": "rating_id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "UserID": {"name": "user_id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "Cursor": {"name": "cursor", "in": "query", "schema": {"type": "string"}, "description": "The next or prev token of the page before or after the one wanted"},
+      "Count": {"name": "count", "in": "query", "schema": {"type": "integer", "minimum": 1, "default": 10}, "description": "The size of the page, at most the service's MAX_PAGE_SIZE"}
+    },
+    "requestBodies": {
+      "Recipe": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Recipe"}}}},
+      "Ingredient": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Ingredient"}}}},
+      "Step": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Step"}}}},
+      "Credentials": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Credentials"}}}}
+    },
+    "responses": {
+      "Success": {"description": "Done", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Result"}}}},
+      "BadRequest": {"description": "The request is malformed", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "Unauthorized": {"description": "The credentials are missing or invalid", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "Forbidden": {"description": "The caller may not do this", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "NotFound": {"description": "Not found", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "Conflict": {"description": "The request conflicts with existing data", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "UnsupportedMediaType": {"description": "The body is of a type which is not accepted", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "ValidationFailed": {"description": "The payload is invalid; errors lists each invalid field", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}},
+      "ServerError": {"description": "The database is unavailable (503, which may be retried after Retry-After), did not respond in time (504), or failed (500)", "content": {"application/problem+json": {"schema": {"$ref": "#/components/schemas/Problem"}}}}
+    },
+    "schemas": {
+      "Recipe": {
+        "type": "object",
+        "required": ["id", "name", "preptime", "difficulty", "vegetarian", "derive_preptime"],
+        "properties": {
+          "id": {"type": "integer", "readOnly": true},
+          "name": {"type": "string", "maxLength": 200},
+          "preptime": {"type": "number", "minimum": 0},
+          "difficulty": {"type": "integer", "minimum": 1, "maximum": 3},
+          "vegetarian": {"type": "boolean"},
+          "owner_id": {"type": "integer", "readOnly": true, "description": "The user who created the recipe; absent for recipes created by API keys"},
+          "derive_preptime": {"type": "boolean", "description": "Set preptime to the sum of the step durations"},
+          "ingredients": {"type": "array", "items": {"$ref": "#/components/schemas/Ingredient"}, "maxItems": 100},
+          "steps": {"type": "array", "items": {"$ref": "#/components/schemas/Step"}, "maxItems": 100}
+        },
+        "additionalProperties": false
+      },
+      "RecipePatch": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "maxLength": 200},
+          "preptime": {"type": "number", "minimum": 0},
+          "difficulty": {"type": "integer", "minimum": 1, "maximum": 3},
+          "vegetarian": {"type": "boolean"},
+          "derive_preptime": {"type": "boolean"}
+        },
+        "additionalProperties": false
+      },
+      "JSONPatch": {
+        "type": "array",
+        "items": {
+          "type": "object",
+          "required": ["op", "path"],
+          "properties": {
+            "op": {"type": "string", "enum": ["add", "remove", "replace", "move", "copy", "test"]},
+            "path": {"type": "string"},
+            "from": {"type": "string"},
+            "value": {}
+          }
+        }
+      },
+      "Ingredient": {
+        "type": "object",
+        "required": ["ingredient_id", "recipe_id", "name", "quantity", "unit"],
+        "properties": {
+          "ingredient_id": {"type": "integer", "readOnly": true},
+          "recipe_id": {"type": "integer", "readOnly": true},
+          "name": {"type": "string", "maxLength": 100},
+          "quantity": {"type": "number", "minimum": 0},
+          "unit": {"type": "string", "maxLength": 20},
+          "notes": {"type": "string", "maxLength": 500}
+        },
+        "additionalProperties": false
+      },
+      "Step": {
+        "type": "object",
+        "required": ["step_id", "recipe_id", "position", "text"],
+        "properties": {
+          "step_id": {"type": "integer", "readOnly": true},
+          "recipe_id": {"type": "integer", "readOnly": true},
+          "position": {"type": "integer", "readOnly": true},
+          "text": {"type": "string", "maxLength": 2000},
+          "duration": {"type": "number", "minimum": 0, "description": "In the same unit as preptime"},
+          "timer": {"type": "integer", "minimum": 1, "description": "Seconds to count down"}
+        },
+        "additionalProperties": false
+      },
+      "StepOrder": {
+        "type": "object",
+        "required": ["step_ids"],
+        "properties": {
+          "step_ids": {"type": "array", "items": {"type": "integer"}}
+        }
+      },
+      "RecipeRated": {
+        "type": "object",
+        "required": ["id", "name", "preptime", "difficulty", "vegetarian", "avg_rating", "rating_count"],
+        "properties": {
+          "id": {"type": "integer"},
+          "name": {"type": "string"},
+          "preptime": {"type": "number"},
+          "difficulty": {"type": "integer"},
+          "vegetarian": {"type": "boolean"},
+          "avg_rating": {"type": "number", "description": "0 for a recipe which has not been rated"},
+          "rating_count": {"type": "integer"},
+          "relevance": {"type": "number", "minimum": 0, "maximum": 1, "description": "How well the name matches q, when it is given: each word of q scores 1 if it is a word of the name and 0.5 if it begins one, divided by the number of words in the name"}
+        },
+        "additionalProperties": false
+      },
+      "RecipeSearch": {
+        "type": "object",
+        "properties": {
+          "q": {"type": "string", "maxLength": 200, "description": "Words (runs of letters and digits) to find in the name, ignoring case and plurals. Each of the first 10 words must be a word of the name, or the beginning of one. Unless sort is given, the most relevant recipes come first."},
+          "min_difficulty": {"type": "integer", "minimum": 0, "maximum": 3},
+          "max_difficulty": {"type": "integer", "minimum": 0, "maximum": 3},
+          "vegetarian": {"type": "boolean", "nullable": true},
+          "min_rating": {"type": "number", "minimum": 0, "maximum": 5},
+          "min_preptime": {"type": "number", "minimum": 0, "nullable": true},
+          "max_preptime": {"type": "number", "minimum": 0, "nullable": true},
+          "preptime": {"type": "number", "minimum": 0, "nullable": true, "description": "An exclusive upper bound of the preparation time"},
+          "ingredients": {"type": "array", "items": {"type": "string"}, "maxItems": 20, "nullable": true, "description": "Ingredients which must all be used"},
+          "exclude_ingredients": {"type": "array", "items": {"type": "string"}, "maxItems": 20, "nullable": true, "description": "Ingredients which must not be used"},
+          "sort": {"$ref": "#/components/schemas/Sort"},
+          "cursor": {"type": "string"},
+          "count": {"type": "integer", "minimum": 1}
+        },
+        "additionalProperties": false
+      },
+      "Sort": {
+        "type": "string",
+        "enum": ["", "name", "-name", "preptime", "-preptime", "rating", "-rating"],
+        "description": "The order of the results, descending if prefixed with -; if empty, the most relevant first when q is given, and otherwise ID order"
+      },
+      "RecipeRating": {
+        "type": "object",
+        "required": ["rating_id", "recipe_id", "rating"],
+        "properties": {
+          "rating_id": {"type": "integer", "readOnly": true},
+          "recipe_id": {"type": "integer", "readOnly": true},
+          "user_id": {"type": "integer", "readOnly": true},
+          "rating": {"type": "integer", "minimum": 1, "maximum": 5}
+        },
+        "additionalProperties": false
+      },
+      "RatingStats": {
+        "type": "object",
+        "required": ["recipe_id", "count", "sum", "avg_rating", "histogram"],
+        "properties": {
+          "recipe_id": {"type": "integer"},
+          "count": {"type": "integer"},
+          "sum": {"type": "integer"},
+          "avg_rating": {"type": "number"},
+          "histogram": {"type": "array", "items": {"type": "integer"}, "minItems": 5, "maxItems": 5, "description": "The number of ratings of 1 to 5"}
+        },
+        "additionalProperties": false
+      },
+      "PageMetadata": {
+        "type": "object",
+        "required": ["size", "count"],
+        "properties": {
+          "size": {"type": "integer"},
+          "count": {"type": "integer"},
+          "next": {"type": "string", "description": "The cursor of the next page, if there is one"},
+          "prev": {"type": "string", "description": "The cursor of the previous page, if there is one"}
+        },
+        "additionalProperties": false
+      },
+      "RecipePage": {
+        "type": "object",
+        "required": ["data", "page"],
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/Recipe"}},
+          "page": {"$ref": "#/components/schemas/PageMetadata"}
+        },
+        "additionalProperties": false
+      },
+      "RecipeRatedPage": {
+        "type": "object",
+        "required": ["data", "page"],
+        "properties": {
+          "data": {"type": "array", "items": {"$ref": "#/components/schemas/RecipeRated"}},
+          "page": {"$ref": "#/components/schemas/PageMetadata"}
+        },
+        "additionalProperties": false
+      },
+      "User": {
+        "type": "object",
+        "required": ["user_id", "username", "role", "created_at"],
+        "properties": {
+          "user_id": {"type": "integer"},
+          "username": {"type": "string"},
+          "role": {"$ref": "#/components/schemas/Role"},
+          "created_at": {"type": "string", "format": "date-time"}
+        },
+        "additionalProperties": false
+      },
+      "Role": {
+        "type": "string",
+        "enum": ["viewer", "author", "moderator", "admin"]
+      },
+      "RoleChange": {
+        "type": "object",
+        "required": ["role"],
+        "properties": {
+          "role": {"$ref": "#/components/schemas/Role"}
+        },
+        "additionalProperties": false
+      },
+      "Credentials": {
+        "type": "object",
+        "required": ["username", "password"],
+        "properties": {
+          "username": {"type": "string", "maxLength": 50},
+          "password": {"type": "string", "minLength": 8, "maxLength": 72, "format": "password", "description": "At most 72 bytes in UTF-8"}
+        },
+        "additionalProperties": false
+      },
+      "Token": {
+        "type": "object",
+        "required": ["access_token", "token_type", "expires_in"],
+        "properties": {
+          "access_token": {"type": "string"},
+          "token_type": {"type": "string", "enum": ["Bearer"]},
+          "expires_in": {"type": "integer", "description": "Seconds until the token expires"}
+        },
+        "additionalProperties": false
+      },
+      "Result": {
+        "type": "object",
+        "required": ["result"],
+        "properties": {
+          "result": {"type": "string", "enum": ["success"]}
+        },
+        "additionalProperties": false
+      },
+      "Health": {
+        "type": "object",
+        "required": ["status"],
+        "properties": {
+          "status": {"type": "string", "enum": ["ok"]}
+        },
+        "additionalProperties": false
+      },
+      "Readiness": {
+        "type": "object",
+        "required": ["status", "checks"],
+        "properties": {
+          "status": {"type": "string", "enum": ["ready", "unavailable"]},
+          "checks": {"type": "object", "additionalProperties": {"type": "string"}, "description": "The result of each check, ok if it passed"}
+        },
+        "additionalProperties": false
+      },
+      "Problem": {
+        "type": "object",
+        "description": "An RFC 7807 problem document",
+        "required": ["type", "title", "status", "code"],
+        "properties": {
+          "type": {"type": "string"},
+          "title": {"type": "string"},
+          "status": {"type": "integer"},
+          "detail": {"type": "string"},
+          "code": {"type": "string", "enum": ["bad_request", "unauthorized", "forbidden", "not_found", "conflict", "unsupported_media_type", "validation_failed", "retryable", "unavailable", "timeout", "internal_error"]},
+          "request_id": {"type": "string", "description": "The X-Request-ID of the request, which its errors are logged against"},
+          "errors": {"type": "array", "items": {"$ref": "#/components/schemas/FieldError"}}
+        },
+        "additionalProperties": false
+      },
+      "FieldError": {
+        "type": "object",
+        "required": ["field", "code", "message"],
+        "properties": {
+          "field": {"type": "string"},
+          "code": {"type": "string", "enum": ["required", "too_short", "too_long", "too_small", "too_large", "not_allowed", "unknown_field", "invalid_type"]},
+          "message": {"type": "string"}
+        },
+        "additionalProperties": false
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,105 @@
+package application
+
+import (
+	// native packages
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	// local packages
+	"recipes"
+)
+
+// defaultPageSize is the page size used when the client does not ask for one.
+const defaultPageSize = 10
+
+// pageEnvelope wraps a page of a listing.
+type pageEnvelope struct {
+	Data interface{}  `json:"data"`
+	Page pageMetadata `json:"page"`
+}
+
+// pageMetadata describes a page, and holds the cursor tokens for the pages
+// either side of it.
+type pageMetadata struct {
+	Size  int    `json:"size"`
+	Count int    `json:"count"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// page returns the page selected by a cursor token and a page size, either
+// of which may be empty.
+func (a *App) page(cursor string, count string) (recipes.Page, *Error) {
+	size := 0
+	if count != "" {
+		var err error
+		if size, err = strconv.Atoi(count); err != nil {
+			return recipes.Page{}, invalidPayload([]FieldError{{Field: "count", Code: "invalid_type",
+				Message: "must be a whole number"}})
+		}
+	}
+	return a.pageOfSize(cursor, size)
+}
+
+// pageOfSize returns the page selected by a cursor token, which may be empty,
+// and a page size, which is the default if zero.
+func (a *App) pageOfSize(cursor string, size int) (recipes.Page, *Error) {
+	p := recipes.Page{Limit: size}
+	switch {
+	case size == 0:
+		p.Limit = defaultPageSize
+		if p.Limit > a.MaxPageSize {
+			p.Limit = a.MaxPageSize
+		}
+	case size < 0:
+		return p, invalidPayload([]FieldError{{Field: "count", Code: "too_small",
+			Message: "must be at least 1"}})
+	case size > a.MaxPageSize:
+		return p, invalidPayload([]FieldError{{Field: "count", Code: "too_large",
+			Message: fmt.Sprintf("must be at most %d", a.MaxPageSize)}})
+	}
+	if cursor != "" {
+		c, err := recipes.ParseCursor(cursor)
+		if err != nil {
+			return p, storeError(err, "")
+		}
+		p.Cursor = c
+	}
+	return p, nil
+}
+
+// respondWithPage responds with a page of a listing, with RFC 5988 Link
+// headers for the pages either side. The links are GET requests of the
+// same path with query, which must select the same listing, along with
+// the cursor and count query parameters.
+func respondWithPage(w http.ResponseWriter, req *http.Request, query url.Values, items interface{}, p recipes.Page, info recipes.PageInfo) {
+	meta := pageMetadata{Size: p.Limit, Count: reflect.ValueOf(items).Len()}
+	var links []string
+	for _, l := range []struct {
+		rel    string
+		cursor *recipes.Cursor
+		token  *string
+	}{
+		{"next", info.Next, &meta.Next},
+		{"prev", info.Prev, &meta.Prev},
+	} {
+		if l.cursor == nil {
+			continue
+		}
+		*l.token = l.cursor.Token()
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("cursor", *l.token)
+		q.Set("count", strconv.Itoa(p.Limit))
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, req.URL.Path, q.Encode(), l.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	respondWithJSON(w, http.StatusOK, pageEnvelope{Data: items, Page: meta})
+}
@@ -0,0 +1,468 @@
+package application
+
+import (
+	// native packages
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	// local packages
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// patchableRecipeFields are the members of a recipe which PATCH may change;
+// ingredients and steps have their own endpoints.
+var patchableRecipeFields = []string{"name", "preptime", "difficulty", "vegetarian", "derive_preptime"}
+
+// errPatchTest is returned when a JSON Patch "test" operation fails.
+var errPatchTest = errors.New("test operation failed")
+
+// patchRecipeEndpoint applies a JSON Merge Patch (RFC 7396) or, if the
+// Content-Type is application/json-patch+json, a JSON Patch (RFC 6902)
+// to a recipe. A plain application/json body is treated as a merge patch.
+func (a *App) patchRecipeEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	if !a.checkRecipeChange(w, req, id) {
+		return
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "" {
+		contentType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			contentType = "invalid"
+		}
+	}
+	var apply func(doc map[string]interface{}, patch []byte) (map[string]interface{}, error)
+	switch contentType {
+	case "", "application/json", mergePatchType:
+		apply = applyMergePatch
+	case jsonPatchType:
+		apply = applyJSONPatch
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported patch format")
+		return
+	}
+
+	patch, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer req.Body.Close()
+
+	// the recipe is read, patched and stored in one transaction, so that a
+	// test operation holds until the patch is stored. Why a patch does not
+	// apply is kept here, as the store only needs to know that it did not.
+	r := recipes.Recipe{ID: id}
+	var rejection *Error
+	err = a.Store.PatchRecipe(req.Context(), &r, func(r *recipes.Recipe) error {
+		if rejection = patchRecipe(r, apply, patch); rejection != nil {
+			return recipes.ErrPatchRejected
+		}
+		return nil
+	})
+	if err == recipes.ErrPatchRejected {
+		respondWithProblem(w, rejection)
+		return
+	}
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, r)
+}
+
+// patchRecipe applies a patch to a recipe, which must still be valid.
+func patchRecipe(r *recipes.Recipe, apply func(doc map[string]interface{}, patch []byte) (map[string]interface{}, error), patch []byte) *Error {
+	doc, err := patchableRecipe(*r)
+	if err != nil {
+		return &Error{Status: http.StatusInternalServerError, Code: CodeInternal,
+			Detail: "Internal server error", Cause: err}
+	}
+	doc, err = apply(doc, patch)
+	if err != nil {
+		switch err.(type) {
+		case *json.SyntaxError, *json.UnmarshalTypeError:
+			return newError(http.StatusBadRequest, "Invalid request payload")
+		default:
+			if err == errPatchTest {
+				return newError(http.StatusConflict, "Patch test failed")
+			}
+			return newError(http.StatusUnprocessableEntity, "Invalid patch: "+err.Error())
+		}
+	}
+	if err := patchedRecipe(doc, r); err != nil {
+		return newError(http.StatusUnprocessableEntity, "Invalid patch: "+err.Error())
+	}
+	return validationError(r.Validate())
+}
+
+// patchableRecipe returns the patchable members of a recipe as a JSON document.
+func patchableRecipe(r recipes.Recipe) (map[string]interface{}, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	for _, field := range patchableRecipeFields {
+		doc[field] = all[field]
+	}
+	return doc, nil
+}
+
+// patchedRecipe copies a patched JSON document back into the recipe, checking
+// that no members have been added or removed.
+func patchedRecipe(doc map[string]interface{}, r *recipes.Recipe) error {
+	for _, field := range patchableRecipeFields {
+		if _, ok := doc[field]; !ok {
+			return fmt.Errorf("%s cannot be removed", field)
+		}
+	}
+	if len(doc) != len(patchableRecipeFields) {
+		for field := range doc {
+			if !contains(patchableRecipeFields, field) {
+				return fmt.Errorf("%s cannot be patched", field)
+			}
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return fmt.Errorf("%s must be a JSON %s", typeErr.Field, jsonType(typeErr.Type))
+		}
+		return err
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func applyMergePatch(doc map[string]interface{}, patch []byte) (map[string]interface{}, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	patched, ok := mergePatch(doc, p).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("a merge patch must be a JSON object")
+	}
+	return patched, nil
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// jsonPatchOperation is a single RFC 6902 operation.
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch to doc. The operations are
+// applied in order and, if any of them fails, none of them take effect.
+func applyJSONPatch(doc map[string]interface{}, patch []byte) (map[string]interface{}, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, err
+	}
+	var result interface{} = doc
+	for n, op := range ops {
+		var err error
+		if result, err = applyOperation(result, op); err != nil {
+			if err == errPatchTest {
+				return nil, err
+			}
+			return nil, fmt.Errorf("operation %d (%s %s): %v", n, op.Op, op.Path, err)
+		}
+	}
+	patched, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the patched document must be a JSON object")
+	}
+	return patched, nil
+}
+
+func applyOperation(doc interface{}, op jsonPatchOperation) (interface{}, error) {
+	value := func() (interface{}, error) {
+		if op.Value == nil {
+			return nil, errors.New("value is required")
+		}
+		var v interface{}
+		err := json.Unmarshal(*op.Value, &v)
+		return v, err
+	}
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if op.Path == "" {
+			return v, nil
+		}
+		if doc, _, err = pointerRemove(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "move":
+		if op.Path == op.From || strings.HasPrefix(op.Path, op.From+"/") {
+			if op.Path == op.From {
+				return doc, nil
+			}
+			return nil, errors.New("cannot move a value into itself")
+		}
+		doc, v, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "copy":
+		v, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, deepCopy(v))
+	case "test":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		current, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, v) {
+			return nil, errPatchTest
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token; "-" (past the end) is only allowed when adding.
+func arrayIndex(token string, length int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > length || (i == length && !adding) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[t]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at pointer and returns the new document.
+func pointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return pointerReplaceParent(doc, parentPointer, node)
+	default:
+		return nil, fmt.Errorf("%s does not exist", parentPointer)
+	}
+}
+
+// pointerRemove removes the value at pointer and returns the new document and the removed value.
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		v, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		delete(node, last)
+		return doc, v, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = pointerReplaceParent(doc, parentPointer, node)
+		return doc, v, err
+	default:
+		return nil, nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+// pointerReplaceParent stores a resized array back into its parent.
+func pointerReplaceParent(doc interface{}, pointer string, array []interface{}) (interface{}, error) {
+	tokens, _ := parsePointer(pointer)
+	if len(tokens) == 0 {
+		return array, nil
+	}
+	grandparent, err := pointerGet(doc, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := grandparent.(type) {
+	case map[string]interface{}:
+		node[last] = array
+	case []interface{}:
+		i, _ := strconv.Atoi(last)
+		node[i] = array
+	}
+	return doc, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			c[k] = deepCopy(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(node))
+		for i, v := range node {
+			c[i] = deepCopy(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,140 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	// local packages
+	"auth"
+	"recipes"
+)
+
+// roleRanks orders the roles; each may do everything the ones ranked below it may.
+var roleRanks = map[string]int{
+	recipes.RoleViewer:    1,
+	recipes.RoleAuthor:    2,
+	recipes.RoleModerator: 3,
+	recipes.RoleAdmin:     4,
+}
+
+// roleScopes returns the scopes granted to the tokens of users with a role.
+func roleScopes(role string) []string {
+	scopes := []string{auth.ScopeRecipesRead}
+	if hasRole(role, recipes.RoleAuthor) {
+		scopes = append(scopes, auth.ScopeRecipesWrite)
+	}
+	scopes = append(scopes, auth.ScopeRatingsWrite)
+	if hasRole(role, recipes.RoleModerator) {
+		scopes = append(scopes, auth.ScopeRatingsModerate)
+	}
+	if hasRole(role, recipes.RoleAdmin) {
+		scopes = append(scopes, auth.ScopeUsersAdmin)
+	}
+	return scopes
+}
+
+// hasRole reports whether role ranks at least as high as min.
+func hasRole(role string, min string) bool {
+	return roleRanks[role] >= roleRanks[min]
+}
+
+// principalRole returns the role a caller acts with. API keys are only
+// limited by their scopes, and tokens issued before there were roles
+// belong to authors, as every user was then.
+func principalRole(p *auth.Principal) string {
+	switch {
+	case p.UserID == 0:
+		return recipes.RoleAdmin
+	case p.Role == "":
+		return recipes.RoleAuthor
+	default:
+		return p.Role
+	}
+}
+
+// canCreateRecipe reports whether the caller may create recipes.
+func canCreateRecipe(p *auth.Principal) bool {
+	return hasRole(principalRole(p), recipes.RoleAuthor)
+}
+
+// canChangeRecipe reports whether the caller may change or delete a recipe
+// (including its ingredients and steps) with the specified owner.
+// Recipes without an owner may only be changed by moderators.
+func canChangeRecipe(p *auth.Principal, ownerID int) bool {
+	role := principalRole(p)
+	if hasRole(role, recipes.RoleModerator) {
+		return true
+	}
+	return hasRole(role, recipes.RoleAuthor) && ownerID != 0 && ownerID == p.UserID
+}
+
+// canModerateRatings reports whether the caller may delete the ratings of others.
+func canModerateRatings(p *auth.Principal) bool {
+	return hasRole(principalRole(p), recipes.RoleModerator)
+}
+
+// canManageUsers reports whether the caller may give users their roles.
+func canManageUsers(p *auth.Principal) bool {
+	return hasRole(principalRole(p), recipes.RoleAdmin)
+}
+
+// currentPrincipal returns the caller, with the role they have now, or
+// responds with an error and returns nil. The role of a user is read from
+// the store rather than their token, so that a change of role, and above
+// all a demotion, takes effect at once rather than when they next log in.
+// Users the store does not know, such as those of trusted issuers, keep
+// the role in their token.
+func (a *App) currentPrincipal(w http.ResponseWriter, req *http.Request) *auth.Principal {
+	p := auth.FromContext(req.Context())
+	if p == nil {
+		respondWithError(w, http.StatusUnauthorized, "Authentication is required")
+		return nil
+	}
+	if p.UserID == 0 {
+		return p
+	}
+	u := recipes.User{ID: p.UserID}
+	switch err := a.Store.GetUser(req.Context(), &u); err {
+	case nil:
+		current := *p
+		current.Role = u.Role
+		return &current
+	case recipes.ErrNotFound:
+		return p
+	default:
+		respondWithStoreError(w, err, "")
+		return nil
+	}
+}
+
+// checkRecipeChange responds with an error, and returns false, unless the
+// caller may change the recipe.
+func (a *App) checkRecipeChange(w http.ResponseWriter, req *http.Request, recipeID int) bool {
+	p := a.currentPrincipal(w, req)
+	if p == nil {
+		return false
+	}
+	ownerID, err := a.Store.GetRecipeOwner(req.Context(), recipeID)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return false
+	}
+	if !canChangeRecipe(p, ownerID) {
+		respondWithError(w, http.StatusForbidden, "Only the owner of a recipe, or a moderator, may change it")
+		return false
+	}
+	return true
+}
+
+// checkAllowed responds with an error, and returns false, unless the
+// policy allows the caller to go ahead.
+func (a *App) checkAllowed(w http.ResponseWriter, req *http.Request, allowed func(*auth.Principal) bool, detail string) bool {
+	p := a.currentPrincipal(w, req)
+	if p == nil {
+		return false
+	}
+	if !allowed(p) {
+		respondWithError(w, http.StatusForbidden, detail)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strconv"
+	// local packages
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+func (a *App) addRatingEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	p, e := currentUser(req)
+	if e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	var rr recipes.RecipeRating
+	if e := decodePayload(req, &rr); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	rr.ID, rr.RecipeID, rr.UserID = 0, recipeID, p.UserID
+	created, err := a.Store.AddRecipeRating(req.Context(), &rr)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	if created {
+		respondWithJSON(w, http.StatusCreated, rr)
+	} else {
+		respondWithJSON(w, http.StatusOK, rr)
+	}
+}
+
+// withdrawRatingEndpoint deletes the caller's own rating of a recipe.
+func (a *App) withdrawRatingEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	p, e := currentUser(req)
+	if e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	rr := recipes.RecipeRating{RecipeID: recipeID, UserID: p.UserID}
+	if err := a.Store.WithdrawRecipeRating(req.Context(), &rr); err != nil {
+		respondWithStoreError(w, err, "Rating not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+func (a *App) getRatingStatsEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	st, err := a.Store.GetRatingStats(req.Context(), recipeID)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, st)
+}
+
+func (a *App) deleteRatingEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	id, err := strconv.Atoi(params["rating_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rating ID")
+		return
+	}
+	if !a.checkAllowed(w, req, canModerateRatings, "Only moderators may delete the ratings of others") {
+		return
+	}
+	rr := recipes.RecipeRating{ID: id, RecipeID: recipeID}
+	if err := a.Store.DeleteRecipeRating(req.Context(), &rr); err != nil {
+		respondWithStoreError(w, err, "Rating not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
@@ -0,0 +1,183 @@
+package application
+
+import (
+	// native packages
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	// local packages
+	"recipes"
+)
+
+// searchRequest is the JSON body of a search, which may also give the
+// page to return.
+type searchRequest struct {
+	recipes.RecipeSearch
+	Cursor string `json:"cursor"`
+	Count  int    `json:"count"`
+}
+
+// searchRecipesEndpoint finds rated recipes. The criteria are read from a
+// JSON body if the request has one, and otherwise from the query string or
+// form fields, which use the same names as the JSON members. The cursor and
+// count may always be given in the query string.
+func (a *App) searchRecipesEndpoint(w http.ResponseWriter, req *http.Request) {
+	var rs recipes.RecipeSearch
+	var p recipes.Page
+	var e *Error
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if req.Method == "POST" && contentType == "application/json" {
+		var sr searchRequest
+		if e = decodePayload(req, &sr); e != nil {
+			respondWithProblem(w, e)
+			return
+		}
+		rs = sr.RecipeSearch
+		if sr.Cursor == "" {
+			sr.Cursor = req.URL.Query().Get("cursor")
+		}
+		if sr.Count == 0 {
+			p, e = a.page(sr.Cursor, req.URL.Query().Get("count"))
+		} else {
+			p, e = a.pageOfSize(sr.Cursor, sr.Count)
+		}
+	} else {
+		if rs, e = searchFromForm(req); e == nil {
+			p, e = a.page(req.Form.Get("cursor"), req.Form.Get("count"))
+		}
+	}
+	if e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+
+	recipesRated, info, err := a.Store.GetRecipesRated(req.Context(), rs, p)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	// the criteria of a POST are in its body, so the links carry them instead
+	respondWithPage(w, req, searchQuery(rs), recipesRated, p, info)
+}
+
+// searchQuery encodes search criteria as the query string read by
+// searchFromForm.
+func searchQuery(rs recipes.RecipeSearch) url.Values {
+	q := url.Values{}
+	number := func(name string, f *float32) {
+		if f != nil {
+			q.Set(name, strconv.FormatFloat(float64(*f), 'g', -1, 32))
+		}
+	}
+	if rs.Query != "" {
+		q.Set("q", rs.Query)
+	}
+	if rs.MinDifficulty != 0 {
+		q.Set("min_difficulty", strconv.Itoa(rs.MinDifficulty))
+	}
+	if rs.MaxDifficulty != 0 {
+		q.Set("max_difficulty", strconv.Itoa(rs.MaxDifficulty))
+	}
+	if rs.Vegetarian != nil {
+		q.Set("vegetarian", strconv.FormatBool(*rs.Vegetarian))
+	}
+	if rs.MinRating != 0 {
+		number("min_rating", &rs.MinRating)
+	}
+	number("min_preptime", rs.MinPrepTime)
+	number("max_preptime", rs.MaxPrepTime)
+	number("preptime", rs.PrepTimeUnder)
+	for _, i := range rs.Ingredients {
+		q.Add("ingredients", i)
+	}
+	for _, i := range rs.ExcludeIngredients {
+		q.Add("exclude_ingredients", i)
+	}
+	if rs.Sort != "" {
+		q.Set("sort", rs.Sort)
+	}
+	return q
+}
+
+// searchFromForm reads search criteria from the query string or form fields.
+// The ingredients and exclude_ingredients fields may be repeated.
+func searchFromForm(req *http.Request) (recipes.RecipeSearch, *Error) {
+	var rs recipes.RecipeSearch
+	if err := req.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		return rs, &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid request payload"}
+	}
+
+	var fields []FieldError
+	var nonFinite *Error
+	invalid := func(name string, jsonType string) {
+		fields = append(fields, FieldError{Field: name, Code: "invalid_type",
+			Message: fmt.Sprintf("must be a %s", jsonType)})
+	}
+	integer := func(name string, dst *int) {
+		if value := req.Form.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				invalid(name, "whole number")
+			}
+			*dst = n
+		}
+	}
+	number := func(name string) *float32 {
+		value := req.Form.Get(name)
+		if value == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			invalid(name, "number")
+			return nil
+		}
+		// ParseFloat accepts NaN and Inf, which no bound can be checked against
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			if nonFinite == nil {
+				nonFinite = &Error{Status: http.StatusBadRequest, Code: CodeBadRequest,
+					Detail: fmt.Sprintf("%s must be a finite number", name)}
+			}
+			return nil
+		}
+		f32 := float32(f)
+		return &f32
+	}
+	boolean := func(name string) *bool {
+		value := req.Form.Get(name)
+		if value == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid(name, "boolean")
+			return nil
+		}
+		return &b
+	}
+
+	rs.Query = req.Form.Get("q")
+	integer("min_difficulty", &rs.MinDifficulty)
+	integer("max_difficulty", &rs.MaxDifficulty)
+	rs.Vegetarian = boolean("vegetarian")
+	if minRating := number("min_rating"); minRating != nil {
+		rs.MinRating = *minRating
+	}
+	rs.MinPrepTime = number("min_preptime")
+	rs.MaxPrepTime = number("max_preptime")
+	rs.PrepTimeUnder = number("preptime")
+	rs.Ingredients = req.Form["ingredients"]
+	rs.ExcludeIngredients = req.Form["exclude_ingredients"]
+	rs.Sort = req.Form.Get("sort")
+
+	if nonFinite != nil {
+		return rs, nonFinite
+	}
+	if len(fields) > 0 {
+		return rs, invalidPayload(fields)
+	}
+	return rs, validationError(rs.Validate())
+}
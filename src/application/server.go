@@ -0,0 +1,115 @@
+package application
+
+import (
+	// native packages
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// local packages
+	"config"
+)
+
+// Run serves the app on the specified port until the process is sent
+// SIGINT or SIGTERM, then shuts it down gracefully. It returns nil after a
+// clean shutdown.
+func (a *App) Run(port string) error {
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			a.logger().Info("shutting down", "signal", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	a.logger().Info("now serving recipes", "port", port)
+	return a.Serve(ctx, l)
+}
+
+// Serve serves the app on a listener until ctx is done. It then stops
+// accepting connections, waits up to Server.ShutdownTimeout for in-flight
+// requests to finish, and closes the database. An error is returned if the
+// server fails, or if requests were still running at the deadline.
+func (a *App) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:      a.Router,
+		ReadTimeout:  a.Server.ReadTimeout.Duration,
+		WriteTimeout: a.Server.WriteTimeout.Duration,
+		IdleTimeout:  a.Server.IdleTimeout.Duration,
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-served:
+		a.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), a.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	err := srv.Shutdown(shutdown)
+	if err != nil {
+		// drop the connections which did not finish in time
+		srv.Close()
+		err = fmt.Errorf("requests were still running after %v: %v", a.Server.ShutdownTimeout.Duration, err)
+	}
+	<-served
+	if closeErr := a.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		a.logger().Info("shut down cleanly")
+	}
+	return err
+}
+
+// Close exports the spans which have not yet been exported, and closes the
+// database connection pool, if there is one.
+func (a *App) Close() error {
+	if a.Tracer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.Tracer.Shutdown(ctx); err != nil {
+			a.logger().Warn("spans were not exported before shutting down", "error", err)
+		}
+	}
+	if a.DB == nil {
+		return nil
+	}
+	return a.DB.Close()
+}
+
+// withServerDefaults fills in the timeouts which are zero from the defaults.
+func withServerDefaults(s config.ServerConfig) config.ServerConfig {
+	d := config.Default().Server
+	for _, t := range []struct{ value, fallback *config.Duration }{
+		{&s.ReadTimeout, &d.ReadTimeout},
+		{&s.WriteTimeout, &d.WriteTimeout},
+		{&s.IdleTimeout, &d.IdleTimeout},
+		{&s.ShutdownTimeout, &d.ShutdownTimeout},
+	} {
+		if t.value.Duration == 0 {
+			*t.value = *t.fallback
+		}
+	}
+	return s
+}
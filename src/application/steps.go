@@ -0,0 +1,125 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strconv"
+	// local packages
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+func (a *App) getStepsEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	steps, err := a.Store.GetSteps(req.Context(), recipeID)
+	if err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, steps)
+}
+
+func (a *App) addStepEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	if !a.checkRecipeChange(w, req, recipeID) {
+		return
+	}
+	var st recipes.Step
+	if e := decodePayload(req, &st); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	st.RecipeID = recipeID
+	if err := a.Store.AddStep(req.Context(), &st); err != nil {
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, st)
+}
+
+func (a *App) modifyStepEndpoint(w http.ResponseWriter, req *http.Request) {
+	target, ok := stepFromRequest(w, req)
+	if !ok || !a.checkRecipeChange(w, req, target.RecipeID) {
+		return
+	}
+	var st recipes.Step
+	if e := decodePayload(req, &st); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	st.RecipeID, st.ID = target.RecipeID, target.ID
+	if err := a.Store.UpdateStep(req.Context(), &st); err != nil {
+		respondWithStoreError(w, err, "Step not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, st)
+}
+
+func (a *App) deleteStepEndpoint(w http.ResponseWriter, req *http.Request) {
+	st, ok := stepFromRequest(w, req)
+	if !ok || !a.checkRecipeChange(w, req, st.RecipeID) {
+		return
+	}
+	if err := a.Store.DeleteStep(req.Context(), &st); err != nil {
+		respondWithStoreError(w, err, "Step not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
+}
+
+func (a *App) reorderStepsEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+	if !a.checkRecipeChange(w, req, recipeID) {
+		return
+	}
+	var order struct {
+		StepIDs []int `json:"step_ids"`
+	}
+	if e := decodePayload(req, &order); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	steps, err := a.Store.ReorderSteps(req.Context(), recipeID, order.StepIDs)
+	if err != nil {
+		if err == recipes.ErrStepOrder {
+			respondWithError(w, http.StatusBadRequest, "Invalid step order")
+			return
+		}
+		respondWithStoreError(w, err, "Recipe not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, steps)
+}
+
+// stepFromRequest parses the recipe and step IDs from the path,
+// responding with an error if either is invalid.
+func stepFromRequest(w http.ResponseWriter, req *http.Request) (recipes.Step, bool) {
+	params := mux.Vars(req)
+	recipeID, err := strconv.Atoi(params["recipe_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return recipes.Step{}, false
+	}
+	id, err := strconv.Atoi(params["step_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid step ID")
+		return recipes.Step{}, false
+	}
+	return recipes.Step{ID: id, RecipeID: recipeID}, true
+}
@@ -0,0 +1,75 @@
+package application
+
+import (
+	// native packages
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	// local packages
+	"config"
+	"logging"
+	"tracing"
+)
+
+// newTracer returns the tracer for the configured exporter, or nil if
+// tracing is off.
+func newTracer(cfg config.TracingConfig) (*tracing.Tracer, error) {
+	var e tracing.Exporter
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		e = tracing.NewOTLPExporter(strings.TrimSuffix(cfg.Endpoint, "/"), cfg.HeaderMap())
+	case "stdout":
+		e = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		e = tracing.NewWriterExporter(f)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	return tracing.NewTracer(cfg.ServiceName, e, func(err error) {
+		logging.Default().Warn("exporting spans failed", "error", err)
+	}), nil
+}
+
+// tracingMiddleware starts a server span for every request, continuing
+// the trace of the caller if the request has a traceparent header. The
+// span is named after the method and route template, such as
+// GET /v1/recipes/{id:[0-9]+}.
+func (a *App) tracingMiddleware(next http.Handler) http.Handler {
+	if a.Tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := tracing.Extract(req.Context(), req.Header)
+		ctx, span := a.Tracer.Start(ctx, req.Method, tracing.Server)
+		defer span.End()
+
+		sw := wrapWriter(w)
+		sw.span = span
+		next.ServeHTTP(sw, req.WithContext(ctx))
+
+		route := routeName(req)
+		span.SetName(req.Method + " " + route)
+		span.SetAttributes(
+			"http.method", req.Method,
+			"http.route", route,
+			"http.target", req.URL.RequestURI(),
+			"http.status_code", sw.status,
+			"request_id", RequestID(ctx),
+		)
+		if sw.status >= http.StatusInternalServerError {
+			if sw.problem != nil && sw.problem.Cause != nil {
+				span.SetError(sw.problem.Cause)
+			} else {
+				span.SetError(errors.New(http.StatusText(sw.status)))
+			}
+		}
+	})
+}
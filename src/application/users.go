@@ -0,0 +1,106 @@
+package application
+
+import (
+	// native packages
+	"net/http"
+	"strconv"
+	// local packages
+	"auth"
+	"recipes"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+// tokenResponse is returned by a successful login.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the token in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+func (a *App) registerUserEndpoint(w http.ResponseWriter, req *http.Request) {
+	var c recipes.Credentials
+	if e := decodePayload(req, &c); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	u := recipes.User{Username: c.Username}
+	if err := u.SetPassword(c.Password); err != nil {
+		respondWithStoreError(w, err, "")
+		return
+	}
+	if err := a.Store.CreateUser(req.Context(), &u); err != nil {
+		if err == recipes.ErrConflict {
+			respondWithError(w, http.StatusConflict, "Username is already taken")
+			return
+		}
+		respondWithStoreError(w, err, "")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, u)
+}
+
+func (a *App) loginEndpoint(w http.ResponseWriter, req *http.Request) {
+	var c recipes.Credentials
+	if e := decodePayload(req, &c); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	u := recipes.User{Username: c.Username}
+	if err := a.Store.GetUserByName(req.Context(), &u); err != nil && err != recipes.ErrNotFound {
+		respondWithStoreError(w, err, "")
+		return
+	}
+	// an unknown user has no password hash, so never matches, but takes as
+	// long to check as a known one
+	if !u.CheckPassword(c.Password) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	token, err := a.Tokens.Issue(auth.Claims{Subject: strconv.Itoa(u.ID), UserID: u.ID, Username: u.Username,
+		Role: u.Role, Scope: scopeClaim(roleScopes(u.Role))}, a.TokenTTL)
+	if err != nil {
+		respondWithStoreError(w, err, "")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, tokenResponse{AccessToken: token, TokenType: "Bearer",
+		ExpiresIn: int(a.TokenTTL.Seconds())})
+}
+
+func (a *App) getCurrentUserEndpoint(w http.ResponseWriter, req *http.Request) {
+	p, e := currentUser(req)
+	if e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	u := recipes.User{ID: p.UserID}
+	if err := a.Store.GetUser(req.Context(), &u); err != nil {
+		respondWithStoreError(w, err, "User not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, u)
+}
+
+func (a *App) setUserRoleEndpoint(w http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	id, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if !a.checkAllowed(w, req, canManageUsers, "Only admins may change roles") {
+		return
+	}
+	var rc recipes.RoleChange
+	if e := decodePayload(req, &rc); e != nil {
+		respondWithProblem(w, e)
+		return
+	}
+	u := recipes.User{ID: id, Role: rc.Role}
+	if err := a.Store.SetUserRole(req.Context(), &u); err != nil {
+		respondWithStoreError(w, err, "User not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, u)
+}
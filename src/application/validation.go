@@ -0,0 +1,153 @@
+package application
+
+import (
+	// native packages
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	// local packages
+	"recipes"
+)
+
+// validator is implemented by the recipes entities.
+type validator interface {
+	Validate() error
+}
+
+// decodePayload decodes a JSON request body into v, which must be a pointer
+// to a struct. Unknown fields are rejected and, if v is a validator, it is
+// validated, so that nothing invalid ever reaches the store.
+func decodePayload(req *http.Request, v interface{}) *Error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid request payload"}
+	}
+	defer req.Body.Close()
+
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid request payload"}
+	}
+	if _, ok := raw.(map[string]interface{}); !ok {
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Request payload must be a JSON object"}
+	}
+	var fields []FieldError
+	unknownFields(raw, reflect.TypeOf(v).Elem(), "", &fields)
+	if len(fields) > 0 {
+		return invalidPayload(fields)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return invalidPayload([]FieldError{{
+				Field:   typeErr.Field,
+				Code:    "invalid_type",
+				Message: fmt.Sprintf("must be a JSON %s", jsonType(typeErr.Type)),
+			}})
+		}
+		return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: "Invalid request payload"}
+	}
+	if val, ok := v.(validator); ok {
+		return validationError(val.Validate())
+	}
+	return nil
+}
+
+// validationError converts a validation failure from the recipes package
+// to an API error, or returns nil if there was none.
+func validationError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*recipes.ValidationError)
+	if !ok {
+		return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed,
+			Detail: "The request payload is invalid", Cause: err}
+	}
+	fields := make([]FieldError, len(verr.Fields))
+	for i, f := range verr.Fields {
+		fields[i] = FieldError{Field: f.Field, Code: f.Code, Message: f.Message}
+	}
+	return invalidPayload(fields)
+}
+
+func invalidPayload(fields []FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed,
+		Detail: "The request payload is invalid", Fields: fields}
+}
+
+// unknownFields reports every member of a decoded JSON value which does not
+// match a field of the type it will be decoded into. As with encoding/json,
+// member names match field names case-insensitively.
+func unknownFields(raw interface{}, t reflect.Type, prefix string, fields *[]FieldError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch value := raw.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		for name, member := range value {
+			sf, ok := fieldByJSONName(t, name)
+			if !ok {
+				*fields = append(*fields, FieldError{Field: prefix + name, Code: "unknown_field",
+					Message: "is not a recognised field"})
+				continue
+			}
+			unknownFields(member, sf.Type, prefix+name+".", fields)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		path := strings.TrimSuffix(prefix, ".")
+		for i, item := range value {
+			unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d].", path, i), fields)
+		}
+	}
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if embedded, ok := fieldByJSONName(sf.Type, name); ok {
+				return embedded, true
+			}
+			continue
+		}
+		if tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// jsonType names the JSON type which decodes into a Go type.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "number"
+	}
+}
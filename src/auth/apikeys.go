@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// APIKey is a static key given to another service, granting it some scopes.
+type APIKey struct {
+	// Name identifies the holder of the key in logs
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+// minAPIKeyLength is the shortest key accepted, to make guessing impractical.
+const minAPIKeyLength = 32
+
+// LoadAPIKeys reads a JSON array of API keys from a file:
+//
+//	[{"name": "importer", "key": "...", "scopes": ["recipes:write"]}]
+func LoadAPIKeys(file string) ([]APIKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("parsing API keys file %s: %v", file, err)
+	}
+	for i, k := range keys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("API key %d in %s has no name", i, file)
+		}
+		if len(k.Key) < minAPIKeyLength {
+			return nil, fmt.Errorf("API key %s in %s must be at least %d characters", k.Name, file, minAPIKeyLength)
+		}
+	}
+	return keys, nil
+}
+
+// findAPIKey returns the API key matching key, or nil. Every key is compared
+// in constant time, so that the time taken does not reveal a partial match.
+func findAPIKey(keys []APIKey, key string) *APIKey {
+	var found *APIKey
+	for i := range keys {
+		if subtle.ConstantTimeCompare([]byte(keys[i].Key), []byte(key)) == 1 {
+			found = &keys[i]
+		}
+	}
+	return found
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// The scopes which may be granted to a token or API key.
+const (
+	ScopeRecipesRead     = "recipes:read"
+	ScopeRecipesWrite    = "recipes:write"
+	ScopeRatingsWrite    = "ratings:write"
+	ScopeRatingsModerate = "ratings:moderate"
+	ScopeUsersAdmin      = "users:admin"
+)
+
+// ErrInvalidAPIKey is returned when an API key does not match any known key.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// Principal is an authenticated caller: a user, or another service holding
+// an API key.
+type Principal struct {
+	// UserID is zero for an API key
+	UserID   int
+	Username string
+	// Role is the user's role, which is empty for an API key and for
+	// tokens issued before there were roles
+	Role string
+	// APIKey is the name of the API key used, if any
+	APIKey string
+	Scopes []string
+}
+
+// HasScope reports whether the principal has been granted the scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the caller of a request from a bearer token in
+// the Authorization header or an API key in the X-API-Key header.
+type Authenticator struct {
+	// Signers verify bearer tokens; a token is checked with each Signer
+	// using the algorithm named in its header
+	Signers []*Signer
+	APIKeys []APIKey
+}
+
+// Authenticate returns the caller of a request, or nil if the request has
+// no credentials. It returns an error if the credentials are not valid.
+func (a *Authenticator) Authenticate(req *http.Request) (*Principal, error) {
+	if key := req.Header.Get("X-API-Key"); key != "" {
+		k := findAPIKey(a.APIKeys, key)
+		if k == nil {
+			return nil, ErrInvalidAPIKey
+		}
+		return &Principal{APIKey: k.Name, Scopes: k.Scopes}, nil
+	}
+
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, nil
+	}
+	const prefix = "Bearer "
+	if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return nil, ErrInvalidToken
+	}
+	claims, err := a.Verify(authorization[len(prefix):])
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{UserID: claims.UserID, Username: claims.Username, Role: claims.Role, Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+// Verify checks a token against each of the Signers for its algorithm.
+func (a *Authenticator) Verify(token string) (Claims, error) {
+	alg := tokenAlgorithm(token)
+	err := ErrInvalidToken
+	for _, s := range a.Signers {
+		if s.Algorithm() != alg {
+			continue
+		}
+		var c Claims
+		if c, err = s.Verify(token); err == nil {
+			return c, nil
+		}
+		if err == ErrExpiredToken {
+			// the signature matched
+			break
+		}
+	}
+	return Claims{}, err
+}
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// NewContext returns a copy of ctx holding the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the principal held by ctx, or nil if the caller is anonymous.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey).(*Principal)
+	return p
+}
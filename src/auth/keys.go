@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// LoadHMACKey reads a secret key from a file. Surrounding whitespace,
+// such as a trailing newline, is not part of the key.
+func LoadHMACKey(file string) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(strings.TrimSpace(string(b)))
+	if len(key) < 32 {
+		return nil, fmt.Errorf("the HMAC key in %s must be at least 32 bytes", file)
+	}
+	return key, nil
+}
+
+// RandomKey returns a new random secret key. Tokens signed with it are
+// only valid until the process exits.
+func RandomKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// LoadRSAPrivateKey reads a PEM encoded RSA private key, in either PKCS #1
+// ("RSA PRIVATE KEY") or PKCS #8 ("PRIVATE KEY") form, from a file.
+func LoadRSAPrivateKey(file string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s does not hold an RSA private key: %v", file, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA private key", file)
+	}
+	return key, nil
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key ("PUBLIC KEY") or
+// certificate ("CERTIFICATE") from a file.
+func LoadRSAPublicKey(file string) (*rsa.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s does not hold a valid certificate: %v", file, err)
+		}
+		parsed = cert.PublicKey
+	} else if parsed, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("%s does not hold an RSA public key: %v", file, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA public key", file)
+	}
+	return key, nil
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", file)
+	}
+	return block, nil
+}
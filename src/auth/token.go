@@ -0,0 +1,168 @@
+// Package auth issues and verifies the signed bearer tokens and API keys
+// which identify the callers of the recipes service, and what they may do.
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned when a token is malformed or its signature
+// does not match.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrExpiredToken is returned when a token was correctly signed but has expired.
+var ErrExpiredToken = errors.New("token has expired")
+
+// ErrCannotSign is returned when a Signer which only holds an RSA public key
+// is asked to sign a token.
+var ErrCannotSign = errors.New("cannot sign without a private key")
+
+// Claims are the contents of a token, as registered JWT claims.
+type Claims struct {
+	// Subject is the user ID, as a string
+	Subject  string `json:"sub"`
+	UserID   int    `json:"uid,omitempty"`
+	Username string `json:"name,omitempty"`
+	// Scope is a space-separated list of the scopes granted, as in OAuth 2.0
+	Scope string `json:"scope,omitempty"`
+	// Role is the user's role when the token was issued
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// Signer signs and verifies JSON Web Tokens, either with HMAC SHA-256
+// (HS256) or with RSA SHA-256 (RS256).
+type Signer struct {
+	algorithm  string
+	hmacKey    []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewHMACSigner returns a Signer using the specified secret key.
+func NewHMACSigner(key []byte) *Signer {
+	return &Signer{algorithm: "HS256", hmacKey: key}
+}
+
+// NewRSASigner returns a Signer using the specified private key.
+func NewRSASigner(key *rsa.PrivateKey) *Signer {
+	return &Signer{algorithm: "RS256", privateKey: key, publicKey: &key.PublicKey}
+}
+
+// NewRSAVerifier returns a Signer which can only verify tokens, using the
+// public key of whoever signs them.
+func NewRSAVerifier(key *rsa.PublicKey) *Signer {
+	return &Signer{algorithm: "RS256", publicKey: key}
+}
+
+// Algorithm returns the JWT name of the signing algorithm, HS256 or RS256.
+func (s *Signer) Algorithm() string {
+	return s.algorithm
+}
+
+// Issue returns a token for the claims, which expires after ttl.
+func (s *Signer) Issue(c Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c.IssuedAt = now.Unix()
+	c.ExpiresAt = now.Add(ttl).Unix()
+	return s.Sign(c)
+}
+
+// Sign returns a token holding the claims as they are.
+func (s *Signer) Sign(c Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: s.algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encode(h) + "." + encode(payload)
+	signature, err := s.sign(signingInput)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encode(signature), nil
+}
+
+// Verify checks the signature and expiry of a token, and returns its claims.
+// The token must use the Signer's algorithm.
+func (s *Signer) Verify(token string) (Claims, error) {
+	var c Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || tokenAlgorithm(token) != s.algorithm {
+		return c, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !s.verify(parts[0]+"."+parts[1], signature) {
+		return c, ErrInvalidToken
+	}
+	if err := decode(parts[1], &c); err != nil {
+		return c, ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return c, ErrExpiredToken
+	}
+	return c, nil
+}
+
+func (s *Signer) sign(signingInput string) ([]byte, error) {
+	if s.algorithm == "HS256" {
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	}
+	if s.privateKey == nil {
+		return nil, ErrCannotSign
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	return rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+}
+
+func (s *Signer) verify(signingInput string, signature []byte) bool {
+	if s.algorithm == "HS256" {
+		expected, _ := s.sign(signingInput)
+		return hmac.Equal(signature, expected)
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	return rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, digest[:], signature) == nil
+}
+
+// tokenAlgorithm returns the signing algorithm named in a token's header,
+// or "" if the header cannot be read.
+func tokenAlgorithm(token string) string {
+	var h header
+	if err := decode(strings.SplitN(token, ".", 2)[0], &h); err != nil {
+		return ""
+	}
+	return h.Algorithm
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
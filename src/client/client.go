@@ -0,0 +1,202 @@
+// Package client calls the recipes API, for Go services which would
+// otherwise make the HTTP requests themselves. It uses the entities of the
+// recipes package, retries requests which failed for reasons which may be
+// temporary, and returns the errors of the service as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"tracing"
+)
+
+// RetryPolicy bounds how often, and how quickly, a request is retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialBackoff is doubled after each retry, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used by New.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// Client calls the recipes API. Its fields should not be changed while it
+// is in use; it is otherwise safe for concurrent use.
+type Client struct {
+	// BaseURL is that of the service, such as http://recipes:8100
+	BaseURL string
+	// Token is sent as a bearer token, if set, such as one from Login
+	Token string
+	// APIKey is sent in the X-API-Key header, if set and there is no Token
+	APIKey string
+	// Timeout limits each attempt at a request; zero means no limit
+	// beyond that of the context
+	Timeout time.Duration
+	Retry   RetryPolicy
+	// UserAgent identifies the calling service in the access log
+	UserAgent  string
+	HTTPClient *http.Client
+}
+
+// New returns a client of the service at baseURL, which retries with the
+// DefaultRetryPolicy and gives up on an attempt after 10 seconds.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Timeout:    10 * time.Second,
+		Retry:      DefaultRetryPolicy,
+		UserAgent:  "recipes-client",
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// request is a call to the API.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	contentType string
+	body        []byte
+	// idempotent requests are retried after any failure which may be
+	// temporary; others only when the service reports it did nothing
+	idempotent bool
+}
+
+// newRequest returns a request with payload, if it is not nil, as its JSON body.
+func newRequest(method string, path string, payload interface{}) (*request, error) {
+	r := &request{method: method, path: path,
+		idempotent: method == "GET" || method == "PUT" || method == "DELETE"}
+	if payload != nil {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		r.body, r.contentType = body, "application/json"
+	}
+	return r, nil
+}
+
+// call sends a request with a JSON payload, and decodes the response into out.
+func (c *Client) call(ctx context.Context, method string, path string, payload interface{}, out interface{}) error {
+	r, err := newRequest(method, path, payload)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, r, out)
+}
+
+// send makes a request, retrying it as the retry policy allows, and decodes
+// the response into out if it is not nil. out is zeroed first, so that it
+// holds only the response even if it was also the payload.
+func (c *Client) send(ctx context.Context, r *request, out interface{}) error {
+	backoff := c.Retry.InitialBackoff
+	for retries := 0; ; retries++ {
+		err := c.attempt(ctx, r, out)
+		if err == nil || retries >= c.Retry.MaxRetries || ctx.Err() != nil || !r.retryable(err) {
+			return err
+		}
+
+		// full jitter, so that the clients of a struggling service spread out
+		var wait time.Duration
+		if backoff > 0 {
+			wait = time.Duration(rand.Int63n(int64(backoff)))
+		}
+		if e, ok := err.(*Error); ok && e.RetryAfter > wait {
+			wait = e.RetryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; backoff > c.Retry.MaxBackoff {
+			backoff = c.Retry.MaxBackoff
+		}
+	}
+}
+
+// retryable reports whether a request which failed with err may be sent
+// again. A retryable error means the service rolled back its transaction,
+// so any request may be retried; other failures may have happened after
+// the request took effect.
+func (r *request) retryable(err error) bool {
+	e, ok := err.(*Error)
+	switch {
+	case ok && e.Code == CodeRetryable:
+		return true
+	case ok:
+		return r.idempotent && (e.StatusCode == http.StatusBadGateway ||
+			e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusGatewayTimeout)
+	}
+	// the service could not be reached, or did not answer in time
+	return r.idempotent
+}
+
+// attempt makes a request once.
+func (c *Client) attempt(ctx context.Context, r *request, out interface{}) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	u := c.BaseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequest(r.method, u, bytes.NewReader(r.body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return newError(res, body)
+	}
+	if out == nil {
+		return nil
+	}
+	v := reflect.ValueOf(out).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(body, out)
+}
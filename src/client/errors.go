@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The codes of the errors returned by the service, as in its problem
+// documents. Callers should rely on these rather than on the status code.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeValidationFailed     = "validation_failed"
+	CodeRetryable            = "retryable"
+	CodeUnavailable          = "unavailable"
+	CodeTimeout              = "timeout"
+	CodeInternal             = "internal_error"
+)
+
+// Error is an error response from the service, decoded from its RFC 7807
+// application/problem+json document.
+type Error struct {
+	StatusCode int    `json:"status"`
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Code       string `json:"code"`
+	// RequestID is that of the request, which the service logged the
+	// cause of a server error against
+	RequestID string `json:"request_id"`
+	// Fields lists each invalid field of a payload which failed validation
+	Fields []FieldError `json:"errors"`
+	// RetryAfter is how long the service asked to wait before retrying
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError describes a problem with a single field of a payload.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("recipes: %d %s: %s", e.StatusCode, e.Code, e.Detail)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", f.Field, f.Message)
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// newError returns the error for a response with an error status. A
+// response which is not a problem document, such as one from a proxy, is
+// given the code the service would have used for its status.
+func newError(res *http.Response, body []byte) *Error {
+	e := &Error{}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" || json.Unmarshal(body, e) != nil {
+		e = &Error{Title: http.StatusText(res.StatusCode), Detail: http.StatusText(res.StatusCode),
+			Code: codeForStatus(res.StatusCode)}
+	}
+	e.StatusCode = res.StatusCode
+	if e.RequestID == "" {
+		e.RequestID = res.Header.Get("X-Request-ID")
+	}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return e
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	return CodeInternal
+}
+
+// HasCode reports whether err is an error from the service with code.
+func HasCode(err error, code string) bool {
+	e, ok := err.(*Error)
+	return ok && e.Code == code
+}
+
+// IsNotFound reports whether err is the service saying the recipe (or
+// other entity) does not exist.
+func IsNotFound(err error) bool {
+	return HasCode(err, CodeNotFound)
+}
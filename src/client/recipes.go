@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"recipes"
+)
+
+// PageOptions selects a page of a listing.
+type PageOptions struct {
+	// Cursor is the Next or Prev of another page; the first page if empty
+	Cursor string
+	// Count is the size of the page; the service's default if zero
+	Count int
+}
+
+func (o PageOptions) query() url.Values {
+	q := url.Values{}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Count != 0 {
+		q.Set("count", strconv.Itoa(o.Count))
+	}
+	return q
+}
+
+// PageInfo describes a page, and holds the cursors of the pages either
+// side of it, which are empty at either end of the listing.
+type PageInfo struct {
+	Size  int    `json:"size"`
+	Count int    `json:"count"`
+	Next  string `json:"next"`
+	Prev  string `json:"prev"`
+}
+
+// RecipePage is a page of recipes, in ID order.
+type RecipePage struct {
+	Recipes []recipes.Recipe `json:"data"`
+	Page    PageInfo         `json:"page"`
+}
+
+// SearchPage is a page of the recipes found by a search.
+type SearchPage struct {
+	Recipes []recipes.RecipeRated `json:"data"`
+	Page    PageInfo              `json:"page"`
+}
+
+func recipePath(id int) string {
+	return "/v1/recipes/" + strconv.Itoa(id)
+}
+
+// GetRecipe returns a recipe, with its ingredients and steps.
+func (c *Client) GetRecipe(ctx context.Context, id int) (*recipes.Recipe, error) {
+	var r recipes.Recipe
+	if err := c.call(ctx, "GET", recipePath(id), nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRecipes returns a page of recipes.
+func (c *Client) ListRecipes(ctx context.Context, opts PageOptions) (*RecipePage, error) {
+	r, _ := newRequest("GET", "/v1/recipes", nil)
+	r.query = opts.query()
+	var p RecipePage
+	if err := c.send(ctx, r, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateRecipe creates a recipe, with its ingredients and steps, and sets
+// its ID and the rest of r as the service stored it. It is only retried
+// if the service reports that it did nothing, so that a recipe is not
+// created twice.
+func (c *Client) CreateRecipe(ctx context.Context, r *recipes.Recipe) error {
+	return c.call(ctx, "POST", "/v1/recipes", r, r)
+}
+
+// UpdateRecipe replaces the fields of the recipe with the ID of r, and sets
+// r as the service stored it. The ingredients and steps of r are ignored:
+// those of the recipe are kept, and changed through their own methods.
+func (c *Client) UpdateRecipe(ctx context.Context, r *recipes.Recipe) error {
+	return c.call(ctx, "PUT", recipePath(r.ID), r, r)
+}
+
+// PatchRecipe changes the fields of a recipe named in changes, such as
+// {"name": "Crepes"}, as a JSON Merge Patch.
+func (c *Client) PatchRecipe(ctx context.Context, id int, changes map[string]interface{}) (*recipes.Recipe, error) {
+	r, err := newRequest("PATCH", recipePath(id), changes)
+	if err != nil {
+		return nil, err
+	}
+	r.contentType = "application/merge-patch+json"
+	var patched recipes.Recipe
+	if err := c.send(ctx, r, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+// DeleteRecipe deletes a recipe, with its ingredients, steps and ratings.
+func (c *Client) DeleteRecipe(ctx context.Context, id int) error {
+	return c.call(ctx, "DELETE", recipePath(id), nil, nil)
+}
+
+// searchRequest is the body of a search, along with the page to return.
+type searchRequest struct {
+	recipes.RecipeSearch
+	Cursor string `json:"cursor,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// Search returns a page of the rated recipes matching rs.
+func (c *Client) Search(ctx context.Context, rs recipes.RecipeSearch, opts PageOptions) (*SearchPage, error) {
+	r, err := newRequest("POST", "/v1/recipes/search", searchRequest{rs, opts.Cursor, opts.Count})
+	if err != nil {
+		return nil, err
+	}
+	// a search changes nothing
+	r.idempotent = true
+	var p SearchPage
+	if err := c.send(ctx, r, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// pager fetches the pages of a listing as an iterator needs them.
+type pager struct {
+	// fetch gets the page at cursor for the iterator, returning its
+	// length and the cursor of the next page
+	fetch  func(cursor string) (int, string, error)
+	cursor string
+	left   int
+	done   bool
+	err    error
+}
+
+// next reports whether there is another item, fetching the next page if
+// the items of the current one have been used up.
+func (p *pager) next() bool {
+	for p.left == 0 {
+		if p.done || p.err != nil {
+			return false
+		}
+		n, next, err := p.fetch(p.cursor)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.left, p.cursor, p.done = n, next, next == ""
+	}
+	p.left--
+	return true
+}
+
+// RecipeIterator walks through every recipe, a page at a time:
+//
+//	it := c.AllRecipes(ctx, 50)
+//	for it.Next() {
+//		r := it.Recipe()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type RecipeIterator struct {
+	pager
+	page   []recipes.Recipe
+	recipe recipes.Recipe
+}
+
+// AllRecipes returns an iterator over every recipe, in ID order, fetching
+// count at a time (the service's default if zero).
+func (c *Client) AllRecipes(ctx context.Context, count int) *RecipeIterator {
+	it := &RecipeIterator{}
+	it.fetch = func(cursor string) (int, string, error) {
+		p, err := c.ListRecipes(ctx, PageOptions{Cursor: cursor, Count: count})
+		if err != nil {
+			return 0, "", err
+		}
+		it.page = p.Recipes
+		return len(p.Recipes), p.Page.Next, nil
+	}
+	return it
+}
+
+// Next moves to the next recipe, and reports whether there is one.
+func (it *RecipeIterator) Next() bool {
+	if !it.next() {
+		return false
+	}
+	it.recipe, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Recipe returns the current recipe.
+func (it *RecipeIterator) Recipe() recipes.Recipe {
+	return it.recipe
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *RecipeIterator) Err() error {
+	return it.err
+}
+
+// SearchIterator walks through every recipe found by a search, a page at
+// a time, in the same way as a RecipeIterator.
+type SearchIterator struct {
+	pager
+	page   []recipes.RecipeRated
+	recipe recipes.RecipeRated
+}
+
+// SearchAll returns an iterator over every rated recipe matching rs,
+// fetching count at a time (the service's default if zero).
+func (c *Client) SearchAll(ctx context.Context, rs recipes.RecipeSearch, count int) *SearchIterator {
+	it := &SearchIterator{}
+	it.fetch = func(cursor string) (int, string, error) {
+		p, err := c.Search(ctx, rs, PageOptions{Cursor: cursor, Count: count})
+		if err != nil {
+			return 0, "", err
+		}
+		it.page = p.Recipes
+		return len(p.Recipes), p.Page.Next, nil
+	}
+	return it
+}
+
+// Next moves to the next recipe, and reports whether there is one.
+func (it *SearchIterator) Next() bool {
+	if !it.next() {
+		return false
+	}
+	it.recipe, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Recipe returns the current recipe.
+func (it *SearchIterator) Recipe() recipes.RecipeRated {
+	return it.recipe
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+func ingredientsPath(recipeID int) string {
+	return recipePath(recipeID) + "/ingredients"
+}
+
+// ListIngredients returns the ingredients of a recipe.
+func (c *Client) ListIngredients(ctx context.Context, recipeID int) ([]recipes.Ingredient, error) {
+	var ingredients []recipes.Ingredient
+	if err := c.call(ctx, "GET", ingredientsPath(recipeID), nil, &ingredients); err != nil {
+		return nil, err
+	}
+	return ingredients, nil
+}
+
+// GetIngredient returns an ingredient of a recipe.
+func (c *Client) GetIngredient(ctx context.Context, recipeID int, id int) (*recipes.Ingredient, error) {
+	var i recipes.Ingredient
+	if err := c.call(ctx, "GET", ingredientsPath(recipeID)+"/"+strconv.Itoa(id), nil, &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// AddIngredient adds an ingredient to the recipe with the RecipeID of i,
+// and sets its ID.
+func (c *Client) AddIngredient(ctx context.Context, i *recipes.Ingredient) error {
+	return c.call(ctx, "POST", ingredientsPath(i.RecipeID), i, i)
+}
+
+// UpdateIngredient replaces the ingredient with the ID and RecipeID of i.
+func (c *Client) UpdateIngredient(ctx context.Context, i *recipes.Ingredient) error {
+	return c.call(ctx, "PUT", ingredientsPath(i.RecipeID)+"/"+strconv.Itoa(i.ID), i, i)
+}
+
+// DeleteIngredient removes an ingredient from a recipe.
+func (c *Client) DeleteIngredient(ctx context.Context, recipeID int, id int) error {
+	return c.call(ctx, "DELETE", ingredientsPath(recipeID)+"/"+strconv.Itoa(id), nil, nil)
+}
+
+func stepsPath(recipeID int) string {
+	return recipePath(recipeID) + "/steps"
+}
+
+// ListSteps returns the steps of a recipe, in order.
+func (c *Client) ListSteps(ctx context.Context, recipeID int) ([]recipes.Step, error) {
+	var steps []recipes.Step
+	if err := c.call(ctx, "GET", stepsPath(recipeID), nil, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// AddStep adds a step to the end of the recipe with the RecipeID of s, and
+// sets its ID and position.
+func (c *Client) AddStep(ctx context.Context, s *recipes.Step) error {
+	return c.call(ctx, "POST", stepsPath(s.RecipeID), s, s)
+}
+
+// UpdateStep replaces the step with the ID and RecipeID of s, which keeps
+// its position.
+func (c *Client) UpdateStep(ctx context.Context, s *recipes.Step) error {
+	return c.call(ctx, "PUT", stepsPath(s.RecipeID)+"/"+strconv.Itoa(s.ID), s, s)
+}
+
+// DeleteStep removes a step from a recipe.
+func (c *Client) DeleteStep(ctx context.Context, recipeID int, id int) error {
+	return c.call(ctx, "DELETE", stepsPath(recipeID)+"/"+strconv.Itoa(id), nil, nil)
+}
+
+// ReorderSteps puts the steps of a recipe in the order of stepIDs, which
+// must list every step once, and returns them in their new order.
+func (c *Client) ReorderSteps(ctx context.Context, recipeID int, stepIDs []int) ([]recipes.Step, error) {
+	var steps []recipes.Step
+	order := struct {
+		StepIDs []int `json:"step_ids"`
+	}{stepIDs}
+	if err := c.call(ctx, "PUT", stepsPath(recipeID)+"/order", order, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
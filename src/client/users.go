@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"recipes"
+)
+
+// Token is a bearer token issued by Login.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the number of seconds the token is valid for
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Register registers a user, who is given the author role.
+func (c *Client) Register(ctx context.Context, username string, password string) (*recipes.User, error) {
+	var u recipes.User
+	err := c.call(ctx, "POST", "/v1/users", recipes.Credentials{Username: username, Password: password}, &u)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Login returns a token for a user, to set as the Token of a client making
+// requests on their behalf.
+func (c *Client) Login(ctx context.Context, username string, password string) (*Token, error) {
+	r, err := newRequest("POST", "/v1/users/login", recipes.Credentials{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	// logging in changes nothing
+	r.idempotent = true
+	var t Token
+	if err := c.send(ctx, r, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CurrentUser returns the user the client's Token was issued to.
+func (c *Client) CurrentUser(ctx context.Context) (*recipes.User, error) {
+	var u recipes.User
+	if err := c.call(ctx, "GET", "/v1/users/me", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetUserRole changes the role of a user, which needs the users:admin scope.
+func (c *Client) SetUserRole(ctx context.Context, userID int, role string) (*recipes.User, error) {
+	var u recipes.User
+	path := "/v1/users/" + strconv.Itoa(userID) + "/role"
+	if err := c.call(ctx, "PUT", path, recipes.RoleChange{Role: role}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func ratingPath(recipeID int) string {
+	return recipePath(recipeID) + "/rating"
+}
+
+// Rate rates a recipe on behalf of the user of the client's Token,
+// replacing their earlier rating of it, if any.
+func (c *Client) Rate(ctx context.Context, recipeID int, rating int) (*recipes.RecipeRating, error) {
+	r, err := newRequest("POST", ratingPath(recipeID), recipes.RecipeRating{Rating: rating})
+	if err != nil {
+		return nil, err
+	}
+	// rating again replaces the rating
+	r.idempotent = true
+	var rr recipes.RecipeRating
+	if err := c.send(ctx, r, &rr); err != nil {
+		return nil, err
+	}
+	return &rr, nil
+}
+
+// WithdrawRating withdraws the rating of a recipe by the user of the
+// client's Token.
+func (c *Client) WithdrawRating(ctx context.Context, recipeID int) error {
+	return c.call(ctx, "DELETE", ratingPath(recipeID), nil, nil)
+}
+
+// GetRatingStats returns the number, average and spread of the ratings of
+// a recipe.
+func (c *Client) GetRatingStats(ctx context.Context, recipeID int) (*recipes.RatingStats, error) {
+	var st recipes.RatingStats
+	if err := c.call(ctx, "GET", ratingPath(recipeID), nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// DeleteRating deletes any rating of a recipe, which needs the
+// ratings:moderate scope.
+func (c *Client) DeleteRating(ctx context.Context, recipeID int, ratingID int) error {
+	return c.call(ctx, "DELETE", ratingPath(recipeID)+"/"+strconv.Itoa(ratingID), nil, nil)
+}
@@ -0,0 +1,237 @@
+// Package commands holds the administrative subcommands of the service,
+// such as migrating the schema or inspecting and fixing recipes. They work
+// through the same store as the API, so that operators need not write SQL
+// against the database themselves.
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"migrations"
+	"recipes"
+)
+
+// Env is what a command works on and writes to.
+type Env struct {
+	// DB is only needed by the migrate commands
+	DB    *sql.DB
+	Store recipes.RecipeStore
+	// In is read by the commands which take a JSON document, when they are
+	// not given a file
+	In io.Reader
+	// Out receives the results of a command, and Err its usage and notes
+	Out io.Writer
+	Err io.Writer
+}
+
+// ErrUsage is returned when a command is unknown or was given the wrong
+// arguments; its usage has already been written to Env.Err.
+var ErrUsage = errors.New("invalid usage")
+
+// command is a subcommand, run with the arguments which follow its name.
+type command struct {
+	name string
+	args string
+	help string
+	run  func(ctx context.Context, env Env, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// serve is run by main, which owns the server, and is only listed here
+	commands = []command{
+		{"serve", "", "serve the API (the default)", nil},
+		{"migrate up", "", "apply every pending schema migration", migrateUp},
+		{"migrate down", "[-steps n]", "roll back the most recent schema migrations, 1 by default", migrateDown},
+		{"migrate status", "", "list the schema migrations and when they were applied", migrateStatus},
+		{"recipes list", "[-count n] [-cursor c]", "list a page of recipes", listRecipes},
+		{"recipes get", "<id>", "print a recipe, with its ingredients and steps, as JSON", getRecipe},
+		{"recipes create", "[file]", "create a recipe from a JSON file, or from standard input", createRecipe},
+		{"recipes delete", "<id>", "delete a recipe, with its ingredients, steps and ratings", deleteRecipe},
+		{"recipes reindex", "", "rebuild the search index from the names of every recipe", reindexRecipes},
+		{"ratings recompute", "", "rebuild the rating statistics of every recipe from its ratings", recomputeRatings},
+		{"users set-role", "<username> <role>", "give a user a role, such as admin", setRole},
+		{"seed", "[-force]", "add some sample recipes to an empty database", seed},
+	}
+}
+
+// Usage writes the list of commands to w.
+func Usage(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.help)
+	}
+	tw.Flush()
+}
+
+// IsServe reports whether args, the arguments after any flags of the
+// program, ask for the API to be served.
+func IsServe(args []string) bool {
+	return len(args) == 0 || (len(args) == 1 && args[0] == "serve")
+}
+
+// Run runs the command named by the leading args, such as "migrate up",
+// with the rest of args.
+func Run(ctx context.Context, env Env, args []string) error {
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if c.run == nil || len(args) < len(words) || strings.Join(args[:len(words)], " ") != c.name {
+			continue
+		}
+		return c.run(ctx, env, args[len(words):])
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(env.Err, "No command was given")
+	} else {
+		fmt.Fprintf(env.Err, "Unknown command %q\n", strings.Join(args, " "))
+	}
+	Usage(env.Err)
+	return ErrUsage
+}
+
+// flags returns the flag set of the command named name, which writes its
+// usage to env.Err.
+func flags(env Env, name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.Err)
+	fs.Usage = func() {
+		fmt.Fprintf(env.Err, "Usage: %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the arguments of a command, which must leave between min
+// and max positional arguments.
+func parse(fs *flag.FlagSet, args []string, min int, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return ErrUsage
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		fs.Usage()
+		return ErrUsage
+	}
+	return nil
+}
+
+func needDB(env Env) error {
+	if env.DB == nil {
+		return errors.New("the migrate commands need a database")
+	}
+	return nil
+}
+
+func migrateUp(ctx context.Context, env Env, args []string) error {
+	if err := parse(flags(env, "migrate up", ""), args, 0, 0); err != nil {
+		return err
+	}
+	if err := needDB(env); err != nil {
+		return err
+	}
+	applied, err := migrations.Up(env.DB)
+	for _, m := range applied {
+		fmt.Fprintf(env.Out, "Applied migration %d (%s)\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Fprintf(env.Out, "The schema is up to date, at version %d\n", migrations.Latest())
+	}
+	return nil
+}
+
+func migrateDown(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "migrate down", "[-steps n]")
+	steps := fs.Int("steps", 1, "the number of migrations to roll back")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		fmt.Fprintln(env.Err, "-steps must be at least 1")
+		return ErrUsage
+	}
+	if err := needDB(env); err != nil {
+		return err
+	}
+	rolledBack, err := migrations.Down(env.DB, *steps)
+	for _, m := range rolledBack {
+		fmt.Fprintf(env.Out, "Rolled back migration %d (%s)\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(rolledBack) == 0 {
+		fmt.Fprintln(env.Out, "No migrations have been applied")
+	}
+	return nil
+}
+
+func migrateStatus(ctx context.Context, env Env, args []string) error {
+	if err := parse(flags(env, "migrate status", ""), args, 0, 0); err != nil {
+		return err
+	}
+	if err := needDB(env); err != nil {
+		return err
+	}
+	statuses, err := migrations.GetStatus(env.DB)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied {
+			applied = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return tw.Flush()
+}
+
+func recomputeRatings(ctx context.Context, env Env, args []string) error {
+	if err := parse(flags(env, "ratings recompute", ""), args, 0, 0); err != nil {
+		return err
+	}
+	n, err := env.Store.RecomputeRatingStats(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "Recomputed the rating statistics of %d recipes\n", n)
+	return nil
+}
+
+func setRole(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "users set-role", "<username> <role>")
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+	u := recipes.User{Username: fs.Arg(0)}
+	rc := recipes.RoleChange{Role: fs.Arg(1)}
+	if err := rc.Validate(); err != nil {
+		return err
+	}
+	if err := env.Store.GetUserByName(ctx, &u); err != nil {
+		if err == recipes.ErrNotFound {
+			return fmt.Errorf("user %s not found", u.Username)
+		}
+		return err
+	}
+	u.Role = rc.Role
+	if err := env.Store.SetUserRole(ctx, &u); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "User %s is now a %s\n", u.Username, u.Role)
+	return nil
+}
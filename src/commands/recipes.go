@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"recipes"
+)
+
+// recipeID parses the ID of a recipe given on the command line.
+func recipeID(env Env, arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		fmt.Fprintf(env.Err, "Invalid recipe ID %q\n", arg)
+		return 0, ErrUsage
+	}
+	return id, nil
+}
+
+// storeError describes the error of a store operation on a recipe.
+func storeError(err error, id int) error {
+	if err == recipes.ErrNotFound {
+		return fmt.Errorf("recipe %d not found", id)
+	}
+	return err
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func listRecipes(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "recipes list", "[-count n] [-cursor c]")
+	count := fs.Int("count", 20, "the number of recipes to list")
+	cursor := fs.String("cursor", "", "the cursor of the page to list, as printed after the previous page")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *count < 1 {
+		fmt.Fprintln(env.Err, "-count must be at least 1")
+		return ErrUsage
+	}
+	p := recipes.Page{Limit: *count}
+	if *cursor != "" {
+		c, err := recipes.ParseCursor(*cursor)
+		if err != nil {
+			fmt.Fprintf(env.Err, "Invalid cursor %q\n", *cursor)
+			return ErrUsage
+		}
+		p.Cursor = c
+	}
+	list, info, err := env.Store.GetRecipes(ctx, p)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREPTIME\tDIFFICULTY\tVEGETARIAN")
+	for _, r := range list {
+		fmt.Fprintf(tw, "%d\t%s\t%g\t%d\t%t\n", r.ID, r.Name, r.PrepTime, r.Difficulty, r.Vegetarian)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if info.Next != nil {
+		fmt.Fprintf(env.Err, "More recipes follow: recipes list -cursor %s\n", info.Next.Token())
+	}
+	return nil
+}
+
+func getRecipe(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "recipes get", "<id>")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	id, err := recipeID(env, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	r := recipes.Recipe{ID: id}
+	if err := env.Store.GetRecipe(ctx, &r); err != nil {
+		return storeError(err, id)
+	}
+	return writeJSON(env.Out, r)
+}
+
+// createRecipe creates a recipe from a document like the payload of
+// POST /v1/recipes, which is checked in the same way.
+func createRecipe(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "recipes create", "[file]")
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+	in := env.In
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var r recipes.Recipe
+	dec := json.NewDecoder(in)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return fmt.Errorf("invalid recipe: %v", err)
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	// the ID of the recipe, and those of its ingredients and steps, are chosen by the store
+	r.ID, r.OwnerID = 0, 0
+	if err := env.Store.CreateRecipe(ctx, &r); err != nil {
+		return err
+	}
+	return writeJSON(env.Out, r)
+}
+
+// reindexRecipes indexes the recipes created before there was a search
+// index, which the index is otherwise kept up to date with.
+func reindexRecipes(ctx context.Context, env Env, args []string) error {
+	if err := parse(flags(env, "recipes reindex", ""), args, 0, 0); err != nil {
+		return err
+	}
+	n, err := env.Store.ReindexRecipes(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "Indexed the names of %d recipes\n", n)
+	return nil
+}
+
+func deleteRecipe(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "recipes delete", "<id>")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	id, err := recipeID(env, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := env.Store.DeleteRecipe(ctx, &recipes.Recipe{ID: id}); err != nil {
+		return storeError(err, id)
+	}
+	fmt.Fprintf(env.Out, "Deleted recipe %d\n", id)
+	return nil
+}
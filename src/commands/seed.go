@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"recipes"
+)
+
+// duration returns a step duration, in the same units as PrepTime.
+func duration(d float32) *float32 {
+	return &d
+}
+
+// samples are the recipes added by seed, for trying out the API or a client.
+var samples = []recipes.Recipe{
+	{Name: "Pancakes", Difficulty: 1, Vegetarian: true, DerivePrepTime: true,
+		Ingredients: []recipes.Ingredient{
+			{Name: "flour", Quantity: 125, Unit: "g"},
+			{Name: "milk", Quantity: 300, Unit: "ml"},
+			{Name: "egg", Quantity: 2},
+		},
+		Steps: []recipes.Step{
+			{Text: "Whisk the flour, milk and eggs into a smooth batter", Duration: duration(0.1)},
+			{Text: "Rest the batter", Duration: duration(0.5)},
+			{Text: "Fry ladlefuls in a hot buttered pan, turning once", Duration: duration(0.3)},
+		}},
+	{Name: "Tomato soup", Difficulty: 1, Vegetarian: true, DerivePrepTime: true,
+		Ingredients: []recipes.Ingredient{
+			{Name: "tomatoes", Quantity: 1, Unit: "kg"},
+			{Name: "onion", Quantity: 1},
+			{Name: "vegetable stock", Quantity: 500, Unit: "ml"},
+		},
+		Steps: []recipes.Step{
+			{Text: "Soften the chopped onion", Duration: duration(0.2)},
+			{Text: "Add the tomatoes and stock and simmer", Duration: duration(0.5)},
+			{Text: "Blend until smooth and season"},
+		}},
+	{Name: "Roast chicken", Difficulty: 2, DerivePrepTime: true,
+		Ingredients: []recipes.Ingredient{
+			{Name: "chicken", Quantity: 1.5, Unit: "kg"},
+			{Name: "lemon", Quantity: 1},
+			{Name: "thyme", Quantity: 4, Unit: "sprigs"},
+		},
+		Steps: []recipes.Step{
+			{Text: "Stuff the chicken with the halved lemon and thyme", Duration: duration(0.1)},
+			{Text: "Roast at 200C", Duration: duration(1.5)},
+			{Text: "Rest before carving", Duration: duration(0.2)},
+		}},
+	{Name: "Beef Wellington", Difficulty: 3, DerivePrepTime: true,
+		Ingredients: []recipes.Ingredient{
+			{Name: "beef fillet", Quantity: 1, Unit: "kg"},
+			{Name: "mushrooms", Quantity: 500, Unit: "g", Notes: "finely chopped"},
+			{Name: "puff pastry", Quantity: 500, Unit: "g"},
+		},
+		Steps: []recipes.Step{
+			{Text: "Sear the beef on every side", Duration: duration(0.2)},
+			{Text: "Cook the mushrooms down to a dry paste", Duration: duration(0.3)},
+			{Text: "Wrap the beef in the mushrooms and pastry, and chill", Duration: duration(0.5)},
+			{Text: "Bake at 200C", Duration: duration(0.7)},
+		}},
+}
+
+// seed adds the samples, unless there are recipes already, which it
+// would otherwise be mixed up with.
+func seed(ctx context.Context, env Env, args []string) error {
+	fs := flags(env, "seed", "[-force]")
+	force := fs.Bool("force", false, "add the samples even if there are recipes already")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if !*force {
+		existing, _, err := env.Store.GetRecipes(ctx, recipes.Page{Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("there are recipes already; use seed -force to add the samples anyway")
+		}
+	}
+	for _, sample := range samples {
+		r := sample
+		// the store sets the IDs of the ingredients and steps, so they
+		// must not be shared with the samples
+		r.Ingredients = append([]recipes.Ingredient(nil), sample.Ingredients...)
+		r.Steps = append([]recipes.Step(nil), sample.Steps...)
+		if err := env.Store.CreateRecipe(ctx, &r); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Out, "Created recipe %d (%s)\n", r.ID, r.Name)
+	}
+	return nil
+}
@@ -0,0 +1,581 @@
+// Package config loads the service configuration from an optional
+// JSON file and environment variables, and validates it.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the complete service configuration.
+type Config struct {
+	// Port is the HTTP port to serve on
+	Port string `json:"port"`
+	// AutoMigrate applies any pending schema migrations at startup
+	AutoMigrate bool `json:"auto_migrate"`
+	// MaxPageSize is the most items a client may ask for in one page
+	MaxPageSize int            `json:"max_page_size"`
+	Server      ServerConfig   `json:"server"`
+	Database    DatabaseConfig `json:"database"`
+	Store       StoreConfig    `json:"store"`
+	Auth        AuthConfig     `json:"auth"`
+	Log         LogConfig      `json:"log"`
+	Tracing     TracingConfig  `json:"tracing"`
+}
+
+// TracingConfig describes where OpenTelemetry spans are exported to.
+type TracingConfig struct {
+	// Exporter is none, otlp (to a collector), stdout or file
+	Exporter string `json:"exporter"`
+	// Endpoint is the base URL of the OTLP/HTTP collector; spans are
+	// posted to its /v1/traces path
+	Endpoint string `json:"endpoint"`
+	// Headers are sent to the collector, as comma-separated key=value pairs
+	Headers string `json:"headers"`
+	// File receives the spans, one JSON object per line, for the file exporter
+	File string `json:"file"`
+	// ServiceName identifies the service in the traces
+	ServiceName string `json:"service_name"`
+}
+
+// HeaderMap returns the headers to send to the collector.
+func (t TracingConfig) HeaderMap() map[string]string {
+	headers := map[string]string{}
+	for _, kv := range strings.Split(t.Headers, ",") {
+		if i := strings.Index(kv, "="); i > 0 {
+			headers[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	return headers
+}
+
+// LogConfig describes what is logged.
+type LogConfig struct {
+	// Level is the least severe level logged: debug, info, warn or error
+	Level string `json:"level"`
+}
+
+// ServerConfig describes the timeouts of the HTTP server.
+type ServerConfig struct {
+	// ReadTimeout limits reading a whole request, including the body
+	ReadTimeout Duration `json:"read_timeout"`
+	// WriteTimeout limits the time from the end of reading the request
+	// headers to the end of writing the response
+	WriteTimeout Duration `json:"write_timeout"`
+	// IdleTimeout is how long a keep-alive connection waits for the next request
+	IdleTimeout Duration `json:"idle_timeout"`
+	// ShutdownTimeout is how long in-flight requests are given to finish
+	// when the service is stopped
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+}
+
+// StoreConfig limits the time taken by each operation of the store, such
+// as GetRecipe or GetRecipesRated.
+type StoreConfig struct {
+	// Timeout limits the operations without an entry in Timeouts; zero
+	// means no limit
+	Timeout Duration `json:"timeout"`
+	// Timeouts holds the limits of particular operations
+	Timeouts map[string]Duration `json:"timeouts"`
+}
+
+// TimeoutMap returns the limits of particular operations.
+func (s StoreConfig) TimeoutMap() map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for op, d := range s.Timeouts {
+		timeouts[op] = d.Duration
+	}
+	return timeouts
+}
+
+// AuthConfig describes how callers are authenticated, and how the tokens
+// given to users are signed.
+type AuthConfig struct {
+	// HMACKeyFile holds the secret key tokens are signed with; if neither it
+	// nor RSAPrivateKeyFile is set a random key is used, and tokens do not
+	// survive a restart
+	HMACKeyFile string `json:"hmac_key_file"`
+	// RSAPrivateKeyFile holds a PEM encoded key to sign tokens with RS256,
+	// in preference to the HMAC key
+	RSAPrivateKeyFile string `json:"rsa_private_key_file"`
+	// RSAPublicKeyFile holds the PEM encoded public key (or certificate) of
+	// another issuer of RS256 tokens, which are also accepted
+	RSAPublicKeyFile string `json:"rsa_public_key_file"`
+	// APIKeysFile holds a JSON array of static API keys and their scopes
+	APIKeysFile string `json:"api_keys_file"`
+	// AnonymousReads lets callers without credentials read recipes
+	AnonymousReads bool `json:"anonymous_reads"`
+	// TokenTTL is how long a token is valid for after login
+	TokenTTL Duration `json:"token_ttl"`
+}
+
+// DatabaseConfig describes how to connect to CockroachDB.
+type DatabaseConfig struct {
+	// Hosts are tried in order, the first to respond is used
+	Hosts           []string `json:"hosts"`
+	Port            int      `json:"port"`
+	User            string   `json:"user"`
+	Password        string   `json:"password"`
+	Name            string   `json:"name"`
+	ApplicationName string   `json:"application_name"`
+
+	// SSLMode is one of disable, require, verify-ca or verify-full
+	SSLMode     string `json:"sslmode"`
+	SSLRootCert string `json:"sslrootcert"`
+	SSLCert     string `json:"sslcert"`
+	SSLKey      string `json:"sslkey"`
+
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnectTimeout  Duration `json:"connect_timeout"`
+
+	// StartupTimeout is how long to wait for the database to respond at
+	// startup, retrying with backoff; zero means not to wait
+	StartupTimeout Duration `json:"startup_timeout"`
+	// PingTimeout limits the readiness check of the database
+	PingTimeout Duration `json:"ping_timeout"`
+}
+
+// Duration is a time.Duration which is written as "30s", "5m" etc in JSON.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string such as "30s".
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %s", b)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string such as "30s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default returns the configuration used by docker-compose.
+func Default() Config {
+	return Config{
+		Port:        "8100",
+		MaxPageSize: 100,
+		Server: ServerConfig{
+			ReadTimeout:     Duration{15 * time.Second},
+			WriteTimeout:    Duration{30 * time.Second},
+			IdleTimeout:     Duration{2 * time.Minute},
+			ShutdownTimeout: Duration{20 * time.Second},
+		},
+		Database: DatabaseConfig{
+			Hosts:           []string{"cockroach-backend"},
+			Port:            26257,
+			ApplicationName: "restful_cockroach",
+			SSLMode:         "disable",
+			MaxOpenConns:    10,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: Duration{30 * time.Minute},
+			ConnectTimeout:  Duration{10 * time.Second},
+			StartupTimeout:  Duration{time.Minute},
+			PingTimeout:     Duration{2 * time.Second},
+		},
+		Store: StoreConfig{
+			Timeout: Duration{5 * time.Second},
+			Timeouts: map[string]Duration{
+				// searches join the rating statistics and may check the
+				// ingredients of every recipe
+				"GetRecipesRated": {10 * time.Second},
+				// run by hand, with the ratings recompute command
+				"RecomputeRatingStats": {0},
+				// run by hand, with the recipes reindex command
+				"ReindexRecipes": {0},
+			},
+		},
+		Auth: AuthConfig{
+			AnonymousReads: true,
+			TokenTTL:       Duration{24 * time.Hour},
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "restful_cockroach",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the JSON file named by
+// CONFIG_FILE (if set) and then the environment, and validates the result.
+func Load() (Config, error) {
+	cfg := Default()
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return cfg, err
+		}
+	}
+	errs := cfg.loadEnv()
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return cfg, &Error{Problems: errs}
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for missing or inconsistent settings.
+func (c Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return &Error{Problems: errs}
+	}
+	return nil
+}
+
+// Error lists everything wrong with a configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+func (c *Config) loadFile(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading config file: %v", err)
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return fmt.Errorf("parsing config file %s: %v", file, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the configuration with any environment variables set,
+// returning a problem for each one that cannot be parsed.
+func (c *Config) loadEnv() []string {
+	var errs []string
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not an integer", name, v))
+				return
+			}
+			*dst = i
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a boolean", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a duration", name, v))
+				return
+			}
+			dst.Duration = d
+		}
+	}
+
+	str("PORT", &c.Port)
+	boolean("AUTO_MIGRATE", &c.AutoMigrate)
+	integer("MAX_PAGE_SIZE", &c.MaxPageSize)
+
+	duration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	duration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	db := &c.Database
+	if v, ok := os.LookupEnv("COCKROACH_HOSTS"); ok {
+		db.Hosts = nil
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				db.Hosts = append(db.Hosts, h)
+			}
+		}
+	}
+	integer("COCKROACH_PORT", &db.Port)
+	str("COCKROACH_USER", &db.User)
+	str("COCKROACH_PASSWORD", &db.Password)
+	str("COCKROACH_DB", &db.Name)
+	str("COCKROACH_APPLICATION_NAME", &db.ApplicationName)
+	str("COCKROACH_SSLMODE", &db.SSLMode)
+	str("COCKROACH_SSLROOTCERT", &db.SSLRootCert)
+	str("COCKROACH_SSLCERT", &db.SSLCert)
+	str("COCKROACH_SSLKEY", &db.SSLKey)
+	integer("COCKROACH_MAX_OPEN_CONNS", &db.MaxOpenConns)
+	integer("COCKROACH_MAX_IDLE_CONNS", &db.MaxIdleConns)
+	duration("COCKROACH_CONN_MAX_LIFETIME", &db.ConnMaxLifetime)
+	duration("COCKROACH_CONNECT_TIMEOUT", &db.ConnectTimeout)
+	duration("COCKROACH_STARTUP_TIMEOUT", &db.StartupTimeout)
+	duration("COCKROACH_PING_TIMEOUT", &db.PingTimeout)
+
+	duration("STORE_TIMEOUT", &c.Store.Timeout)
+	if v, ok := os.LookupEnv("STORE_TIMEOUTS"); ok {
+		for _, kv := range strings.Split(v, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			i := strings.Index(kv, "=")
+			if i < 1 {
+				errs = append(errs, fmt.Sprintf("STORE_TIMEOUTS: %q is not operation=duration", kv))
+				continue
+			}
+			d, err := time.ParseDuration(kv[i+1:])
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("STORE_TIMEOUTS: %q is not a duration", kv[i+1:]))
+				continue
+			}
+			if c.Store.Timeouts == nil {
+				c.Store.Timeouts = map[string]Duration{}
+			}
+			c.Store.Timeouts[kv[:i]] = Duration{d}
+		}
+	}
+
+	str("AUTH_HMAC_KEY_FILE", &c.Auth.HMACKeyFile)
+	str("AUTH_RSA_PRIVATE_KEY_FILE", &c.Auth.RSAPrivateKeyFile)
+	str("AUTH_RSA_PUBLIC_KEY_FILE", &c.Auth.RSAPublicKeyFile)
+	str("AUTH_API_KEYS_FILE", &c.Auth.APIKeysFile)
+	boolean("AUTH_ANONYMOUS_READS", &c.Auth.AnonymousReads)
+	duration("AUTH_TOKEN_TTL", &c.Auth.TokenTTL)
+
+	str("LOG_LEVEL", &c.Log.Level)
+
+	// the names used by the OpenTelemetry SDKs, except OTEL_TRACES_FILE
+	str("OTEL_TRACES_EXPORTER", &c.Tracing.Exporter)
+	str("OTEL_EXPORTER_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	str("OTEL_EXPORTER_OTLP_HEADERS", &c.Tracing.Headers)
+	str("OTEL_TRACES_FILE", &c.Tracing.File)
+	str("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+	return errs
+}
+
+func (c Config) validate() []string {
+	var errs []string
+	if p, err := strconv.Atoi(c.Port); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Sprintf("PORT: %q is not a valid port", c.Port))
+	}
+	if c.MaxPageSize < 1 {
+		errs = append(errs, "MAX_PAGE_SIZE: must be at least 1")
+	}
+	errs = append(errs, c.Server.validate()...)
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.Store.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.Log.validate()...)
+	return append(errs, c.Tracing.validate()...)
+}
+
+func (s StoreConfig) validate() []string {
+	var errs []string
+	if s.Timeout.Duration < 0 {
+		errs = append(errs, "STORE_TIMEOUT: must not be negative")
+	}
+	for op, d := range s.Timeouts {
+		if d.Duration < 0 {
+			errs = append(errs, fmt.Sprintf("STORE_TIMEOUTS: the timeout of %s must not be negative", op))
+		}
+	}
+	return errs
+}
+
+func (t TracingConfig) validate() []string {
+	var errs []string
+	switch t.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("OTEL_EXPORTER_OTLP_ENDPOINT: %q is not an http or https URL", t.Endpoint))
+		}
+	case "file":
+		if t.File == "" {
+			errs = append(errs, "OTEL_TRACES_FILE: is required by the file exporter")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("OTEL_TRACES_EXPORTER: %q must be one of none, otlp, stdout or file", t.Exporter))
+	}
+	if t.ServiceName == "" {
+		errs = append(errs, "OTEL_SERVICE_NAME: must not be empty")
+	}
+	return errs
+}
+
+func (l LogConfig) validate() []string {
+	switch strings.ToLower(l.Level) {
+	case "debug", "info", "warn", "error":
+		return nil
+	}
+	return []string{fmt.Sprintf("LOG_LEVEL: %q must be one of debug, info, warn or error", l.Level)}
+}
+
+func (s ServerConfig) validate() []string {
+	var errs []string
+	for _, d := range []struct {
+		name string
+		d    Duration
+	}{
+		{"SERVER_READ_TIMEOUT", s.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", s.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", s.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", s.ShutdownTimeout},
+	} {
+		if d.d.Duration <= 0 {
+			errs = append(errs, d.name+": must be positive")
+		}
+	}
+	return errs
+}
+
+func (a AuthConfig) validate() []string {
+	var errs []string
+	for _, f := range []struct{ name, file string }{
+		{"AUTH_HMAC_KEY_FILE", a.HMACKeyFile},
+		{"AUTH_RSA_PRIVATE_KEY_FILE", a.RSAPrivateKeyFile},
+		{"AUTH_RSA_PUBLIC_KEY_FILE", a.RSAPublicKeyFile},
+		{"AUTH_API_KEYS_FILE", a.APIKeysFile},
+	} {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.name, err))
+		}
+	}
+	if a.TokenTTL.Duration <= 0 {
+		errs = append(errs, "AUTH_TOKEN_TTL: must be positive")
+	}
+	return errs
+}
+
+func (db DatabaseConfig) validate() []string {
+	var errs []string
+	if len(db.Hosts) == 0 {
+		errs = append(errs, "COCKROACH_HOSTS: at least one host is required")
+	}
+	if db.Port < 1 || db.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("COCKROACH_PORT: %d is not a valid port", db.Port))
+	}
+	if db.User == "" {
+		errs = append(errs, "COCKROACH_USER: a user is required")
+	}
+	if db.Name == "" {
+		errs = append(errs, "COCKROACH_DB: a database name is required")
+	}
+
+	switch db.SSLMode {
+	case "disable", "require":
+	case "verify-ca", "verify-full":
+		if db.SSLRootCert == "" {
+			errs = append(errs, fmt.Sprintf("COCKROACH_SSLROOTCERT: a CA certificate is required for sslmode %s", db.SSLMode))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("COCKROACH_SSLMODE: %q is not one of disable, require, verify-ca or verify-full", db.SSLMode))
+	}
+	if (db.SSLCert == "") != (db.SSLKey == "") {
+		errs = append(errs, "COCKROACH_SSLCERT, COCKROACH_SSLKEY: a client certificate and key must be given together")
+	}
+	if db.SSLMode == "disable" && (db.SSLCert != "" || db.SSLRootCert != "") {
+		errs = append(errs, "COCKROACH_SSLMODE: certificates are configured but sslmode is disable")
+	}
+	for _, f := range []struct{ name, file string }{
+		{"COCKROACH_SSLROOTCERT", db.SSLRootCert},
+		{"COCKROACH_SSLCERT", db.SSLCert},
+		{"COCKROACH_SSLKEY", db.SSLKey},
+	} {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.name, err))
+		}
+	}
+
+	if db.MaxOpenConns < 0 {
+		errs = append(errs, "COCKROACH_MAX_OPEN_CONNS: must not be negative")
+	}
+	if db.MaxIdleConns < 0 {
+		errs = append(errs, "COCKROACH_MAX_IDLE_CONNS: must not be negative")
+	}
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		errs = append(errs, "COCKROACH_MAX_IDLE_CONNS: must not be more than COCKROACH_MAX_OPEN_CONNS")
+	}
+	if db.ConnMaxLifetime.Duration < 0 {
+		errs = append(errs, "COCKROACH_CONN_MAX_LIFETIME: must not be negative")
+	}
+	if db.ConnectTimeout.Duration < 0 {
+		errs = append(errs, "COCKROACH_CONNECT_TIMEOUT: must not be negative")
+	}
+	if db.StartupTimeout.Duration < 0 {
+		errs = append(errs, "COCKROACH_STARTUP_TIMEOUT: must not be negative")
+	}
+	if db.PingTimeout.Duration <= 0 {
+		errs = append(errs, "COCKROACH_PING_TIMEOUT: must be positive")
+	}
+	return errs
+}
+
+// DataSourceNames returns a lib/pq connection URL for each configured host.
+func (db DatabaseConfig) DataSourceNames() []string {
+	query := url.Values{}
+	query.Set("sslmode", db.SSLMode)
+	if db.SSLRootCert != "" {
+		query.Set("sslrootcert", db.SSLRootCert)
+	}
+	if db.SSLCert != "" {
+		query.Set("sslcert", db.SSLCert)
+		query.Set("sslkey", db.SSLKey)
+	}
+	if db.ApplicationName != "" {
+		query.Set("application_name", db.ApplicationName)
+	}
+	if db.ConnectTimeout.Duration > 0 {
+		// lib/pq takes whole seconds, rounded up so that a short timeout is not "wait forever"
+		query.Set("connect_timeout", strconv.Itoa(int((db.ConnectTimeout.Duration+time.Second-1)/time.Second)))
+	}
+
+	user := url.User(db.User)
+	if db.Password != "" {
+		user = url.UserPassword(db.User, db.Password)
+	}
+
+	var dsns []string
+	for _, host := range db.Hosts {
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     user,
+			Host:     net.JoinHostPort(host, strconv.Itoa(db.Port)),
+			Path:     "/" + db.Name,
+			RawQuery: query.Encode(),
+		}
+		dsns = append(dsns, u.String())
+	}
+	return dsns
+}
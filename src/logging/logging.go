@@ -0,0 +1,201 @@
+// Package logging writes leveled, structured logs as one JSON object per
+// line, and carries a logger (with the fields of a request) in a context.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+// The levels, from the least to the most severe.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q, must be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// output serializes the writes of a logger and the loggers derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Logger writes entries at or above its level, each with its fields.
+type Logger struct {
+	out    *output
+	level  Level
+	fields []interface{}
+}
+
+// New returns a Logger which writes entries at or above level to w.
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{out: &output{w: w}, level: level}
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultLogger = New(os.Stderr, Info)
+)
+
+// Default returns the logger used when no other is given, which writes
+// entries at or above Info to stderr unless replaced by SetDefault.
+func Default() *Logger {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the default logger.
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// With returns a logger which adds the key/value pairs to every entry.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return &Logger{out: l.out, level: l.level, fields: fields}
+}
+
+// Enabled reports whether entries at level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes an entry at Debug level, with the key/value pairs.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(Debug, msg, keyvals...)
+}
+
+// Info writes an entry at Info level, with the key/value pairs.
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(Info, msg, keyvals...)
+}
+
+// Warn writes an entry at Warn level, with the key/value pairs.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(Warn, msg, keyvals...)
+}
+
+// Error writes an entry at Error level, with the key/value pairs.
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(Error, msg, keyvals...)
+}
+
+// Log writes an entry with the time, level and message, then the fields
+// of the logger and the key/value pairs. Errors are written as their
+// message, and durations in milliseconds.
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeField(&b, "time", time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteByte(',')
+	writeField(&b, "level", level.String())
+	b.WriteByte(',')
+	writeField(&b, "msg", msg)
+	for _, kv := range [][]interface{}{l.fields, keyvals} {
+		for i := 0; i < len(kv); i += 2 {
+			key := fmt.Sprint(kv[i])
+			var value interface{} = "(missing)"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			b.WriteByte(',')
+			writeField(&b, key, value)
+		}
+	}
+	b.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+func writeField(b *bytes.Buffer, key string, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = float64(v) / float64(time.Millisecond)
+	case fmt.Stringer:
+		value = v.String()
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(v)
+}
+
+// Writer returns a writer which logs each line written to it as the
+// message of an entry at level, so that the standard log package can be
+// pointed at a Logger.
+func (l *Logger) Writer(level Level) io.Writer {
+	return lineWriter{l, level}
+}
+
+type lineWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.l.Log(w.level, line)
+	}
+	return len(p), nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying a logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by a context, or the default
+// logger if it carries none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return Default()
+}
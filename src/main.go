@@ -1,13 +1,76 @@
 package main
 
-import "os"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
 
-import "application"
+import (
+	"application"
+	"commands"
+	"config"
+	"logging"
+	"recipes"
+)
 
 func main() {
-	app := application.App{}
-	app.Initialize(
-		os.Getenv("COCKROACH_USER"),
-		os.Getenv("COCKROACH_DB"))
-	app.Run(os.Getenv("PORT"))
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [command]\n\n", os.Args[0])
+		commands.Usage(flag.CommandLine.Output())
+	}
+	flag.Parse()
+	args := flag.Args()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("cannot load the configuration", err)
+	}
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logging.SetDefault(logging.New(os.Stderr, level))
+	// whatever libraries log with the log package is logged as JSON too
+	log.SetFlags(0)
+	log.SetOutput(logging.Default().Writer(logging.Info))
+
+	if !commands.IsServe(args) {
+		os.Exit(runCommand(cfg, args))
+	}
+
+	app := application.App{AutoMigrate: cfg.AutoMigrate, MaxPageSize: cfg.MaxPageSize, TokenTTL: cfg.Auth.TokenTTL.Duration,
+		Server: cfg.Server, PingTimeout: cfg.Database.PingTimeout.Duration, RequireReadScope: !cfg.Auth.AnonymousReads,
+		StoreTimeout: cfg.Store.Timeout.Duration, StoreTimeouts: cfg.Store.TimeoutMap()}
+	app.Initialize(cfg)
+	if err := app.Run(cfg.Port); err != nil {
+		fatal("the server stopped", err)
+	}
+}
+
+// fatal logs the error which stopped the program and exits.
+func fatal(msg string, err error) {
+	logging.Default().Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// runCommand runs an administrative command against the configured
+// database, which is not migrated first, and returns the exit status.
+func runCommand(cfg config.Config, args []string) int {
+	db, err := application.OpenDB(cfg.Database)
+	if err != nil {
+		fatal("cannot connect to the database", err)
+	}
+	defer db.Close()
+	env := commands.Env{DB: db, In: os.Stdin, Out: os.Stdout, Err: os.Stderr,
+		Store: recipes.ObservedStore{Store: recipes.NewSQLStore(db),
+			Timeout: cfg.Store.Timeout.Duration, Timeouts: cfg.Store.TimeoutMap()}}
+	switch err := commands.Run(context.Background(), env, args); err {
+	case nil:
+		return 0
+	case commands.ErrUsage:
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
 }
@@ -0,0 +1,53 @@
+//go:build ignore
+// +build ignore
+
+// generate writes files.go, which holds the SQL files of the sql directory
+// so that the migrations are built into the program. It is run by go
+// generate whenever a file is added to the sql directory.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	names, err := filepath.Glob(filepath.Join("sql", "*.sql"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go run generate.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package migrations\n\n")
+	buf.WriteString("// files holds the SQL files of the sql directory, by name.\n")
+	buf.WriteString("var files = map[string]string{\n")
+	for _, name := range names {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// a raw string keeps the SQL readable, unless it holds a backquote
+		quoted := "`" + string(content) + "`"
+		if strings.Contains(string(content), "`") {
+			quoted = fmt.Sprintf("%q", content)
+		}
+		fmt.Fprintf(&buf, "%q: %s,\n", filepath.Base(name), quoted)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("files.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
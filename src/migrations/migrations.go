@@ -0,0 +1,267 @@
+// Package migrations holds the versioned schema for the recipes database
+// and applies it, keeping track of what has been run in schema_migrations.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:generate go run generate.go
+
+// Migration is a single versioned schema change, read from the files
+// sql/<version>_<name>.up.sql and sql/<version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	// Up and Down are the statements of the change and of its rollback,
+	// each run on its own within the transaction of the migration
+	Up   []string
+	Down []string
+}
+
+// Status reports whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+const migrationsTableCreationQuery = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+	version INT NOT NULL,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT now(),
+	CONSTRAINT schema_migrations_pkey PRIMARY KEY (version)
+)`
+
+var registry []Migration
+
+func init() {
+	byVersion := map[int]*Migration{}
+	for file, content := range files {
+		var version int
+		var rest string
+		if _, err := fmt.Sscanf(file, "%04d_%s", &version, &rest); err != nil || version < 1 {
+			panic(fmt.Sprintf("migrations: %s is not named <version>_<name>.up.sql or .down.sql", file))
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+		var name string
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = statements(content)
+		case strings.HasSuffix(rest, ".down.sql"):
+			name = strings.TrimSuffix(rest, ".down.sql")
+			m.Down = statements(content)
+		default:
+			panic(fmt.Sprintf("migrations: %s is neither an up nor a down migration", file))
+		}
+		if m.Name != "" && m.Name != name {
+			panic(fmt.Sprintf("migrations: duplicate version %d (%s, %s)", version, m.Name, name))
+		}
+		m.Name = name
+	}
+	for _, m := range byVersion {
+		if len(m.Up) == 0 || len(m.Down) == 0 {
+			panic(fmt.Sprintf("migrations: %04d_%s needs both an up and a down file", m.Version, m.Name))
+		}
+		registry = append(registry, *m)
+	}
+	sort.Slice(registry, func(i, j int) bool { return registry[i].Version < registry[j].Version })
+}
+
+// statements splits the content of a SQL file into its statements, which
+// end with a semicolon at the end of a line. Comments are kept with the
+// statement which follows them.
+func statements(content string) []string {
+	var stmts []string
+	var stmt []string
+	code := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" && len(stmt) == 0 {
+			continue
+		}
+		stmt = append(stmt, line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			code = true
+		}
+		if strings.HasSuffix(trimmed, ";") {
+			if code {
+				s := strings.TrimSpace(strings.Join(stmt, "\n"))
+				stmts = append(stmts, strings.TrimSuffix(s, ";"))
+			}
+			stmt, code = nil, false
+		}
+	}
+	if code {
+		stmts = append(stmts, strings.TrimSpace(strings.Join(stmt, "\n")))
+	}
+	return stmts
+}
+
+// All returns every known migration, ordered by version.
+func All() []Migration {
+	all := make([]Migration, len(registry))
+	copy(all, registry)
+	return all
+}
+
+// Latest returns the highest known migration version.
+func Latest() int {
+	if len(registry) == 0 {
+		return 0
+	}
+	return registry[len(registry)-1].Version
+}
+
+// Up applies all pending migrations in version order and returns the ones applied.
+func Up(db *sql.DB) ([]Migration, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	for _, m := range registry {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := run(db, m.Up, "INSERT INTO schema_migrations(version, name) VALUES($1, $2)", m.Version, m.Name); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down rolls back the most recently applied migrations, at most steps of them,
+// and returns the ones rolled back.
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	for i := len(registry) - 1; i >= 0 && len(done) < steps; i-- {
+		m := registry[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := run(db, m.Down, "DELETE FROM schema_migrations WHERE version=$1", m.Version); err != nil {
+			return done, fmt.Errorf("rollback of migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// GetStatus returns every known migration along with whether it has been applied.
+func GetStatus(db *sql.DB) ([]Status, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, m := range registry {
+		at, ok := applied[m.Version]
+		statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Pending returns the known migrations which have not been applied, in version order.
+func Pending(db *sql.DB) ([]Migration, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	return pendingOf(applied), nil
+}
+
+// PendingContext is Pending for health checks, which must neither write
+// nor outlast ctx: it does not create the bookkeeping table, and if there
+// is none every migration is pending.
+func PendingContext(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	applied, err := readApplied(ctx, db)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" {
+		// undefined_table
+		applied, err = map[int]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pendingOf(applied), nil
+}
+
+// pendingOf returns the known migrations which are not among applied.
+func pendingOf(applied map[int]time.Time) []Migration {
+	pending := []Migration{}
+	for _, m := range registry {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// appliedVersions creates the bookkeeping table if needed and
+// returns the applied versions mapped to when they were applied.
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	if _, err := db.Exec(migrationsTableCreationQuery); err != nil {
+		return nil, err
+	}
+	return readApplied(context.Background(), db)
+}
+
+// readApplied returns the applied versions mapped to when they were applied.
+func readApplied(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes the statements of a schema change, one at a time, and its
+// bookkeeping in a single transaction, so that a migration which fails
+// part way is not recorded and leaves none of its statements applied.
+func run(db *sql.DB, schema []string, bookkeeping string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range schema {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
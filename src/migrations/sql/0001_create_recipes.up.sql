@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS recipes
+(
+    id BIGSERIAL,
+    name TEXT NOT NULL,
+    preptime FLOAT(4) NOT NULL DEFAULT 0.0,
+    difficulty NUMERIC(1) NOT NULL CHECK (difficulty > 0) CHECK (difficulty < 4) DEFAULT 0,
+    vegetarian BOOLEAN NOT NULL DEFAULT false,
+    CONSTRAINT recipes_pkey PRIMARY KEY (id)
+);
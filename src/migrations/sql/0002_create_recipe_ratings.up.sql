@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS recipe_ratings
+(
+    recipe_id BIGINT REFERENCES recipes(id) ON DELETE CASCADE,
+    rating_id BIGSERIAL,
+    rating SMALLINT NOT NULL CHECK (rating > 0) CHECK (rating < 6) DEFAULT 0,
+    PRIMARY KEY (recipe_id, rating_id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS ingredients
+(
+    recipe_id BIGINT REFERENCES recipes(id) ON DELETE CASCADE,
+    ingredient_id BIGSERIAL,
+    name TEXT NOT NULL,
+    quantity FLOAT(4) NOT NULL CHECK (quantity >= 0) DEFAULT 0.0,
+    unit TEXT NOT NULL DEFAULT '',
+    notes TEXT,
+    PRIMARY KEY (recipe_id, ingredient_id)
+);
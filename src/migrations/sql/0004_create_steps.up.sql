@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS steps
+(
+    recipe_id BIGINT REFERENCES recipes(id) ON DELETE CASCADE,
+    step_id BIGSERIAL,
+    position INT NOT NULL CHECK (position > 0),
+    text TEXT NOT NULL,
+    duration FLOAT(4) CHECK (duration >= 0),
+    timer INT CHECK (timer > 0),
+    PRIMARY KEY (recipe_id, step_id)
+);
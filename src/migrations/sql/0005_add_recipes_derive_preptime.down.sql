@@ -0,0 +1 @@
+ALTER TABLE recipes DROP COLUMN derive_preptime;
@@ -0,0 +1 @@
+ALTER TABLE recipes ADD COLUMN derive_preptime BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS recipe_rating_stats
+(
+    recipe_id BIGINT REFERENCES recipes(id) ON DELETE CASCADE,
+    count INT NOT NULL CHECK (count >= 0) DEFAULT 0,
+    sum INT NOT NULL CHECK (sum >= 0) DEFAULT 0,
+    avg_rating FLOAT NOT NULL DEFAULT 0.0,
+    stars_1 INT NOT NULL CHECK (stars_1 >= 0) DEFAULT 0,
+    stars_2 INT NOT NULL CHECK (stars_2 >= 0) DEFAULT 0,
+    stars_3 INT NOT NULL CHECK (stars_3 >= 0) DEFAULT 0,
+    stars_4 INT NOT NULL CHECK (stars_4 >= 0) DEFAULT 0,
+    stars_5 INT NOT NULL CHECK (stars_5 >= 0) DEFAULT 0,
+    PRIMARY KEY (recipe_id),
+    INDEX recipe_rating_stats_avg_rating_idx (avg_rating)
+);
+
+-- count the ratings made before the statistics were kept
+INSERT INTO recipe_rating_stats(recipe_id, count, sum, avg_rating, stars_1, stars_2, stars_3, stars_4, stars_5)
+SELECT recipe_id, count(*), sum(rating), CAST(avg(rating) AS FLOAT),
+    sum(CASE WHEN rating = 1 THEN 1 ELSE 0 END), sum(CASE WHEN rating = 2 THEN 1 ELSE 0 END),
+    sum(CASE WHEN rating = 3 THEN 1 ELSE 0 END), sum(CASE WHEN rating = 4 THEN 1 ELSE 0 END),
+    sum(CASE WHEN rating = 5 THEN 1 ELSE 0 END)
+FROM recipe_ratings GROUP BY recipe_id;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    user_id BIGSERIAL,
+    username TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id),
+    UNIQUE INDEX users_username_key (username)
+);
@@ -0,0 +1 @@
+ALTER TABLE recipe_ratings DROP COLUMN user_id;
@@ -0,0 +1,2 @@
+-- ratings made before there were users have no user_id
+ALTER TABLE recipe_ratings ADD COLUMN user_id BIGINT;
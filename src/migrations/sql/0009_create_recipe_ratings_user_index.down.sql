@@ -0,0 +1 @@
+DROP INDEX IF EXISTS recipe_ratings@recipe_ratings_recipe_id_user_id_key;
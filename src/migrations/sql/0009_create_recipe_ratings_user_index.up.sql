@@ -0,0 +1,2 @@
+-- a user may only rate a recipe once
+CREATE UNIQUE INDEX IF NOT EXISTS recipe_ratings_recipe_id_user_id_key ON recipe_ratings (recipe_id, user_id);
@@ -0,0 +1,2 @@
+-- recipes created before there were owners have no owner_id
+ALTER TABLE recipes ADD COLUMN owner_id BIGINT;
@@ -0,0 +1,2 @@
+-- existing users keep the ability to create recipes they had before roles
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'author';
@@ -0,0 +1,11 @@
+-- the search index: a row for each distinct word of a recipe's name, as
+-- split and stemmed by the recipes package, along with the number of words
+-- in the name; recipes created before it are indexed by recipes reindex
+CREATE TABLE IF NOT EXISTS recipe_words
+(
+    word TEXT NOT NULL,
+    recipe_id BIGINT NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    name_words INT NOT NULL CHECK (name_words > 0),
+    PRIMARY KEY (word, recipe_id),
+    INDEX recipe_words_recipe_id_idx (recipe_id)
+);
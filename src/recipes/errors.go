@@ -0,0 +1,80 @@
+package recipes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is returned when the recipe, or the ingredient, step or rating of
+// the recipe, being read or written does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrStepOrder is returned by ReorderSteps when the new order does not
+// list every step of the recipe exactly once.
+var ErrStepOrder = errors.New("the step order must list every step of the recipe exactly once")
+
+// ErrConflict is returned when a user is registered with a username which
+// is already taken.
+var ErrConflict = errors.New("conflict")
+
+// ErrInvalidCursor is returned when a page cursor is malformed, or belongs
+// to a listing with a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrPatchRejected is returned by the patch function given to PatchRecipe
+// when the patch cannot be applied, such as when it is invalid or one of
+// its tests fails; that is the client's mistake rather than the store's.
+var ErrPatchRejected = errors.New("the patch was rejected")
+
+// notFound translates the database errors for a missing row into ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	// foreign_key_violation, the recipe was deleted during the transaction
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	return err
+}
+
+// IsConflict reports whether err is ErrConflict or a unique constraint violation.
+func IsConflict(err error) bool {
+	if err == ErrConflict {
+		return true
+	}
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// IsConstraintViolation reports whether err is a CHECK or NOT NULL
+// constraint violation, that is, whether the data written was invalid.
+func IsConstraintViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && (pqErr.Code == "23514" || pqErr.Code == "23502")
+}
+
+// IsRejection reports whether err means that the store turned down what
+// it was asked to do, because of what it was given, rather than failing.
+func IsRejection(err error) bool {
+	return err == ErrInvalidCursor || err == ErrStepOrder || err == ErrPatchRejected ||
+		IsConflict(err) || IsConstraintViolation(err)
+}
+
+// IsUnavailable reports whether err means that the database could not be
+// reached, or is shutting down or starting up.
+func IsUnavailable(err error) bool {
+	if err == driver.ErrBadConn {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	pqErr, ok := err.(*pq.Error)
+	// class 08 is connection exception, class 57 includes admin_shutdown and cannot_connect_now
+	return ok && (pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P03")
+}
@@ -0,0 +1,82 @@
+package recipes
+
+import "context"
+
+// GetIngredients returns the ingredients of a specific recipe.
+func (s *MemoryStore) GetIngredients(ctx context.Context, recipeID int) ([]Ingredient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.recipes[recipeID]; !ok {
+		return nil, ErrNotFound
+	}
+	return s.copyIngredients(recipeID), nil
+}
+
+// GetIngredient returns a single specified ingredient.
+func (s *MemoryStore) GetIngredient(ctx context.Context, i *Ingredient) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := s.findIngredient(i)
+	if n < 0 {
+		return ErrNotFound
+	}
+	*i = s.ingredients[i.RecipeID][n]
+	return nil
+}
+
+// AddIngredient adds an ingredient to a specific recipe.
+func (s *MemoryStore) AddIngredient(ctx context.Context, i *Ingredient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[i.RecipeID]; !ok {
+		return ErrNotFound
+	}
+	s.lastIngredientID++
+	i.ID = s.lastIngredientID
+	s.ingredients[i.RecipeID] = append(s.ingredients[i.RecipeID], *i)
+	return nil
+}
+
+// UpdateIngredient is used to modify a specific ingredient.
+func (s *MemoryStore) UpdateIngredient(ctx context.Context, i *Ingredient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.findIngredient(i)
+	if n < 0 {
+		return ErrNotFound
+	}
+	s.ingredients[i.RecipeID][n] = *i
+	return nil
+}
+
+// DeleteIngredient is used to delete a specific ingredient.
+func (s *MemoryStore) DeleteIngredient(ctx context.Context, i *Ingredient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.findIngredient(i)
+	if n < 0 {
+		return ErrNotFound
+	}
+	ingredients := s.ingredients[i.RecipeID]
+	s.ingredients[i.RecipeID] = append(ingredients[:n:n], ingredients[n+1:]...)
+	return nil
+}
+
+// findIngredient returns the index of the ingredient within its recipe,
+// or -1 if there is no such ingredient; the caller must hold the lock.
+func (s *MemoryStore) findIngredient(i *Ingredient) int {
+	for n, found := range s.ingredients[i.RecipeID] {
+		if found.ID == i.ID {
+			return n
+		}
+	}
+	return -1
+}
+
+// copyIngredients returns a copy of the ingredients of a recipe;
+// the caller must hold the lock.
+func (s *MemoryStore) copyIngredients(recipeID int) []Ingredient {
+	ingredients := make([]Ingredient, len(s.ingredients[recipeID]))
+	copy(ingredients, s.ingredients[recipeID])
+	return ingredients
+}
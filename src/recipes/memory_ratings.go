@@ -0,0 +1,110 @@
+package recipes
+
+import "context"
+
+// AddRecipeRating adds or replaces a rating for a specific recipe.
+func (s *MemoryStore) AddRecipeRating(ctx context.Context, rr *RecipeRating) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[rr.RecipeID]; !ok {
+		return false, ErrNotFound
+	}
+	if n := s.findUserRating(rr); n >= 0 {
+		previous := &s.ratings[rr.RecipeID][n]
+		s.adjustRatingStats(rr.RecipeID, previous.Rating, -1)
+		rr.ID = previous.ID
+		*previous = *rr
+		s.adjustRatingStats(rr.RecipeID, rr.Rating, 1)
+		return false, nil
+	}
+	s.lastRatingID++
+	rr.ID = s.lastRatingID
+	s.ratings[rr.RecipeID] = append(s.ratings[rr.RecipeID], *rr)
+	s.adjustRatingStats(rr.RecipeID, rr.Rating, 1)
+	return true, nil
+}
+
+// WithdrawRecipeRating is used to delete a user's rating of a specific recipe.
+func (s *MemoryStore) WithdrawRecipeRating(ctx context.Context, rr *RecipeRating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.findUserRating(rr)
+	if n < 0 {
+		return ErrNotFound
+	}
+	ratings := s.ratings[rr.RecipeID]
+	rr.ID, rr.Rating = ratings[n].ID, ratings[n].Rating
+	s.ratings[rr.RecipeID] = append(ratings[:n:n], ratings[n+1:]...)
+	s.adjustRatingStats(rr.RecipeID, rr.Rating, -1)
+	return nil
+}
+
+// DeleteRecipeRating is used to delete a specific rating.
+func (s *MemoryStore) DeleteRecipeRating(ctx context.Context, rr *RecipeRating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ratings := s.ratings[rr.RecipeID]
+	for n := range ratings {
+		if ratings[n].ID == rr.ID {
+			rr.UserID, rr.Rating = ratings[n].UserID, ratings[n].Rating
+			s.ratings[rr.RecipeID] = append(ratings[:n:n], ratings[n+1:]...)
+			s.adjustRatingStats(rr.RecipeID, rr.Rating, -1)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// GetRatingStats returns the rating statistics of a specific recipe.
+func (s *MemoryStore) GetRatingStats(ctx context.Context, recipeID int) (RatingStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.recipes[recipeID]; !ok {
+		return RatingStats{}, ErrNotFound
+	}
+	st := s.ratingStats[recipeID]
+	st.RecipeID = recipeID
+	return st, nil
+}
+
+// RecomputeRatingStats rebuilds the rating statistics from the ratings.
+func (s *MemoryStore) RecomputeRatingStats(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratingStats = map[int]RatingStats{}
+	for recipeID, ratings := range s.ratings {
+		for _, rr := range ratings {
+			s.adjustRatingStats(recipeID, rr.Rating, 1)
+		}
+	}
+	return len(s.ratingStats), nil
+}
+
+// adjustRatingStats updates the rating statistics of a recipe for a rating
+// being added (delta 1) or deleted (delta -1); the caller must hold the lock.
+func (s *MemoryStore) adjustRatingStats(recipeID int, rating int, delta int) {
+	st := s.ratingStats[recipeID]
+	st.RecipeID = recipeID
+	st.Count += delta
+	st.Sum += rating * delta
+	st.Histogram[rating-1] += delta
+	st.AvgRating = 0
+	if st.Count > 0 {
+		st.AvgRating = float32(st.Sum) / float32(st.Count)
+	}
+	s.ratingStats[recipeID] = st
+}
+
+// findUserRating returns the index of the rating of recipe rr.RecipeID by
+// user rr.UserID, or -1 if there is none; the caller must hold the lock.
+func (s *MemoryStore) findUserRating(rr *RecipeRating) int {
+	if rr.UserID == 0 {
+		return -1
+	}
+	for n, existing := range s.ratings[rr.RecipeID] {
+		if existing.UserID == rr.UserID {
+			return n
+		}
+	}
+	return -1
+}
@@ -0,0 +1,119 @@
+package recipes
+
+import "context"
+
+// GetSteps returns the steps of a specific recipe.
+func (s *MemoryStore) GetSteps(ctx context.Context, recipeID int) ([]Step, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.recipes[recipeID]; !ok {
+		return nil, ErrNotFound
+	}
+	return s.copySteps(recipeID), nil
+}
+
+// AddStep appends a step to a specific recipe.
+func (s *MemoryStore) AddStep(ctx context.Context, st *Step) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[st.RecipeID]; !ok {
+		return ErrNotFound
+	}
+	s.lastStepID++
+	st.ID = s.lastStepID
+	st.Position = len(s.steps[st.RecipeID]) + 1
+	s.steps[st.RecipeID] = append(s.steps[st.RecipeID], *st)
+	s.refreshPrepTime(st.RecipeID)
+	return nil
+}
+
+// UpdateStep is used to modify a specific step.
+func (s *MemoryStore) UpdateStep(ctx context.Context, st *Step) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.findStep(st)
+	if n < 0 {
+		return ErrNotFound
+	}
+	st.Position = n + 1
+	s.steps[st.RecipeID][n] = *st
+	s.refreshPrepTime(st.RecipeID)
+	return nil
+}
+
+// DeleteStep is used to delete a specific step.
+func (s *MemoryStore) DeleteStep(ctx context.Context, st *Step) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.findStep(st)
+	if n < 0 {
+		return ErrNotFound
+	}
+	st.Position = n + 1
+	steps := append(s.steps[st.RecipeID][:n:n], s.steps[st.RecipeID][n+1:]...)
+	for i := range steps {
+		steps[i].Position = i + 1
+	}
+	s.steps[st.RecipeID] = steps
+	s.refreshPrepTime(st.RecipeID)
+	return nil
+}
+
+// ReorderSteps is used to change the order of the steps of a specific recipe.
+func (s *MemoryStore) ReorderSteps(ctx context.Context, recipeID int, stepIDs []int) ([]Step, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[recipeID]; !ok {
+		return nil, ErrNotFound
+	}
+	current := s.steps[recipeID]
+	if !isPermutation(current, stepIDs) {
+		return nil, ErrStepOrder
+	}
+	byID := map[int]Step{}
+	for _, st := range current {
+		byID[st.ID] = st
+	}
+	steps := make([]Step, len(stepIDs))
+	for n, id := range stepIDs {
+		steps[n] = byID[id]
+		steps[n].Position = n + 1
+	}
+	s.steps[recipeID] = steps
+	return s.copySteps(recipeID), nil
+}
+
+// findStep returns the index of the step within its recipe,
+// or -1 if there is no such step; the caller must hold the lock.
+func (s *MemoryStore) findStep(st *Step) int {
+	for n, found := range s.steps[st.RecipeID] {
+		if found.ID == st.ID {
+			return n
+		}
+	}
+	return -1
+}
+
+// copySteps returns a copy of the steps of a recipe;
+// the caller must hold the lock.
+func (s *MemoryStore) copySteps(recipeID int) []Step {
+	steps := make([]Step, len(s.steps[recipeID]))
+	copy(steps, s.steps[recipeID])
+	return steps
+}
+
+// refreshPrepTime recalculates the preparation time of a recipe if it is
+// derived from the step durations; the caller must hold the lock.
+func (s *MemoryStore) refreshPrepTime(recipeID int) {
+	r, ok := s.recipes[recipeID]
+	if !ok || !r.DerivePrepTime {
+		return
+	}
+	r.PrepTime = 0
+	for _, st := range s.steps[recipeID] {
+		if st.Duration != nil {
+			r.PrepTime += *st.Duration
+		}
+	}
+	s.recipes[recipeID] = r
+}
@@ -0,0 +1,256 @@
+package recipes
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a goroutine-safe, in-memory RecipeStore.
+// It mirrors the behaviour of SQLStore and is intended for testing.
+type MemoryStore struct {
+	mu               sync.RWMutex
+	recipes          map[int]Recipe
+	ingredients      map[int][]Ingredient
+	steps            map[int][]Step
+	ratings          map[int][]RecipeRating
+	ratingStats      map[int]RatingStats
+	users            map[int]User
+	lastRecipeID     int
+	lastIngredientID int
+	lastStepID       int
+	lastRatingID     int
+	lastUserID       int
+}
+
+// NewMemoryStore returns an empty in-memory RecipeStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		recipes:     map[int]Recipe{},
+		ingredients: map[int][]Ingredient{},
+		steps:       map[int][]Step{},
+		ratings:     map[int][]RecipeRating{},
+		ratingStats: map[int]RatingStats{},
+		users:       map[int]User{},
+	}
+}
+
+// GetRecipe returns a single specified recipe.
+func (s *MemoryStore) GetRecipe(ctx context.Context, r *Recipe) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, ok := s.recipes[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	*r = found
+	r.Ingredients = s.copyIngredients(r.ID)
+	r.Steps = s.copySteps(r.ID)
+	return nil
+}
+
+// GetRecipeOwner returns the owner of a specified recipe.
+func (s *MemoryStore) GetRecipeOwner(ctx context.Context, recipeID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, ok := s.recipes[recipeID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return found.OwnerID, nil
+}
+
+// UpdateRecipe is used to modify a specific recipe.
+func (s *MemoryStore) UpdateRecipe(ctx context.Context, r *Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.recipes[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	r.OwnerID = existing.OwnerID
+	s.storeRecipe(*r)
+	r.PrepTime = s.recipes[r.ID].PrepTime
+	r.Ingredients = s.copyIngredients(r.ID)
+	r.Steps = s.copySteps(r.ID)
+	return nil
+}
+
+// PatchRecipe is used to change a specific recipe based on what it holds.
+func (s *MemoryStore) PatchRecipe(ctx context.Context, r *Recipe, patch func(r *Recipe) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.recipes[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	*r = existing
+	r.Ingredients = s.copyIngredients(r.ID)
+	r.Steps = s.copySteps(r.ID)
+	if err := patch(r); err != nil {
+		return err
+	}
+	r.ID, r.OwnerID = existing.ID, existing.OwnerID
+	s.storeRecipe(*r)
+	r.PrepTime = s.recipes[r.ID].PrepTime
+	r.Ingredients = s.copyIngredients(r.ID)
+	r.Steps = s.copySteps(r.ID)
+	return nil
+}
+
+// DeleteRecipe is used to delete a specific recipe.
+func (s *MemoryStore) DeleteRecipe(ctx context.Context, r *Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[r.ID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.recipes, r.ID)
+	delete(s.ingredients, r.ID)
+	delete(s.steps, r.ID)
+	delete(s.ratings, r.ID)
+	delete(s.ratingStats, r.ID)
+	return nil
+}
+
+// CreateRecipe is used to create a single recipe.
+func (s *MemoryStore) CreateRecipe(ctx context.Context, r *Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRecipeID++
+	r.ID = s.lastRecipeID
+	for i := range r.Ingredients {
+		r.Ingredients[i].RecipeID = r.ID
+		s.lastIngredientID++
+		r.Ingredients[i].ID = s.lastIngredientID
+		s.ingredients[r.ID] = append(s.ingredients[r.ID], r.Ingredients[i])
+	}
+	for i := range r.Steps {
+		r.Steps[i].RecipeID = r.ID
+		r.Steps[i].Position = i + 1
+		s.lastStepID++
+		r.Steps[i].ID = s.lastStepID
+		s.steps[r.ID] = append(s.steps[r.ID], r.Steps[i])
+	}
+	s.storeRecipe(*r)
+	r.PrepTime = s.recipes[r.ID].PrepTime
+	return nil
+}
+
+// GetRecipes returns a collection of known recipes.
+func (s *MemoryStore) GetRecipes(ctx context.Context, p Page) ([]Recipe, PageInfo, error) {
+	if err := p.checkSort(""); err != nil {
+		return nil, PageInfo{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := s.sortedIDs()
+	i := 0
+	if p.backward() {
+		i = sort.SearchInts(ids, p.Cursor.ID)
+	} else if p.Cursor != nil {
+		i = sort.SearchInts(ids, p.Cursor.ID+1)
+	}
+	lo, hi, more := pageBounds(p, len(ids), i)
+	recipes := []Recipe{}
+	for _, id := range ids[lo:hi] {
+		recipes = append(recipes, s.recipes[id])
+	}
+	var first, last *Cursor
+	if len(recipes) > 0 {
+		first, last = &Cursor{ID: recipes[0].ID}, &Cursor{ID: recipes[len(recipes)-1].ID}
+	}
+	return recipes, pageInfo(p, first, last, more), nil
+}
+
+// GetRecipesRated returns a collection of rated recipes.
+func (s *MemoryStore) GetRecipesRated(ctx context.Context, rs RecipeSearch, p Page) ([]RecipeRated, PageInfo, error) {
+	if err := p.checkSort(rs.order()); err != nil {
+		return nil, PageInfo{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recipesRated := []RecipeRated{}
+	for _, id := range s.sortedIDs() {
+		r := s.recipes[id]
+		rated := RecipeRated{
+			ID:          r.ID,
+			Name:        r.Name,
+			PrepTime:    r.PrepTime,
+			Difficulty:  r.Difficulty,
+			Vegetarian:  r.Vegetarian,
+			AvgRating:   s.ratingStats[id].AvgRating,
+			RatingCount: s.ratingStats[id].Count,
+		}
+		var ingredients []string
+		for _, i := range s.ingredients[id] {
+			ingredients = append(ingredients, i.Name)
+		}
+		if rs.matches(&rated, ingredients) {
+			recipesRated = append(recipesRated, rated)
+		}
+	}
+	sort.Slice(recipesRated, func(i, j int) bool {
+		return rs.less(recipesRated[i], recipesRated[j])
+	})
+
+	i := 0
+	if p.Cursor != nil {
+		pos := rs.position(*p.Cursor)
+		if p.backward() {
+			i = sort.Search(len(recipesRated), func(j int) bool { return !rs.less(recipesRated[j], pos) })
+		} else {
+			i = sort.Search(len(recipesRated), func(j int) bool { return rs.less(pos, recipesRated[j]) })
+		}
+	}
+	lo, hi, more := pageBounds(p, len(recipesRated), i)
+	recipesRated = recipesRated[lo:hi]
+	var first, last *Cursor
+	if len(recipesRated) > 0 {
+		first, last = rs.cursor(recipesRated[0]), rs.cursor(recipesRated[len(recipesRated)-1])
+	}
+	return recipesRated, pageInfo(p, first, last, more), nil
+}
+
+// ReindexRecipes rebuilds the search index from the names of the recipes.
+// The names are indexed as they are searched, so there is nothing to do.
+func (s *MemoryStore) ReindexRecipes(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.recipes), nil
+}
+
+// storeRecipe saves a recipe without its ingredients and steps, deriving its
+// preparation time if need be; the caller must hold the lock.
+func (s *MemoryStore) storeRecipe(r Recipe) {
+	r.Ingredients = nil
+	r.Steps = nil
+	s.recipes[r.ID] = r
+	s.refreshPrepTime(r.ID)
+}
+
+// sortedIDs returns the recipe IDs in ascending order; the caller must hold the lock.
+func (s *MemoryStore) sortedIDs() []int {
+	ids := make([]int, 0, len(s.recipes))
+	for id := range s.recipes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// pageBounds returns the bounds of a page of n sorted items, where the
+// page's cursor falls just before item i, and whether there are more items
+// beyond the page in the direction it is read.
+func pageBounds(p Page, n int, i int) (int, int, bool) {
+	if p.backward() {
+		if i <= p.Limit {
+			return 0, i, false
+		}
+		return i - p.Limit, i, true
+	}
+	if n-i <= p.Limit {
+		return i, n, false
+	}
+	return i, i + p.Limit, true
+}
@@ -0,0 +1,64 @@
+package recipes
+
+import (
+	"context"
+	"time"
+)
+
+// CreateUser is used to register a new user.
+func (s *MemoryStore) CreateUser(ctx context.Context, u *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.users {
+		if existing.Username == u.Username {
+			return ErrConflict
+		}
+	}
+	if u.Role == "" {
+		u.Role = DefaultRole
+	}
+	s.lastUserID++
+	u.ID = s.lastUserID
+	u.CreatedAt = time.Now().UTC()
+	s.users[u.ID] = *u
+	return nil
+}
+
+// GetUser returns a single specified user.
+func (s *MemoryStore) GetUser(ctx context.Context, u *User) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, ok := s.users[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	*u = found
+	return nil
+}
+
+// GetUserByName returns the user with a specified username.
+func (s *MemoryStore) GetUserByName(ctx context.Context, u *User) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, found := range s.users {
+		if found.Username == u.Username {
+			*u = found
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SetUserRole is used to change the role of a specific user.
+func (s *MemoryStore) SetUserRole(ctx context.Context, u *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found, ok := s.users[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	found.Role = u.Role
+	s.users[u.ID] = found
+	*u = found
+	return nil
+}
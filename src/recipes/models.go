@@ -1,122 +1,118 @@
 package recipes
 
-import "database/sql"
+import "time"
 
 // The Recipe entity is used to marshall/unmarshall JSON.
 type Recipe struct {
 	ID         int     `json:"id"`
-	Name       string  `json:"name"`
-	PrepTime   float32 `json:"preptime"`
-	Difficulty int     `json:"difficulty"`
+	Name       string  `json:"name" validate:"required,maxlen=200"`
+	PrepTime   float32 `json:"preptime" validate:"min=0"`
+	Difficulty int     `json:"difficulty" validate:"min=1,max=3"`
 	Vegetarian bool    `json:"vegetarian"`
+	// OwnerID is the user who created the recipe, and is set by the
+	// service rather than the client; recipes created before there were
+	// owners, or by API keys, have none
+	OwnerID int `json:"owner_id,omitempty"`
+
+	// DerivePrepTime sets PrepTime to the sum of the step durations
+	DerivePrepTime bool `json:"derive_preptime"`
+
+	Ingredients []Ingredient `json:"ingredients,omitempty" validate:"maxlen=100"`
+	Steps       []Step       `json:"steps,omitempty" validate:"maxlen=100"`
+}
+
+// The Ingredient entity is used to marshall/unmarshall JSON.
+type Ingredient struct {
+	ID       int     `json:"ingredient_id"`
+	RecipeID int     `json:"recipe_id"`
+	Name     string  `json:"name" validate:"required,maxlen=100"`
+	Quantity float32 `json:"quantity" validate:"min=0"`
+	Unit     string  `json:"unit" validate:"maxlen=20"`
+	Notes    string  `json:"notes,omitempty" validate:"maxlen=500"`
+}
+
+// The Step entity is used to marshall/unmarshall JSON.
+// Steps are numbered from 1 in the order they should be followed.
+type Step struct {
+	ID       int    `json:"step_id"`
+	RecipeID int    `json:"recipe_id"`
+	Position int    `json:"position"`
+	Text     string `json:"text" validate:"required,maxlen=2000"`
+	// Duration is optional, and in the same units as Recipe.PrepTime
+	Duration *float32 `json:"duration,omitempty" validate:"min=0"`
+	// Timer is optional, a kitchen timer to set in seconds
+	Timer *int `json:"timer,omitempty" validate:"min=1"`
 }
 
 // The RecipeRated entity is used to marshall/unmarshall JSON.
 type RecipeRated struct {
-	ID         int     `json:"id"`
-	Name       string  `json:"name"`
-	PrepTime   float32 `json:"preptime"`
-	Difficulty int     `json:"difficulty"`
-	Vegetarian bool    `json:"vegetarian"`
-	AvgRating  float32 `json:"avg_rating"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	PrepTime    float32 `json:"preptime"`
+	Difficulty  int     `json:"difficulty"`
+	Vegetarian  bool    `json:"vegetarian"`
+	AvgRating   float32 `json:"avg_rating"`
+	RatingCount int     `json:"rating_count"`
+	// Relevance is how well the name matches the query of a search, from
+	// 0 to 1; it is only set when there is a query
+	Relevance float32 `json:"relevance,omitempty"`
 }
 
 // The RecipeRating entity is used to marshall/unmarshall JSON.
+// Each user may rate a recipe once; ratings made before there were users
+// have no UserID.
 type RecipeRating struct {
 	ID       int `json:"rating_id"`
 	RecipeID int `json:"recipe_id"`
-	Rating   int `json:"rating"`
-}
-
-// GetRecipe returns a single specified recipe.
-func (r *Recipe) GetRecipe(db *sql.DB) error {
-	return db.QueryRow("SELECT name, preptime, difficulty, vegetarian FROM recipes WHERE id=$1",
-		r.ID).Scan(&r.Name, &r.PrepTime, &r.Difficulty, &r.Vegetarian)
-}
-
-// UpdateRecipe is used to modify a specific recipe.
-func (r *Recipe) UpdateRecipe(db *sql.DB) (res sql.Result, err error) {
-	res, err = db.Exec("UPDATE recipes SET name=$1, preptime=$2, difficulty=$3, vegetarian=$4 WHERE id=$5",
-		r.Name, r.PrepTime, r.Difficulty, r.Vegetarian, r.ID)
-	return res, err
+	UserID   int `json:"user_id,omitempty"`
+	Rating   int `json:"rating" validate:"min=1,max=5"`
 }
 
-// DeleteRecipe is used to delete a specific recipe.
-func (r *Recipe) DeleteRecipe(db *sql.DB) (res sql.Result, err error) {
-	res, err = db.Exec("DELETE FROM recipes WHERE id=$1", r.ID)
-	return res, err
+// The RatingStats entity is used to marshall/unmarshall JSON.
+// It summarises the ratings of a recipe, and is kept up to date
+// as ratings are added and deleted.
+type RatingStats struct {
+	RecipeID  int     `json:"recipe_id"`
+	Count     int     `json:"count"`
+	Sum       int     `json:"sum"`
+	AvgRating float32 `json:"avg_rating"`
+	// Histogram counts the ratings of 1 to 5 stars
+	Histogram [5]int `json:"histogram"`
 }
 
-// CreateRecipe is used to create a single recipe.
-func (r *Recipe) CreateRecipe(db *sql.DB) error {
-	err := db.QueryRow(
-		"INSERT INTO recipes(name, preptime, difficulty, vegetarian) VALUES($1, $2, $3, $4) RETURNING id",
-		r.Name, r.PrepTime, r.Difficulty, r.Vegetarian).Scan(&r.ID)
-	if err != nil {
-		return err
-	}
-	return nil
+// The User entity is used to marshall/unmarshall JSON.
+type User struct {
+	ID        int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	// PasswordHash is the bcrypt hash of the password, and is never sent to clients
+	PasswordHash string `json:"-"`
 }
 
-// GetRecipes returns a collection of known recipes.
-func GetRecipes(db *sql.DB, start int, count int) ([]Recipe, error) {
-	rows, err := db.Query(
-		"SELECT id, name, preptime, difficulty, vegetarian FROM recipes LIMIT $1 OFFSET $2",
-		count, start)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-	recipes := []Recipe{}
-	for rows.Next() {
-		var r Recipe
-		if err := rows.Scan(&r.ID, &r.Name, &r.PrepTime, &r.Difficulty, &r.Vegetarian); err != nil {
-			return nil, err
-		}
-		recipes = append(recipes, r)
-	}
-
-	return recipes, nil
+// The roles a user may have. Each may do everything the ones before it may:
+// viewers read and rate recipes, authors also create recipes and change
+// their own, moderators change any recipe and delete any rating, and
+// admins also give users their roles.
+const (
+	RoleViewer    = "viewer"
+	RoleAuthor    = "author"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// DefaultRole is the role of newly registered users.
+const DefaultRole = RoleAuthor
+
+// The RoleChange entity is used to unmarshall the JSON giving a user a role.
+type RoleChange struct {
+	Role string `json:"role" validate:"required,oneof=viewer author moderator admin"`
 }
 
-// GetRecipesRated returns a collection of rated recipes.
-func GetRecipesRated(db *sql.DB, start int, count int, preptime float32) ([]RecipeRated, error) {
-	rows, err := db.Query(
-		"SELECT id, name, preptime, difficulty, vegetarian, "+
-			"(SELECT COALESCE(AVG(rating),0) AS avg_rating FROM recipe_ratings WHERE recipe_id = id)"+
-			" FROM recipes WHERE preptime < $1 LIMIT $2 OFFSET $3",
-		preptime, count, start)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-	recipesRated := []RecipeRated{}
-	for rows.Next() {
-		var rr RecipeRated
-		if err := rows.Scan(&rr.ID, &rr.Name, &rr.PrepTime, &rr.Difficulty, &rr.Vegetarian, &rr.AvgRating); err != nil {
-			return nil, err
-		}
-		recipesRated = append(recipesRated, rr)
-	}
-
-	return recipesRated, nil
-}
-
-// AddRecipeRating adds a rating for a specific recipe.
-// There can be many ratings for any specific recipe
-// and the ratings are never overwritten.
-func (rr *RecipeRating) AddRecipeRating(db *sql.DB) error {
-	err := db.QueryRow(
-		"INSERT INTO recipe_ratings(recipe_id, rating) VALUES($1, $2) RETURNING rating_id",
-		rr.RecipeID, rr.Rating).Scan(&rr.ID)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+// The Credentials entity is used to unmarshall the JSON of a registration or login.
+type Credentials struct {
+	Username string `json:"username" validate:"required,maxlen=50"`
+	// Password is limited to 72 bytes by bcrypt, which is fewer characters
+	// if any are not ASCII
+	Password string `json:"password" validate:"required,minlen=8,maxbytes=72"`
 }
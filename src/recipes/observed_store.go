@@ -0,0 +1,269 @@
+package recipes
+
+import (
+	"context"
+	"time"
+
+	"logging"
+	"tracing"
+)
+
+// QueryObserver is told how long each call to a store took, and the error
+// it returned, if any.
+type QueryObserver func(operation string, d time.Duration, err error)
+
+// ObservedStore is a RecipeStore which times every call to another store.
+// Each call is one or more queries (or a transaction) in an SQLStore, so
+// the times are those of the database work behind each operation.
+//
+// Calls which fail are logged to the logger carried by their context,
+// along with its fields such as the request ID; calls which succeed, or
+// find nothing, are logged at Debug level. Each call is also traced, as a
+// span named after the operation, and given a deadline.
+type ObservedStore struct {
+	Store   RecipeStore
+	Observe QueryObserver
+	// Timeout limits each call, unless Timeouts has an entry for its
+	// operation; zero means no limit
+	Timeout  time.Duration
+	Timeouts map[string]time.Duration
+}
+
+// start starts a call, returning its context and a function to defer with
+// a pointer to the call's error result.
+func (s ObservedStore) start(ctx context.Context, operation string) (context.Context, func(*error)) {
+	begun := time.Now()
+	timeout, ok := s.Timeouts[operation]
+	if !ok {
+		timeout = s.Timeout
+	}
+	cancel := func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	ctx, span := tracing.Start(ctx, "recipes."+operation, tracing.Internal)
+	return ctx, func(err *error) {
+		// the driver reports a cancelled query in its own way
+		if *err != nil && ctx.Err() != nil {
+			*err = ctx.Err()
+		}
+		cancel()
+		s.observe(ctx, operation, time.Since(begun), *err)
+		if *err != nil && *err != ErrNotFound && !IsRejection(*err) {
+			span.SetError(*err)
+		}
+		span.End()
+	}
+}
+
+// observe reports a call which took d.
+func (s ObservedStore) observe(ctx context.Context, operation string, d time.Duration, err error) {
+	if s.Observe != nil {
+		s.Observe(operation, d, err)
+	}
+	log := logging.FromContext(ctx)
+	switch {
+	case err == nil || err == ErrNotFound:
+		log.Debug("store operation", "operation", operation, "duration_ms", d, "not_found", err == ErrNotFound)
+	case IsRejection(err) || err == context.Canceled:
+		log.Warn("store operation rejected", "operation", operation, "duration_ms", d, "error", err)
+	default:
+		log.Error("store operation failed", "operation", operation, "duration_ms", d, "error", err)
+	}
+}
+
+// GetRecipe is timed, traced and limited as the "GetRecipe" operation.
+func (s ObservedStore) GetRecipe(ctx context.Context, r *Recipe) (err error) {
+	ctx, end := s.start(ctx, "GetRecipe")
+	defer end(&err)
+	return s.Store.GetRecipe(ctx, r)
+}
+
+// GetRecipeOwner is timed, traced and limited as the "GetRecipeOwner" operation.
+func (s ObservedStore) GetRecipeOwner(ctx context.Context, recipeID int) (ownerID int, err error) {
+	ctx, end := s.start(ctx, "GetRecipeOwner")
+	defer end(&err)
+	return s.Store.GetRecipeOwner(ctx, recipeID)
+}
+
+// GetRecipes is timed, traced and limited as the "GetRecipes" operation.
+func (s ObservedStore) GetRecipes(ctx context.Context, p Page) (recipes []Recipe, info PageInfo, err error) {
+	ctx, end := s.start(ctx, "GetRecipes")
+	defer end(&err)
+	return s.Store.GetRecipes(ctx, p)
+}
+
+// CreateRecipe is timed, traced and limited as the "CreateRecipe" operation.
+func (s ObservedStore) CreateRecipe(ctx context.Context, r *Recipe) (err error) {
+	ctx, end := s.start(ctx, "CreateRecipe")
+	defer end(&err)
+	return s.Store.CreateRecipe(ctx, r)
+}
+
+// UpdateRecipe is timed, traced and limited as the "UpdateRecipe" operation.
+func (s ObservedStore) UpdateRecipe(ctx context.Context, r *Recipe) (err error) {
+	ctx, end := s.start(ctx, "UpdateRecipe")
+	defer end(&err)
+	return s.Store.UpdateRecipe(ctx, r)
+}
+
+// PatchRecipe is timed, traced and limited as the "PatchRecipe" operation.
+func (s ObservedStore) PatchRecipe(ctx context.Context, r *Recipe, patch func(r *Recipe) error) (err error) {
+	ctx, end := s.start(ctx, "PatchRecipe")
+	defer end(&err)
+	return s.Store.PatchRecipe(ctx, r, patch)
+}
+
+// DeleteRecipe is timed, traced and limited as the "DeleteRecipe" operation.
+func (s ObservedStore) DeleteRecipe(ctx context.Context, r *Recipe) (err error) {
+	ctx, end := s.start(ctx, "DeleteRecipe")
+	defer end(&err)
+	return s.Store.DeleteRecipe(ctx, r)
+}
+
+// GetRecipesRated is timed, traced and limited as the "GetRecipesRated" operation.
+func (s ObservedStore) GetRecipesRated(ctx context.Context, rs RecipeSearch, p Page) (rated []RecipeRated, info PageInfo, err error) {
+	ctx, end := s.start(ctx, "GetRecipesRated")
+	defer end(&err)
+	return s.Store.GetRecipesRated(ctx, rs, p)
+}
+
+// ReindexRecipes is timed, traced and limited as the "ReindexRecipes" operation.
+func (s ObservedStore) ReindexRecipes(ctx context.Context) (n int, err error) {
+	ctx, end := s.start(ctx, "ReindexRecipes")
+	defer end(&err)
+	return s.Store.ReindexRecipes(ctx)
+}
+
+// AddRecipeRating is timed, traced and limited as the "AddRecipeRating" operation.
+func (s ObservedStore) AddRecipeRating(ctx context.Context, rr *RecipeRating) (created bool, err error) {
+	ctx, end := s.start(ctx, "AddRecipeRating")
+	defer end(&err)
+	return s.Store.AddRecipeRating(ctx, rr)
+}
+
+// WithdrawRecipeRating is timed, traced and limited as the "WithdrawRecipeRating" operation.
+func (s ObservedStore) WithdrawRecipeRating(ctx context.Context, rr *RecipeRating) (err error) {
+	ctx, end := s.start(ctx, "WithdrawRecipeRating")
+	defer end(&err)
+	return s.Store.WithdrawRecipeRating(ctx, rr)
+}
+
+// DeleteRecipeRating is timed, traced and limited as the "DeleteRecipeRating" operation.
+func (s ObservedStore) DeleteRecipeRating(ctx context.Context, rr *RecipeRating) (err error) {
+	ctx, end := s.start(ctx, "DeleteRecipeRating")
+	defer end(&err)
+	return s.Store.DeleteRecipeRating(ctx, rr)
+}
+
+// GetRatingStats is timed, traced and limited as the "GetRatingStats" operation.
+func (s ObservedStore) GetRatingStats(ctx context.Context, recipeID int) (st RatingStats, err error) {
+	ctx, end := s.start(ctx, "GetRatingStats")
+	defer end(&err)
+	return s.Store.GetRatingStats(ctx, recipeID)
+}
+
+// RecomputeRatingStats is timed, traced and limited as the "RecomputeRatingStats" operation.
+func (s ObservedStore) RecomputeRatingStats(ctx context.Context) (n int, err error) {
+	ctx, end := s.start(ctx, "RecomputeRatingStats")
+	defer end(&err)
+	return s.Store.RecomputeRatingStats(ctx)
+}
+
+// CreateUser is timed, traced and limited as the "CreateUser" operation.
+func (s ObservedStore) CreateUser(ctx context.Context, u *User) (err error) {
+	ctx, end := s.start(ctx, "CreateUser")
+	defer end(&err)
+	return s.Store.CreateUser(ctx, u)
+}
+
+// GetUser is timed, traced and limited as the "GetUser" operation.
+func (s ObservedStore) GetUser(ctx context.Context, u *User) (err error) {
+	ctx, end := s.start(ctx, "GetUser")
+	defer end(&err)
+	return s.Store.GetUser(ctx, u)
+}
+
+// GetUserByName is timed, traced and limited as the "GetUserByName" operation.
+func (s ObservedStore) GetUserByName(ctx context.Context, u *User) (err error) {
+	ctx, end := s.start(ctx, "GetUserByName")
+	defer end(&err)
+	return s.Store.GetUserByName(ctx, u)
+}
+
+// SetUserRole is timed, traced and limited as the "SetUserRole" operation.
+func (s ObservedStore) SetUserRole(ctx context.Context, u *User) (err error) {
+	ctx, end := s.start(ctx, "SetUserRole")
+	defer end(&err)
+	return s.Store.SetUserRole(ctx, u)
+}
+
+// GetIngredients is timed, traced and limited as the "GetIngredients" operation.
+func (s ObservedStore) GetIngredients(ctx context.Context, recipeID int) (ingredients []Ingredient, err error) {
+	ctx, end := s.start(ctx, "GetIngredients")
+	defer end(&err)
+	return s.Store.GetIngredients(ctx, recipeID)
+}
+
+// GetIngredient is timed, traced and limited as the "GetIngredient" operation.
+func (s ObservedStore) GetIngredient(ctx context.Context, i *Ingredient) (err error) {
+	ctx, end := s.start(ctx, "GetIngredient")
+	defer end(&err)
+	return s.Store.GetIngredient(ctx, i)
+}
+
+// AddIngredient is timed, traced and limited as the "AddIngredient" operation.
+func (s ObservedStore) AddIngredient(ctx context.Context, i *Ingredient) (err error) {
+	ctx, end := s.start(ctx, "AddIngredient")
+	defer end(&err)
+	return s.Store.AddIngredient(ctx, i)
+}
+
+// UpdateIngredient is timed, traced and limited as the "UpdateIngredient" operation.
+func (s ObservedStore) UpdateIngredient(ctx context.Context, i *Ingredient) (err error) {
+	ctx, end := s.start(ctx, "UpdateIngredient")
+	defer end(&err)
+	return s.Store.UpdateIngredient(ctx, i)
+}
+
+// DeleteIngredient is timed, traced and limited as the "DeleteIngredient" operation.
+func (s ObservedStore) DeleteIngredient(ctx context.Context, i *Ingredient) (err error) {
+	ctx, end := s.start(ctx, "DeleteIngredient")
+	defer end(&err)
+	return s.Store.DeleteIngredient(ctx, i)
+}
+
+// GetSteps is timed, traced and limited as the "GetSteps" operation.
+func (s ObservedStore) GetSteps(ctx context.Context, recipeID int) (steps []Step, err error) {
+	ctx, end := s.start(ctx, "GetSteps")
+	defer end(&err)
+	return s.Store.GetSteps(ctx, recipeID)
+}
+
+// AddStep is timed, traced and limited as the "AddStep" operation.
+func (s ObservedStore) AddStep(ctx context.Context, st *Step) (err error) {
+	ctx, end := s.start(ctx, "AddStep")
+	defer end(&err)
+	return s.Store.AddStep(ctx, st)
+}
+
+// UpdateStep is timed, traced and limited as the "UpdateStep" operation.
+func (s ObservedStore) UpdateStep(ctx context.Context, st *Step) (err error) {
+	ctx, end := s.start(ctx, "UpdateStep")
+	defer end(&err)
+	return s.Store.UpdateStep(ctx, st)
+}
+
+// DeleteStep is timed, traced and limited as the "DeleteStep" operation.
+func (s ObservedStore) DeleteStep(ctx context.Context, st *Step) (err error) {
+	ctx, end := s.start(ctx, "DeleteStep")
+	defer end(&err)
+	return s.Store.DeleteStep(ctx, st)
+}
+
+// ReorderSteps is timed, traced and limited as the "ReorderSteps" operation.
+func (s ObservedStore) ReorderSteps(ctx context.Context, recipeID int, stepIDs []int) (steps []Step, err error) {
+	ctx, end := s.start(ctx, "ReorderSteps")
+	defer end(&err)
+	return s.Store.ReorderSteps(ctx, recipeID, stepIDs)
+}
@@ -0,0 +1,92 @@
+package recipes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Cursor is a position in an ordered listing of recipes: just after the
+// recipe with ID and sort key Name or Value or, if Before is set, just
+// before it. Cursors are passed to clients as opaque tokens.
+type Cursor struct {
+	// Sort is the sort order of the listing the cursor belongs to
+	Sort   string  `json:"s,omitempty"`
+	ID     int     `json:"id"`
+	Name   string  `json:"n,omitempty"`
+	Value  float64 `json:"v,omitempty"`
+	Before bool    `json:"b,omitempty"`
+}
+
+// Page selects up to Limit recipes from a listing, starting from Cursor or,
+// if Cursor is nil, from the beginning.
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// PageInfo holds the cursors for the next and previous pages of a listing;
+// each is nil if there is no such page.
+type PageInfo struct {
+	Next *Cursor
+	Prev *Cursor
+}
+
+// Token encodes the cursor for a client.
+func (c Cursor) Token() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor decodes a cursor token, returning ErrInvalidCursor if it is
+// not one produced by Token.
+func ParseCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// backward reports whether the page runs backward from its cursor.
+func (p Page) backward() bool {
+	return p.Cursor != nil && p.Cursor.Before
+}
+
+// checkSort returns ErrInvalidCursor if the page's cursor belongs to a
+// listing with a different sort order.
+func (p Page) checkSort(sort string) error {
+	if p.Cursor != nil && p.Cursor.Sort != sort {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// pageInfo returns the cursors either side of a page, given the cursors of
+// its first and last items (nil if it is empty) and whether there were more
+// items beyond the page in the direction it was read.
+func pageInfo(p Page, first *Cursor, last *Cursor, more bool) PageInfo {
+	var info PageInfo
+	if first == nil {
+		return info
+	}
+	prev, next := *first, *last
+	prev.Before, next.Before = true, false
+	if p.backward() {
+		info.Next = &next
+		if more {
+			info.Prev = &prev
+		}
+	} else {
+		if more {
+			info.Next = &next
+		}
+		if p.Cursor != nil {
+			info.Prev = &prev
+		}
+	}
+	return info
+}
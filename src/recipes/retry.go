@@ -0,0 +1,114 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy bounds how often, and how quickly, a transaction is retried
+// after CockroachDB reports a retryable (SQLSTATE 40001) error.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialBackoff is doubled after each retry, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used by NewSQLStore.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// TxStats counts the transactions run by ExecuteTx.
+type TxStats struct {
+	Transactions int64
+	Retries      int64
+	// Exhausted counts transactions which failed after MaxRetries retries
+	Exhausted int64
+}
+
+var txStats TxStats
+
+// GetTxStats returns the transaction counts since the process started.
+func GetTxStats() TxStats {
+	return TxStats{
+		Transactions: atomic.LoadInt64(&txStats.Transactions),
+		Retries:      atomic.LoadInt64(&txStats.Retries),
+		Exhausted:    atomic.LoadInt64(&txStats.Exhausted),
+	}
+}
+
+// IsRetryable reports whether err is a CockroachDB transaction retry error.
+func IsRetryable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "40001"
+}
+
+// ExecuteTx runs fn in a transaction using the DefaultRetryPolicy.
+func ExecuteTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	return DefaultRetryPolicy.ExecuteTx(ctx, db, fn)
+}
+
+// ExecuteTx runs fn in a transaction using CockroachDB's client-side retry
+// protocol: fn is run after SAVEPOINT cockroach_restart and, if it or the
+// RELEASE fails with a retryable error, the savepoint is rolled back and fn is
+// run again after a backoff. fn may therefore be run more than once and must
+// not have side effects outside the transaction.
+//
+// The transaction is rolled back if ctx is done before it commits, and no
+// retry is made once ctx is done; ctx should also be passed to the queries
+// fn makes.
+func (p RetryPolicy) ExecuteTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	atomic.AddInt64(&txStats.Transactions, 1)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT cockroach_restart"); err != nil {
+		return err
+	}
+	backoff := p.InitialBackoff
+	for retries := 0; ; retries++ {
+		err := fn(tx)
+		if err == nil {
+			if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT cockroach_restart"); err == nil {
+				return tx.Commit()
+			}
+		}
+		if !IsRetryable(err) {
+			return err
+		}
+		if retries >= p.MaxRetries {
+			atomic.AddInt64(&txStats.Exhausted, 1)
+			return err
+		}
+		atomic.AddInt64(&txStats.Retries, 1)
+
+		// full jitter, so that contending clients spread out
+		if backoff > 0 {
+			select {
+			case <-time.After(time.Duration(rand.Int63n(int64(backoff)))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT cockroach_restart"); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package recipes
+
+import "strings"
+
+// RecipeSearch holds the criteria for finding rated recipes. The zero value
+// matches every recipe, in ID order.
+type RecipeSearch struct {
+	// Query matches recipes with a word in their name for each of its
+	// first maxTerms words, which is the same word (ignoring case and
+	// plurals) or begins with it. Unless Sort is set, the most relevant
+	// recipes come first.
+	Query string `json:"q" validate:"maxlen=200"`
+
+	// MinDifficulty and MaxDifficulty are inclusive; zero means no bound
+	MinDifficulty int `json:"min_difficulty" validate:"min=0,max=3"`
+	MaxDifficulty int `json:"max_difficulty" validate:"min=0,max=3"`
+
+	Vegetarian *bool `json:"vegetarian"`
+
+	// MinRating is the lowest average rating to match; unrated recipes average 0
+	MinRating float32 `json:"min_rating" validate:"min=0,max=5"`
+
+	// MinPrepTime and MaxPrepTime are inclusive
+	MinPrepTime *float32 `json:"min_preptime" validate:"min=0"`
+	MaxPrepTime *float32 `json:"max_preptime" validate:"min=0"`
+	// PrepTimeUnder is an exclusive upper bound, as used by the original search
+	PrepTimeUnder *float32 `json:"preptime" validate:"min=0"`
+
+	// Ingredients must all be used, ExcludeIngredients must not be; ingredient
+	// names are matched exactly, ignoring case
+	Ingredients        []string `json:"ingredients" validate:"maxlen=20"`
+	ExcludeIngredients []string `json:"exclude_ingredients" validate:"maxlen=20"`
+
+	// Sort is one of name, preptime or rating, prefixed by - for descending order
+	Sort string `json:"sort" validate:"oneof=name -name preptime -preptime rating -rating"`
+}
+
+// Validate checks the search criteria.
+func (rs *RecipeSearch) Validate() error {
+	return Validate(rs)
+}
+
+// terms returns the distinct words of the query which are searched for.
+func (rs RecipeSearch) terms() []string {
+	terms, _ := indexWords(rs.Query)
+	if len(terms) > maxTerms {
+		terms = terms[:maxTerms]
+	}
+	return terms
+}
+
+// order returns the sort order of the results: Sort, or relevance if it is
+// not set and there is a query.
+func (rs RecipeSearch) order() string {
+	if rs.Sort == "" && len(rs.terms()) > 0 {
+		return "relevance"
+	}
+	return rs.Sort
+}
+
+// sortKey returns the field to sort on and whether the order is descending.
+func (rs RecipeSearch) sortKey() (string, bool) {
+	order := rs.order()
+	if order == "relevance" {
+		return order, true
+	}
+	if strings.HasPrefix(order, "-") {
+		return order[1:], true
+	}
+	return order, false
+}
+
+// cursor returns the cursor positioned at a rated recipe.
+func (rs RecipeSearch) cursor(r RecipeRated) *Cursor {
+	c := &Cursor{Sort: rs.order(), ID: r.ID}
+	switch key, _ := rs.sortKey(); key {
+	case "name":
+		c.Name = r.Name
+	case "preptime":
+		c.Value = float64(r.PrepTime)
+	case "rating":
+		c.Value = float64(r.AvgRating)
+	case "relevance":
+		c.Value = float64(r.Relevance)
+	}
+	return c
+}
+
+// position returns a rated recipe with the sort key of a cursor, for
+// comparing with less.
+func (rs RecipeSearch) position(c Cursor) RecipeRated {
+	return RecipeRated{ID: c.ID, Name: c.Name, PrepTime: float32(c.Value), AvgRating: float32(c.Value),
+		Relevance: float32(c.Value)}
+}
+
+// matches reports whether a rated recipe, which uses the named ingredients,
+// meets the search criteria, and sets its relevance to the query.
+func (rs RecipeSearch) matches(r *RecipeRated, ingredients []string) bool {
+	if terms := rs.terms(); len(terms) > 0 {
+		words, count := indexWords(r.Name)
+		score, ok := relevance(terms, words, count)
+		if !ok {
+			return false
+		}
+		r.Relevance = float32(score)
+	}
+	switch {
+	case rs.MinDifficulty > 0 && r.Difficulty < rs.MinDifficulty,
+		rs.MaxDifficulty > 0 && r.Difficulty > rs.MaxDifficulty,
+		rs.Vegetarian != nil && r.Vegetarian != *rs.Vegetarian,
+		r.AvgRating < rs.MinRating,
+		rs.MinPrepTime != nil && r.PrepTime < *rs.MinPrepTime,
+		rs.MaxPrepTime != nil && r.PrepTime > *rs.MaxPrepTime,
+		rs.PrepTimeUnder != nil && r.PrepTime >= *rs.PrepTimeUnder:
+		return false
+	}
+	uses := map[string]bool{}
+	for _, ingredient := range ingredients {
+		uses[strings.ToLower(ingredient)] = true
+	}
+	for _, ingredient := range rs.Ingredients {
+		if !uses[strings.ToLower(ingredient)] {
+			return false
+		}
+	}
+	for _, ingredient := range rs.ExcludeIngredients {
+		if uses[strings.ToLower(ingredient)] {
+			return false
+		}
+	}
+	return true
+}
+
+// less orders rated recipes by the sort key, and then by ID.
+func (rs RecipeSearch) less(a, b RecipeRated) bool {
+	key, desc := rs.sortKey()
+	var cmp int
+	switch key {
+	case "name":
+		cmp = strings.Compare(a.Name, b.Name)
+	case "preptime":
+		cmp = compareFloat(a.PrepTime, b.PrepTime)
+	case "rating":
+		cmp = compareFloat(a.AvgRating, b.AvgRating)
+	case "relevance":
+		cmp = compareFloat(a.Relevance, b.Relevance)
+	}
+	if desc {
+		cmp = -cmp
+	}
+	if cmp != 0 {
+		return cmp < 0
+	}
+	return a.ID < b.ID
+}
+
+func compareFloat(a, b float32) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// GetIngredients returns the ingredients of a specific recipe.
+func (s *SQLStore) GetIngredients(ctx context.Context, recipeID int) ([]Ingredient, error) {
+	var ingredients []Ingredient
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, recipeID); err != nil {
+			return err
+		}
+		var err error
+		ingredients, err = queryIngredients(ctx, tx, recipeID)
+		return err
+	})
+	return ingredients, err
+}
+
+// GetIngredient returns a single specified ingredient.
+func (s *SQLStore) GetIngredient(ctx context.Context, i *Ingredient) error {
+	var notes sql.NullString
+	err := s.db().QueryRowContext(ctx,
+		"SELECT name, quantity, unit, notes FROM ingredients WHERE recipe_id=$1 AND ingredient_id=$2",
+		i.RecipeID, i.ID).Scan(&i.Name, &i.Quantity, &i.Unit, &notes)
+	i.Notes = notes.String
+	return notFound(err)
+}
+
+// AddIngredient adds an ingredient to a specific recipe.
+func (s *SQLStore) AddIngredient(ctx context.Context, i *Ingredient) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, i.RecipeID); err != nil {
+			return err
+		}
+		return notFound(insertIngredient(ctx, tx, i))
+	})
+}
+
+// UpdateIngredient is used to modify a specific ingredient.
+func (s *SQLStore) UpdateIngredient(ctx context.Context, i *Ingredient) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE ingredients SET name=$1, quantity=$2, unit=$3, notes=$4 WHERE recipe_id=$5 AND ingredient_id=$6",
+			i.Name, i.Quantity, i.Unit, nullString(i.Notes), i.RecipeID, i.ID)
+		return checkRowsAffected(res, err)
+	})
+}
+
+// DeleteIngredient is used to delete a specific ingredient.
+func (s *SQLStore) DeleteIngredient(ctx context.Context, i *Ingredient) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM ingredients WHERE recipe_id=$1 AND ingredient_id=$2", i.RecipeID, i.ID)
+		return checkRowsAffected(res, err)
+	})
+}
+
+func insertIngredient(ctx context.Context, q queryer, i *Ingredient) error {
+	return q.QueryRowContext(ctx,
+		"INSERT INTO ingredients(recipe_id, name, quantity, unit, notes) VALUES($1, $2, $3, $4, $5) RETURNING ingredient_id",
+		i.RecipeID, i.Name, i.Quantity, i.Unit, nullString(i.Notes)).Scan(&i.ID)
+}
+
+func queryIngredients(ctx context.Context, q queryer, recipeID int) ([]Ingredient, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT ingredient_id, name, quantity, unit, notes FROM ingredients WHERE recipe_id=$1 ORDER BY ingredient_id",
+		recipeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	ingredients := []Ingredient{}
+	for rows.Next() {
+		i := Ingredient{RecipeID: recipeID}
+		var notes sql.NullString
+		if err := rows.Scan(&i.ID, &i.Name, &i.Quantity, &i.Unit, &notes); err != nil {
+			return nil, err
+		}
+		i.Notes = notes.String
+		ingredients = append(ingredients, i)
+	}
+
+	return ingredients, rows.Err()
+}
+
+// recipeExists returns ErrNotFound if there is no such recipe.
+func recipeExists(ctx context.Context, q queryer, recipeID int) error {
+	var id int
+	return notFound(q.QueryRowContext(ctx, "SELECT id FROM recipes WHERE id=$1", recipeID).Scan(&id))
+}
+
+// checkRowsAffected returns ErrNotFound if the statement did not change anything.
+func checkRowsAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,142 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AddRecipeRating adds or replaces a rating for a specific recipe.
+// There can be many ratings for any specific recipe, but only one
+// from each user: a user's rating is overwritten if they rate again.
+func (s *SQLStore) AddRecipeRating(ctx context.Context, rr *RecipeRating) (bool, error) {
+	created := false
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, rr.RecipeID); err != nil {
+			return err
+		}
+		var previous int
+		err := sql.ErrNoRows
+		if rr.UserID != 0 {
+			err = tx.QueryRowContext(ctx, "SELECT rating_id, rating FROM recipe_ratings WHERE recipe_id=$1 AND user_id=$2",
+				rr.RecipeID, rr.UserID).Scan(&rr.ID, &previous)
+		}
+		switch {
+		case err == sql.ErrNoRows:
+			created = true
+			err = tx.QueryRowContext(ctx,
+				"INSERT INTO recipe_ratings(recipe_id, user_id, rating) VALUES($1, $2, $3) RETURNING rating_id",
+				rr.RecipeID, nullInt(rr.UserID), rr.Rating).Scan(&rr.ID)
+		case err == nil:
+			// reset, as the transaction may be a retry
+			created = false
+			if _, err = tx.ExecContext(ctx, "UPDATE recipe_ratings SET rating=$1 WHERE recipe_id=$2 AND rating_id=$3",
+				rr.Rating, rr.RecipeID, rr.ID); err == nil {
+				err = adjustRatingStats(ctx, tx, rr.RecipeID, previous, -1)
+			}
+		}
+		if err != nil {
+			return notFound(err)
+		}
+		return adjustRatingStats(ctx, tx, rr.RecipeID, rr.Rating, 1)
+	})
+	return created, err
+}
+
+// WithdrawRecipeRating is used to delete a user's rating of a specific recipe.
+func (s *SQLStore) WithdrawRecipeRating(ctx context.Context, rr *RecipeRating) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx, "DELETE FROM recipe_ratings WHERE recipe_id=$1 AND user_id=$2 RETURNING rating_id, rating",
+			rr.RecipeID, rr.UserID).Scan(&rr.ID, &rr.Rating)
+		if err != nil {
+			return notFound(err)
+		}
+		return adjustRatingStats(ctx, tx, rr.RecipeID, rr.Rating, -1)
+	})
+}
+
+// DeleteRecipeRating is used to delete a specific rating.
+func (s *SQLStore) DeleteRecipeRating(ctx context.Context, rr *RecipeRating) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		var userID sql.NullInt64
+		err := tx.QueryRowContext(ctx, "DELETE FROM recipe_ratings WHERE recipe_id=$1 AND rating_id=$2 RETURNING user_id, rating",
+			rr.RecipeID, rr.ID).Scan(&userID, &rr.Rating)
+		rr.UserID = int(userID.Int64)
+		if err != nil {
+			return notFound(err)
+		}
+		return adjustRatingStats(ctx, tx, rr.RecipeID, rr.Rating, -1)
+	})
+}
+
+// GetRatingStats returns the rating statistics of a specific recipe.
+func (s *SQLStore) GetRatingStats(ctx context.Context, recipeID int) (RatingStats, error) {
+	st := RatingStats{RecipeID: recipeID}
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, recipeID); err != nil {
+			return err
+		}
+		h := &st.Histogram
+		err := tx.QueryRowContext(ctx,
+			"SELECT count, sum, avg_rating, stars_1, stars_2, stars_3, stars_4, stars_5 "+
+				"FROM recipe_rating_stats WHERE recipe_id=$1",
+			recipeID).Scan(&st.Count, &st.Sum, &st.AvgRating, &h[0], &h[1], &h[2], &h[3], &h[4])
+		if err == sql.ErrNoRows {
+			// the recipe has never been rated
+			return nil
+		}
+		return err
+	})
+	return st, err
+}
+
+// RecomputeRatingStats rebuilds the rating statistics from the ratings,
+// in case they have drifted from them.
+func (s *SQLStore) RecomputeRatingStats(ctx context.Context) (int, error) {
+	var n int64
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM recipe_rating_stats"); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx,
+			"INSERT INTO recipe_rating_stats(recipe_id, count, sum, avg_rating, stars_1, stars_2, stars_3, stars_4, stars_5) "+
+				"SELECT recipe_id, count(*), sum(rating), CAST(avg(rating) AS FLOAT), "+
+				"sum(CASE WHEN rating = 1 THEN 1 ELSE 0 END), sum(CASE WHEN rating = 2 THEN 1 ELSE 0 END), "+
+				"sum(CASE WHEN rating = 3 THEN 1 ELSE 0 END), sum(CASE WHEN rating = 4 THEN 1 ELSE 0 END), "+
+				"sum(CASE WHEN rating = 5 THEN 1 ELSE 0 END) "+
+				"FROM recipe_ratings GROUP BY recipe_id")
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
+
+// adjustRatingStats updates the rating statistics of a recipe for a rating
+// being added (delta 1) or deleted (delta -1), creating them if need be.
+func adjustRatingStats(ctx context.Context, q queryer, recipeID int, rating int, delta int) error {
+	var stars [5]int
+	stars[rating-1] = delta
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO recipe_rating_stats AS s (recipe_id, count, sum, stars_1, stars_2, stars_3, stars_4, stars_5) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8) "+
+			"ON CONFLICT (recipe_id) DO UPDATE SET count = s.count + excluded.count, sum = s.sum + excluded.sum, "+
+			"stars_1 = s.stars_1 + excluded.stars_1, stars_2 = s.stars_2 + excluded.stars_2, "+
+			"stars_3 = s.stars_3 + excluded.stars_3, stars_4 = s.stars_4 + excluded.stars_4, "+
+			"stars_5 = s.stars_5 + excluded.stars_5",
+		recipeID, delta, rating*delta, stars[0], stars[1], stars[2], stars[3], stars[4])
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		"UPDATE recipe_rating_stats SET avg_rating = "+
+			"CASE WHEN count > 0 THEN CAST(sum AS FLOAT) / count ELSE 0.0 END WHERE recipe_id=$1",
+		recipeID)
+	return err
+}
+
+// nullInt stores a zero ID as NULL.
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,150 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GetSteps returns the steps of a specific recipe.
+func (s *SQLStore) GetSteps(ctx context.Context, recipeID int) ([]Step, error) {
+	var steps []Step
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, recipeID); err != nil {
+			return err
+		}
+		var err error
+		steps, err = querySteps(ctx, tx, recipeID)
+		return err
+	})
+	return steps, err
+}
+
+// AddStep appends a step to a specific recipe.
+func (s *SQLStore) AddStep(ctx context.Context, st *Step) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, st.RecipeID); err != nil {
+			return err
+		}
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(position), 0) + 1 FROM steps WHERE recipe_id=$1",
+			st.RecipeID).Scan(&st.Position)
+		if err != nil {
+			return err
+		}
+		if err := insertStep(ctx, tx, st); err != nil {
+			return notFound(err)
+		}
+		return refreshPrepTime(ctx, tx, st.RecipeID)
+	})
+}
+
+// UpdateStep is used to modify a specific step.
+func (s *SQLStore) UpdateStep(ctx context.Context, st *Step) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx,
+			"UPDATE steps SET text=$1, duration=$2, timer=$3 WHERE recipe_id=$4 AND step_id=$5 RETURNING position",
+			st.Text, st.Duration, st.Timer, st.RecipeID, st.ID).Scan(&st.Position)
+		if err != nil {
+			return notFound(err)
+		}
+		return refreshPrepTime(ctx, tx, st.RecipeID)
+	})
+}
+
+// DeleteStep is used to delete a specific step.
+func (s *SQLStore) DeleteStep(ctx context.Context, st *Step) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx, "DELETE FROM steps WHERE recipe_id=$1 AND step_id=$2 RETURNING position",
+			st.RecipeID, st.ID).Scan(&st.Position)
+		if err != nil {
+			return notFound(err)
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE steps SET position = position - 1 WHERE recipe_id=$1 AND position > $2",
+			st.RecipeID, st.Position)
+		if err != nil {
+			return err
+		}
+		return refreshPrepTime(ctx, tx, st.RecipeID)
+	})
+}
+
+// ReorderSteps is used to change the order of the steps of a specific recipe.
+func (s *SQLStore) ReorderSteps(ctx context.Context, recipeID int, stepIDs []int) ([]Step, error) {
+	var steps []Step
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if err := recipeExists(ctx, tx, recipeID); err != nil {
+			return err
+		}
+		current, err := querySteps(ctx, tx, recipeID)
+		if err != nil {
+			return err
+		}
+		if !isPermutation(current, stepIDs) {
+			return ErrStepOrder
+		}
+		for n, id := range stepIDs {
+			_, err := tx.ExecContext(ctx, "UPDATE steps SET position=$1 WHERE recipe_id=$2 AND step_id=$3", n+1, recipeID, id)
+			if err != nil {
+				return err
+			}
+		}
+		steps, err = querySteps(ctx, tx, recipeID)
+		return err
+	})
+	return steps, err
+}
+
+func insertStep(ctx context.Context, q queryer, st *Step) error {
+	return q.QueryRowContext(ctx,
+		"INSERT INTO steps(recipe_id, position, text, duration, timer) VALUES($1, $2, $3, $4, $5) RETURNING step_id",
+		st.RecipeID, st.Position, st.Text, st.Duration, st.Timer).Scan(&st.ID)
+}
+
+func querySteps(ctx context.Context, q queryer, recipeID int) ([]Step, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT step_id, position, text, duration, timer FROM steps WHERE recipe_id=$1 ORDER BY position",
+		recipeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	steps := []Step{}
+	for rows.Next() {
+		st := Step{RecipeID: recipeID}
+		var duration sql.NullFloat64
+		var timer sql.NullInt64
+		if err := rows.Scan(&st.ID, &st.Position, &st.Text, &duration, &timer); err != nil {
+			return nil, err
+		}
+		if duration.Valid {
+			d := float32(duration.Float64)
+			st.Duration = &d
+		}
+		if timer.Valid {
+			t := int(timer.Int64)
+			st.Timer = &t
+		}
+		steps = append(steps, st)
+	}
+
+	return steps, rows.Err()
+}
+
+// isPermutation reports whether stepIDs lists each of the steps exactly once.
+func isPermutation(steps []Step, stepIDs []int) bool {
+	if len(steps) != len(stepIDs) {
+		return false
+	}
+	remaining := map[int]bool{}
+	for _, st := range steps {
+		remaining[st.ID] = true
+	}
+	for _, id := range stepIDs {
+		if !remaining[id] {
+			return false
+		}
+		delete(remaining, id)
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// SQLStore is a RecipeStore backed by CockroachDB (or PostgreSQL).
+// All writes are run through Retry.ExecuteTx.
+type SQLStore struct {
+	DB    *sql.DB
+	Retry RetryPolicy
+}
+
+// NewSQLStore returns a RecipeStore using the specified database.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db, Retry: DefaultRetryPolicy}
+}
+
+// GetRecipe returns a single specified recipe.
+func (s *SQLStore) GetRecipe(ctx context.Context, r *Recipe) error {
+	return selectRecipe(ctx, s.db(), r)
+}
+
+// selectRecipe loads a recipe, with its ingredients and steps.
+func selectRecipe(ctx context.Context, q queryer, r *Recipe) error {
+	var ownerID sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT name, preptime, difficulty, vegetarian, derive_preptime, owner_id FROM recipes WHERE id=$1",
+		r.ID).Scan(&r.Name, &r.PrepTime, &r.Difficulty, &r.Vegetarian, &r.DerivePrepTime, &ownerID)
+	if err != nil {
+		return notFound(err)
+	}
+	r.OwnerID = int(ownerID.Int64)
+	return loadChildren(ctx, q, r)
+}
+
+// GetRecipeOwner returns the owner of a specified recipe.
+func (s *SQLStore) GetRecipeOwner(ctx context.Context, recipeID int) (int, error) {
+	var ownerID sql.NullInt64
+	err := s.db().QueryRowContext(ctx, "SELECT owner_id FROM recipes WHERE id=$1", recipeID).Scan(&ownerID)
+	return int(ownerID.Int64), notFound(err)
+}
+
+// UpdateRecipe is used to modify a specific recipe.
+func (s *SQLStore) UpdateRecipe(ctx context.Context, r *Recipe) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		return updateRecipe(ctx, tx, r)
+	})
+}
+
+// PatchRecipe is used to change a specific recipe based on what it holds.
+// CockroachDB transactions are serializable, so if another transaction
+// changes the recipe after it is read, one of them is retried, and the
+// patch is applied again to what the other stored.
+func (s *SQLStore) PatchRecipe(ctx context.Context, r *Recipe, patch func(r *Recipe) error) error {
+	id := r.ID
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		*r = Recipe{ID: id}
+		if err := selectRecipe(ctx, tx, r); err != nil {
+			return err
+		}
+		if err := patch(r); err != nil {
+			return err
+		}
+		r.ID = id
+		return updateRecipe(ctx, tx, r)
+	})
+}
+
+// updateRecipe replaces a recipe, and loads its owner, ingredients and steps.
+func updateRecipe(ctx context.Context, tx queryer, r *Recipe) error {
+	var ownerID sql.NullInt64
+	err := tx.QueryRowContext(ctx,
+		"UPDATE recipes SET name=$1, preptime=$2, difficulty=$3, vegetarian=$4, derive_preptime=$5 WHERE id=$6 RETURNING owner_id",
+		r.Name, r.PrepTime, r.Difficulty, r.Vegetarian, r.DerivePrepTime, r.ID).Scan(&ownerID)
+	if err != nil {
+		return notFound(err)
+	}
+	r.OwnerID = int(ownerID.Int64)
+	if err := indexRecipe(ctx, tx, r.ID, r.Name, true); err != nil {
+		return err
+	}
+	if err := derivePrepTime(ctx, tx, r); err != nil {
+		return err
+	}
+	return loadChildren(ctx, tx, r)
+}
+
+// DeleteRecipe is used to delete a specific recipe.
+func (s *SQLStore) DeleteRecipe(ctx context.Context, r *Recipe) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM recipes WHERE id=$1", r.ID)
+		return checkRowsAffected(res, err)
+	})
+}
+
+// CreateRecipe is used to create a single recipe.
+func (s *SQLStore) CreateRecipe(ctx context.Context, r *Recipe) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO recipes(name, preptime, difficulty, vegetarian, derive_preptime, owner_id) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+			r.Name, r.PrepTime, r.Difficulty, r.Vegetarian, r.DerivePrepTime, nullInt(r.OwnerID)).Scan(&r.ID)
+		if err != nil {
+			return err
+		}
+		if err := indexRecipe(ctx, tx, r.ID, r.Name, false); err != nil {
+			return err
+		}
+		for i := range r.Ingredients {
+			r.Ingredients[i].RecipeID = r.ID
+			if err := insertIngredient(ctx, tx, &r.Ingredients[i]); err != nil {
+				return err
+			}
+		}
+		for i := range r.Steps {
+			r.Steps[i].RecipeID = r.ID
+			r.Steps[i].Position = i + 1
+			if err := insertStep(ctx, tx, &r.Steps[i]); err != nil {
+				return err
+			}
+		}
+		return derivePrepTime(ctx, tx, r)
+	})
+}
+
+// loadChildren loads the ingredients and steps of a recipe.
+func loadChildren(ctx context.Context, q queryer, r *Recipe) error {
+	var err error
+	if r.Ingredients, err = queryIngredients(ctx, q, r.ID); err != nil {
+		return err
+	}
+	r.Steps, err = querySteps(ctx, q, r.ID)
+	return err
+}
+
+// derivePrepTime sets the preparation time of a recipe with DerivePrepTime
+// set to the sum of its step durations, and loads it into r.
+func derivePrepTime(ctx context.Context, q queryer, r *Recipe) error {
+	if !r.DerivePrepTime {
+		return nil
+	}
+	if err := refreshPrepTime(ctx, q, r.ID); err != nil {
+		return err
+	}
+	return q.QueryRowContext(ctx, "SELECT preptime FROM recipes WHERE id=$1", r.ID).Scan(&r.PrepTime)
+}
+
+// refreshPrepTime recalculates the preparation time of a recipe
+// if it is derived from the step durations.
+func refreshPrepTime(ctx context.Context, q queryer, recipeID int) error {
+	_, err := q.ExecContext(ctx,
+		"UPDATE recipes SET preptime = (SELECT COALESCE(SUM(duration), 0) FROM steps WHERE recipe_id = $1)"+
+			" WHERE id = $1 AND derive_preptime",
+		recipeID)
+	return err
+}
+
+// GetRecipes returns a page of known recipes, in ID order.
+func (s *SQLStore) GetRecipes(ctx context.Context, p Page) ([]Recipe, PageInfo, error) {
+	if err := p.checkSort(""); err != nil {
+		return nil, PageInfo{}, err
+	}
+	query := "SELECT id, name, preptime, difficulty, vegetarian, derive_preptime, owner_id FROM recipes " +
+		"WHERE id > $1 ORDER BY id LIMIT $2"
+	after := 0
+	if p.Cursor != nil {
+		after = p.Cursor.ID
+	}
+	if p.backward() {
+		query = "SELECT id, name, preptime, difficulty, vegetarian, derive_preptime, owner_id FROM recipes " +
+			"WHERE id < $1 ORDER BY id DESC LIMIT $2"
+	}
+	rows, err := s.db().QueryContext(ctx, query, after, p.Limit+1)
+
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	defer rows.Close()
+	recipes := []Recipe{}
+	for rows.Next() {
+		var r Recipe
+		var ownerID sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Name, &r.PrepTime, &r.Difficulty, &r.Vegetarian, &r.DerivePrepTime, &ownerID); err != nil {
+			return nil, PageInfo{}, err
+		}
+		r.OwnerID = int(ownerID.Int64)
+		recipes = append(recipes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	more := len(recipes) > p.Limit
+	if more {
+		recipes = recipes[:p.Limit]
+	}
+	if p.backward() {
+		for i, j := 0, len(recipes)-1; i < j; i, j = i+1, j-1 {
+			recipes[i], recipes[j] = recipes[j], recipes[i]
+		}
+	}
+	var first, last *Cursor
+	if len(recipes) > 0 {
+		first, last = &Cursor{ID: recipes[0].ID}, &Cursor{ID: recipes[len(recipes)-1].ID}
+	}
+	return recipes, pageInfo(p, first, last, more), nil
+}
+
+// GetRecipesRated returns a page of rated recipes.
+func (s *SQLStore) GetRecipesRated(ctx context.Context, rs RecipeSearch, p Page) ([]RecipeRated, PageInfo, error) {
+	if err := p.checkSort(rs.order()); err != nil {
+		return nil, PageInfo{}, err
+	}
+	query, args := searchSQL(rs, p)
+	rows, err := s.db().QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	defer rows.Close()
+	recipesRated := []RecipeRated{}
+	var cursors []*Cursor
+	for rows.Next() {
+		var rr RecipeRated
+		var prepTime, avgRating, relevance float64
+		if err := rows.Scan(&rr.ID, &rr.Name, &prepTime, &rr.Difficulty, &rr.Vegetarian, &avgRating, &rr.RatingCount,
+			&relevance); err != nil {
+			return nil, PageInfo{}, err
+		}
+		rr.PrepTime, rr.AvgRating, rr.Relevance = float32(prepTime), float32(avgRating), float32(relevance)
+		// the cursor keeps the sort key at full precision, to compare
+		// equal to the database's own value
+		c := rs.cursor(rr)
+		switch key, _ := rs.sortKey(); key {
+		case "preptime":
+			c.Value = prepTime
+		case "rating":
+			c.Value = avgRating
+		case "relevance":
+			c.Value = relevance
+		}
+		recipesRated = append(recipesRated, rr)
+		cursors = append(cursors, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	more := len(recipesRated) > p.Limit
+	if more {
+		recipesRated, cursors = recipesRated[:p.Limit], cursors[:p.Limit]
+	}
+	if p.backward() {
+		for i, j := 0, len(recipesRated)-1; i < j; i, j = i+1, j-1 {
+			recipesRated[i], recipesRated[j] = recipesRated[j], recipesRated[i]
+			cursors[i], cursors[j] = cursors[j], cursors[i]
+		}
+	}
+	var first, last *Cursor
+	if len(cursors) > 0 {
+		first, last = cursors[0], cursors[len(cursors)-1]
+	}
+	return recipesRated, pageInfo(p, first, last, more), nil
+}
+
+// sortColumns maps the search sort keys to columns of the rated recipes.
+var sortColumns = map[string]string{
+	"name":      "name",
+	"preptime":  "preptime",
+	"rating":    "avg_rating",
+	"relevance": "relevance",
+}
+
+// searchSQL translates a search into a query for a page of rated recipes,
+// reading one row more than the page to tell whether there are more. Every
+// value from the search is passed as a parameter; only fixed SQL is concatenated.
+func searchSQL(rs RecipeSearch, p Page) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	// the recipes matching the query are found through the search index
+	from := " FROM recipes AS r LEFT JOIN recipe_rating_stats AS s ON s.recipe_id = r.id"
+	relevance := "CAST(0 AS FLOAT)"
+	if terms := rs.terms(); len(terms) > 0 {
+		from += " JOIN (" + matchSQL(terms, param) + ") AS m ON m.recipe_id = r.id"
+		relevance = "m.relevance"
+	}
+	if rs.MinDifficulty > 0 {
+		conditions = append(conditions, "difficulty >= "+param(rs.MinDifficulty))
+	}
+	if rs.MaxDifficulty > 0 {
+		conditions = append(conditions, "difficulty <= "+param(rs.MaxDifficulty))
+	}
+	if rs.Vegetarian != nil {
+		conditions = append(conditions, "vegetarian = "+param(*rs.Vegetarian))
+	}
+	if rs.MinRating > 0 {
+		conditions = append(conditions, "avg_rating >= "+param(rs.MinRating))
+	}
+	if rs.MinPrepTime != nil {
+		conditions = append(conditions, "preptime >= "+param(*rs.MinPrepTime))
+	}
+	if rs.MaxPrepTime != nil {
+		conditions = append(conditions, "preptime <= "+param(*rs.MaxPrepTime))
+	}
+	if rs.PrepTimeUnder != nil {
+		conditions = append(conditions, "preptime < "+param(*rs.PrepTimeUnder))
+	}
+	for _, ingredient := range rs.Ingredients {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM ingredients AS i "+
+			"WHERE i.recipe_id = rated.id AND lower(i.name) = lower("+param(ingredient)+"))")
+	}
+	for _, ingredient := range rs.ExcludeIngredients {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM ingredients AS i "+
+			"WHERE i.recipe_id = rated.id AND lower(i.name) = lower("+param(ingredient)+"))")
+	}
+
+	key, desc := rs.sortKey()
+	column := sortColumns[key]
+	if p.Cursor != nil {
+		// rows after the cursor in the listing's order or, reading
+		// backward, before it
+		keyOp, idOp := ">", ">"
+		if desc {
+			keyOp = "<"
+		}
+		if p.backward() {
+			keyOp, idOp = flip(keyOp), flip(idOp)
+		}
+		idCondition := "id " + idOp + " " + param(p.Cursor.ID)
+		if column == "" {
+			conditions = append(conditions, idCondition)
+		} else {
+			var value interface{} = p.Cursor.Value
+			if key == "name" {
+				value = p.Cursor.Name
+			}
+			v := param(value)
+			conditions = append(conditions, "("+column+" "+keyOp+" "+v+" OR ("+column+" = "+v+" AND "+idCondition+"))")
+		}
+	}
+
+	query := "SELECT id, name, preptime, difficulty, vegetarian, avg_rating, rating_count, relevance FROM (" +
+		"SELECT r.id, r.name, r.preptime, r.difficulty, r.vegetarian, " +
+		"COALESCE(s.avg_rating, 0) AS avg_rating, COALESCE(s.count, 0) AS rating_count, " + relevance + " AS relevance" +
+		from + ") AS rated"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// the order is reversed to read backward, and the page reversed again after
+	keyDir, idDir := "", ""
+	if desc != p.backward() {
+		keyDir = " DESC"
+	}
+	if p.backward() {
+		idDir = " DESC"
+	}
+	if column == "" {
+		query += " ORDER BY id" + idDir
+	} else {
+		query += " ORDER BY " + column + keyDir + ", id" + idDir
+	}
+	query += " LIMIT " + param(p.Limit+1)
+	return query, args
+}
+
+// flip reverses a comparison.
+func flip(op string) string {
+	if op == ">" {
+		return "<"
+	}
+	return ">"
+}
@@ -0,0 +1,83 @@
+package recipes
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"tracing"
+)
+
+// db returns the database to query outside a transaction.
+func (s *SQLStore) db() queryer {
+	return tracedQueryer{s.DB}
+}
+
+// executeTx runs fn in a transaction with Retry.ExecuteTx, tracing the
+// transaction, its attempts and each query in it. fn is passed the context
+// to make its queries with.
+func (s *SQLStore) executeTx(ctx context.Context, fn func(ctx context.Context, tx queryer) error) error {
+	ctx, span := tracing.Start(ctx, "transaction", tracing.Client)
+	attempts := 0
+	err := s.Retry.ExecuteTx(ctx, s.DB, func(tx *sql.Tx) error {
+		attempts++
+		return fn(ctx, tracedQueryer{tx})
+	})
+	span.SetAttributes("db.system", "cockroachdb", "db.attempts", attempts)
+	if err != nil && err != ErrNotFound {
+		span.SetError(err)
+	}
+	span.End()
+	return err
+}
+
+// tracedQueryer traces each query as a child of the span carried by its
+// context, if there is one, named after its operation such as SELECT. The
+// span of a Query ends when the query has been sent, before its rows are
+// read. A QueryRow fails when its row is scanned, so its span does not
+// record the error; that of the transaction does.
+type tracedQueryer struct {
+	q queryer
+}
+
+func startQuery(ctx context.Context, query string) *tracing.Span {
+	if tracing.SpanFromContext(ctx) == nil {
+		return nil
+	}
+	operation := query
+	if i := strings.IndexAny(operation, " \n\t"); i > 0 {
+		operation = operation[:i]
+	}
+	operation = strings.ToUpper(operation)
+	_, span := tracing.Start(ctx, operation, tracing.Client)
+	span.SetAttributes("db.system", "cockroachdb", "db.operation", operation, "db.statement", query)
+	return span
+}
+
+func endQuery(span *tracing.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+func (t tracedQueryer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	span := startQuery(ctx, query)
+	res, err := t.q.ExecContext(ctx, query, args...)
+	endQuery(span, err)
+	return res, err
+}
+
+func (t tracedQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	span := startQuery(ctx, query)
+	rows, err := t.q.QueryContext(ctx, query, args...)
+	endQuery(span, err)
+	return rows, err
+}
+
+func (t tracedQueryer) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	span := startQuery(ctx, query)
+	row := t.q.QueryRowContext(ctx, query, args...)
+	span.End()
+	return row
+}
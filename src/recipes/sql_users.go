@@ -0,0 +1,43 @@
+package recipes
+
+import "context"
+
+// CreateUser is used to register a new user.
+func (s *SQLStore) CreateUser(ctx context.Context, u *User) error {
+	if u.Role == "" {
+		u.Role = DefaultRole
+	}
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO users(username, password_hash, role) VALUES($1, $2, $3) RETURNING user_id, created_at",
+			u.Username, u.PasswordHash, u.Role).Scan(&u.ID, &u.CreatedAt)
+		if IsConflict(err) {
+			return ErrConflict
+		}
+		return err
+	})
+}
+
+// GetUser returns a single specified user.
+func (s *SQLStore) GetUser(ctx context.Context, u *User) error {
+	err := s.db().QueryRowContext(ctx, "SELECT username, password_hash, role, created_at FROM users WHERE user_id=$1",
+		u.ID).Scan(&u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	return notFound(err)
+}
+
+// GetUserByName returns the user with a specified username.
+func (s *SQLStore) GetUserByName(ctx context.Context, u *User) error {
+	err := s.db().QueryRowContext(ctx, "SELECT user_id, password_hash, role, created_at FROM users WHERE username=$1",
+		u.Username).Scan(&u.ID, &u.PasswordHash, &u.Role, &u.CreatedAt)
+	return notFound(err)
+}
+
+// SetUserRole is used to change the role of a specific user.
+func (s *SQLStore) SetUserRole(ctx context.Context, u *User) error {
+	return s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		err := tx.QueryRowContext(ctx,
+			"UPDATE users SET role=$1 WHERE user_id=$2 RETURNING username, password_hash, created_at",
+			u.Role, u.ID).Scan(&u.Username, &u.PasswordHash, &u.CreatedAt)
+		return notFound(err)
+	})
+}
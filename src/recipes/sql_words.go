@@ -0,0 +1,83 @@
+package recipes
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// indexRecipe puts the words of a recipe's name into the search index,
+// first removing those of its old name if replace is set.
+func indexRecipe(ctx context.Context, q queryer, recipeID int, name string, replace bool) error {
+	if replace {
+		if _, err := q.ExecContext(ctx, "DELETE FROM recipe_words WHERE recipe_id=$1", recipeID); err != nil {
+			return err
+		}
+	}
+	words, count := indexWords(name)
+	if len(words) == 0 {
+		return nil
+	}
+	args := []interface{}{recipeID, count}
+	values := make([]string, len(words))
+	for i, word := range words {
+		args = append(args, word)
+		values[i] = "($" + strconv.Itoa(len(args)) + ", $1, $2)"
+	}
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO recipe_words(word, recipe_id, name_words) VALUES "+strings.Join(values, ", "), args...)
+	return err
+}
+
+// ReindexRecipes rebuilds the search index from the names of the recipes,
+// for recipes created before there was one.
+func (s *SQLStore) ReindexRecipes(ctx context.Context) (int, error) {
+	var n int
+	err := s.executeTx(ctx, func(ctx context.Context, tx queryer) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM recipe_words"); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT id, name FROM recipes")
+		if err != nil {
+			return err
+		}
+		var recipes []Recipe
+		for rows.Next() {
+			var r Recipe
+			if err := rows.Scan(&r.ID, &r.Name); err != nil {
+				rows.Close()
+				return err
+			}
+			recipes = append(recipes, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, r := range recipes {
+			if err := indexRecipe(ctx, tx, r.ID, r.Name, false); err != nil {
+				return err
+			}
+		}
+		n = len(recipes)
+		return nil
+	})
+	return n, err
+}
+
+// matchSQL returns a query for the recipes whose names match every term,
+// with their relevance, passing the terms with param. Each term is matched
+// through the primary key of the index, on the words it begins.
+func matchSQL(terms []string, param func(value interface{}) string) string {
+	// terms are only letters and digits, so cannot hold LIKE wildcards
+	selects := make([]string, len(terms))
+	for i, term := range terms {
+		selects[i] = "SELECT recipe_id, max(CASE WHEN word = " + param(term) +
+			" THEN CAST(" + strconv.FormatFloat(exactMatch, 'f', -1, 64) + " AS FLOAT)" +
+			" ELSE CAST(" + strconv.FormatFloat(prefixMatch, 'f', -1, 64) + " AS FLOAT) END) AS score," +
+			" max(name_words) AS name_words FROM recipe_words WHERE word LIKE " + param(term+"%") +
+			" GROUP BY recipe_id"
+	}
+	return "SELECT recipe_id, sum(score) / CAST(max(name_words) AS FLOAT) AS relevance FROM (" +
+		strings.Join(selects, " UNION ALL ") + ") AS terms GROUP BY recipe_id HAVING count(*) = " + param(len(terms))
+}
@@ -0,0 +1,104 @@
+package recipes
+
+import "context"
+
+// RecipeStore is the persistence layer for recipes, their ingredients,
+// their steps and their ratings, and for the users who rate them. Methods
+// return ErrNotFound if the recipe, ingredient, step, rating or user they
+// refer to does not exist.
+type RecipeStore interface {
+	// GetRecipe fills in the recipe identified by r.ID, including its
+	// ingredients and steps.
+	GetRecipe(ctx context.Context, r *Recipe) error
+	// GetRecipeOwner returns the user ID of the owner of a recipe, or zero
+	// if it has none.
+	GetRecipeOwner(ctx context.Context, recipeID int) (int, error)
+	// GetRecipes returns a page of recipes in ID order, and the cursors for
+	// the pages either side. It returns ErrInvalidCursor if the page's cursor
+	// is from another listing.
+	GetRecipes(ctx context.Context, p Page) ([]Recipe, PageInfo, error)
+	// CreateRecipe stores a new recipe, along with any ingredients and steps,
+	// and sets r.ID and the ingredient and step IDs.
+	CreateRecipe(ctx context.Context, r *Recipe) error
+	// UpdateRecipe replaces the recipe identified by r.ID. Its owner,
+	// ingredients and steps are left as they are, and are loaded into r.
+	UpdateRecipe(ctx context.Context, r *Recipe) error
+	// PatchRecipe fills in the recipe identified by r.ID, as GetRecipe does,
+	// calls patch to change it, and stores the result as UpdateRecipe does,
+	// all in one transaction, so that a change made in between is not lost.
+	// patch may be called again if the transaction is retried; if it fails,
+	// nothing is stored and its error is returned. patch should return
+	// ErrPatchRejected if the patch does not apply, so that it is not
+	// taken for a failure of the store.
+	PatchRecipe(ctx context.Context, r *Recipe, patch func(r *Recipe) error) error
+	// DeleteRecipe removes the recipe identified by r.ID, along with its
+	// ingredients, steps and ratings.
+	DeleteRecipe(ctx context.Context, r *Recipe) error
+	// GetRecipesRated returns a page of the recipes which match the search,
+	// along with their average ratings, and the cursors for the pages either
+	// side. The ratings are read from the rating statistics. It returns
+	// ErrInvalidCursor if the page's cursor is from a listing with a different
+	// sort order.
+	GetRecipesRated(ctx context.Context, rs RecipeSearch, p Page) ([]RecipeRated, PageInfo, error)
+	// ReindexRecipes rebuilds the search index, which the query of a
+	// search is matched against, from the names of the recipes, and returns
+	// the number of recipes indexed. The index is kept up to date as
+	// recipes are written, so this is only needed for recipes created
+	// before there was one.
+	ReindexRecipes(ctx context.Context) (int, error)
+
+	// AddRecipeRating stores a rating, updating the recipe's rating
+	// statistics, and sets rr.ID. A user's earlier rating of the recipe is
+	// replaced, keeping its ID; created reports whether the rating is new.
+	// Ratings without a UserID are always added.
+	AddRecipeRating(ctx context.Context, rr *RecipeRating) (created bool, err error)
+	// WithdrawRecipeRating removes the rating of recipe rr.RecipeID by user
+	// rr.UserID, updating the recipe's rating statistics, and sets rr.ID and
+	// rr.Rating.
+	WithdrawRecipeRating(ctx context.Context, rr *RecipeRating) error
+	// DeleteRecipeRating removes the rating identified by rr.RecipeID and
+	// rr.ID, updating the recipe's rating statistics, and sets rr.Rating.
+	DeleteRecipeRating(ctx context.Context, rr *RecipeRating) error
+	// GetRatingStats returns the rating statistics of a recipe.
+	GetRatingStats(ctx context.Context, recipeID int) (RatingStats, error)
+	// RecomputeRatingStats rebuilds the rating statistics of every recipe
+	// from its ratings, and returns the number of recipes which are rated.
+	RecomputeRatingStats(ctx context.Context) (int, error)
+
+	// CreateUser stores a new user and sets u.ID and u.CreatedAt, and u.Role
+	// to DefaultRole if it is empty. It returns ErrConflict if the username
+	// is taken.
+	CreateUser(ctx context.Context, u *User) error
+	// GetUser fills in the user identified by u.ID.
+	GetUser(ctx context.Context, u *User) error
+	// GetUserByName fills in the user identified by u.Username.
+	GetUserByName(ctx context.Context, u *User) error
+	// SetUserRole changes the role of the user identified by u.ID to u.Role,
+	// and fills in the rest of the user.
+	SetUserRole(ctx context.Context, u *User) error
+
+	// GetIngredients returns the ingredients of a recipe.
+	GetIngredients(ctx context.Context, recipeID int) ([]Ingredient, error)
+	// GetIngredient fills in the ingredient identified by i.RecipeID and i.ID.
+	GetIngredient(ctx context.Context, i *Ingredient) error
+	// AddIngredient stores a new ingredient of recipe i.RecipeID and sets i.ID.
+	AddIngredient(ctx context.Context, i *Ingredient) error
+	// UpdateIngredient replaces the ingredient identified by i.RecipeID and i.ID.
+	UpdateIngredient(ctx context.Context, i *Ingredient) error
+	// DeleteIngredient removes the ingredient identified by i.RecipeID and i.ID.
+	DeleteIngredient(ctx context.Context, i *Ingredient) error
+
+	// GetSteps returns the steps of a recipe in order.
+	GetSteps(ctx context.Context, recipeID int) ([]Step, error)
+	// AddStep appends a step to recipe st.RecipeID and sets st.ID and st.Position.
+	AddStep(ctx context.Context, st *Step) error
+	// UpdateStep replaces the text, duration and timer of the step
+	// identified by st.RecipeID and st.ID, and sets st.Position.
+	UpdateStep(ctx context.Context, st *Step) error
+	// DeleteStep removes the step identified by st.RecipeID and st.ID,
+	// renumbering the steps after it.
+	DeleteStep(ctx context.Context, st *Step) error
+	// ReorderSteps puts the steps of a recipe into the order of stepIDs, which
+	// must list each step exactly once, and returns the reordered steps.
+	ReorderSteps(ctx context.Context, recipeID int, stepIDs []int) ([]Step, error)
+}
@@ -0,0 +1,41 @@
+package recipes
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost is the bcrypt cost of new password hashes.
+var PasswordCost = bcrypt.DefaultCost
+
+// dummyHash is checked in place of the hash of a user who does not exist,
+// so that how long a login takes does not tell whether they do.
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// SetPassword sets the user's password hash.
+func (u *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword reports whether password is the user's password. A user
+// without a password hash, such as one who was not found, never matches,
+// but is checked against a dummy hash which takes as long.
+func (u User) CheckPassword(password string) bool {
+	if u.PasswordHash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), PasswordCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
@@ -0,0 +1,190 @@
+package recipes
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validation is declared with `validate` struct tags holding a comma-separated
+// list of rules:
+//
+//	required   a string must not be blank
+//	minlen=N   a string must have at least N characters
+//	maxlen=N   a string may have at most N characters, a slice at most N items
+//	maxbytes=N a string may have at most N bytes in UTF-8
+//	min=N      a number must be at least N
+//	max=N      a number must be at most N
+//	oneof=A B  a string, if set, must be one of the space-separated values
+//
+// Number rules on a pointer field only apply when it is set. Slices of structs
+// are validated item by item.
+
+// FieldError describes why a single field is invalid.
+type FieldError struct {
+	// Field is the JSON path of the field, such as ingredients[0].name
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError lists every invalid field of an entity.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + " " + f.Message
+	}
+	return "invalid: " + strings.Join(problems, "; ")
+}
+
+// Validate checks the recipe, including any ingredients and steps.
+func (r *Recipe) Validate() error {
+	return Validate(r)
+}
+
+// Validate checks the ingredient.
+func (i *Ingredient) Validate() error {
+	return Validate(i)
+}
+
+// Validate checks the step.
+func (st *Step) Validate() error {
+	return Validate(st)
+}
+
+// Validate checks the rating.
+func (rr *RecipeRating) Validate() error {
+	return Validate(rr)
+}
+
+// Validate checks the username and password.
+func (c *Credentials) Validate() error {
+	return Validate(c)
+}
+
+// Validate checks the role.
+func (rc *RoleChange) Validate() error {
+	return Validate(rc)
+}
+
+// Validate checks a struct against its `validate` tags and returns
+// a *ValidationError if any of its fields are invalid.
+func Validate(v interface{}) error {
+	var fields []FieldError
+	validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, fields *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		path := prefix + name
+		fv := v.Field(i)
+		for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			if problem := checkRule(fv, rule); problem != nil {
+				problem.Field = path
+				*fields = append(*fields, *problem)
+			}
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < fv.Len(); j++ {
+				validateStruct(fv.Index(j), fmt.Sprintf("%s[%d].", path, j), fields)
+			}
+		}
+	}
+}
+
+// jsonName returns the name of the field in JSON.
+func jsonName(sf reflect.StructField) string {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func checkRule(v reflect.Value, rule string) *FieldError {
+	name, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch name {
+	case "required":
+		if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" {
+			return &FieldError{Code: "required", Message: "is required"}
+		}
+	case "minlen":
+		min, _ := strconv.Atoi(arg)
+		if v.Kind() == reflect.String && v.String() != "" && utf8.RuneCountInString(v.String()) < min {
+			return &FieldError{Code: "too_short", Message: fmt.Sprintf("must be at least %d characters", min)}
+		}
+	case "maxlen":
+		max, _ := strconv.Atoi(arg)
+		switch v.Kind() {
+		case reflect.String:
+			if utf8.RuneCountInString(v.String()) > max {
+				return &FieldError{Code: "too_long", Message: fmt.Sprintf("must be at most %d characters", max)}
+			}
+		case reflect.Slice:
+			if v.Len() > max {
+				return &FieldError{Code: "too_long", Message: fmt.Sprintf("must have at most %d items", max)}
+			}
+		}
+	case "maxbytes":
+		max, _ := strconv.Atoi(arg)
+		if v.Kind() == reflect.String && len(v.String()) > max {
+			return &FieldError{Code: "too_long", Message: fmt.Sprintf("must be at most %d bytes", max)}
+		}
+	case "oneof":
+		if v.Kind() == reflect.String && v.String() != "" {
+			for _, allowed := range strings.Fields(arg) {
+				if v.String() == allowed {
+					return nil
+				}
+			}
+			return &FieldError{Code: "not_allowed", Message: "must be one of " + strings.Join(strings.Fields(arg), ", ")}
+		}
+	case "min", "max":
+		limit, _ := strconv.ParseFloat(arg, 64)
+		var n float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(v.Int())
+		case reflect.Float32, reflect.Float64:
+			n = v.Float()
+		default:
+			return nil
+		}
+		if name == "min" && n < limit {
+			return &FieldError{Code: "too_small", Message: "must be at least " + arg}
+		}
+		if name == "max" && n > limit {
+			return &FieldError{Code: "too_large", Message: "must be at most " + arg}
+		}
+	}
+	return nil
+}
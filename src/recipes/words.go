@@ -0,0 +1,90 @@
+package recipes
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTerms is the most words of a query which are searched for; the rest
+// are ignored.
+const maxTerms = 10
+
+// Relevance is scored for each word of a query which matches a word of a
+// recipe's name, exactly or as its beginning, and is divided by the number
+// of words in the name, so that a name which is mostly the query ranks first.
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.5
+)
+
+// splitWords splits text into lower-case words of letters and digits, with
+// their plurals folded, in order and with any repeats.
+func splitWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+	for i, word := range words {
+		words[i] = stem(word)
+	}
+	return words
+}
+
+// stem folds the plural of an English word into its singular, so that
+// "tomatoes" finds "tomato" and "eggs" finds "egg". Only the suffix is
+// looked at, so it is a guess; it never lengthens the word, which keeps a
+// stemmed query word matching the beginning of what it would have matched.
+func stem(word string) string {
+	n := len(word)
+	switch {
+	case n <= 3:
+		return word
+	case strings.HasSuffix(word, "ies") && n > 4:
+		return word[:n-3] + "y"
+	case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "ches"),
+		strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "sses"):
+		return word[:n-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:n-1]
+	}
+	return word
+}
+
+// indexWords returns the distinct words of a recipe name, as they are
+// indexed, and the number of words in the name.
+func indexWords(name string) ([]string, int) {
+	all := splitWords(name)
+	seen := map[string]bool{}
+	var words []string
+	for _, word := range all {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words, len(all)
+}
+
+// relevance scores a name, indexed as words with count words in all,
+// against the terms of a query. It reports false if any term matches none
+// of the words.
+func relevance(terms []string, words []string, count int) (float64, bool) {
+	var score float64
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			if word == term {
+				best = exactMatch
+				break
+			}
+			if strings.HasPrefix(word, term) {
+				best = prefixMatch
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score / float64(count), true
+}
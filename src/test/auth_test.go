@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	// local import
+	"application"
+	"auth"
+	"recipes"
+)
+
+func TestAnonymousReads(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	response := executeAnonymous(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("POST", "/v1/recipes", bytes.NewBufferString(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`))
+	response = executeAnonymous(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	checkProblem(t, response, "unauthorized", "Authentication is required")
+}
+
+func TestRequireReadScope(t *testing.T) {
+	reader := auth.APIKey{Name: "reader", Key: strings.Repeat("r", 32), Scopes: []string{auth.ScopeRecipesRead}}
+	private := application.App{RequireReadScope: true, APIKeys: []auth.APIKey{reader}}
+	private.InitializeWithStore(recipes.NewMemoryStore())
+
+	req, _ := http.NewRequest("GET", "/v1/recipes", nil)
+	response := serve(&private, req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, _ = http.NewRequest("GET", "/v1/recipes", nil)
+	req.Header.Set("X-API-Key", reader.Key)
+	response = serve(&private, req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+// TestReadWithoutReadScope checks that a caller whose credentials lack the
+// read scope may still read what anonymous callers may, unless it is required.
+func TestReadWithoutReadScope(t *testing.T) {
+	rater := auth.APIKey{Name: "rater", Key: strings.Repeat("w", 32), Scopes: []string{auth.ScopeRatingsWrite}}
+	for _, required := range []bool{false, true} {
+		app := application.App{RequireReadScope: required, APIKeys: []auth.APIKey{rater}}
+		app.InitializeWithStore(recipes.NewMemoryStore())
+
+		req, _ := http.NewRequest("GET", "/v1/recipes", nil)
+		req.Header.Set("X-API-Key", rater.Key)
+		response := serve(&app, req)
+		if required {
+			checkResponseCode(t, http.StatusForbidden, response.Code)
+			checkProblem(t, response, "forbidden", "The recipes:read scope is required")
+		} else {
+			checkResponseCode(t, http.StatusOK, response.Code)
+		}
+	}
+}
+
+func TestInvalidAPIKey(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/recipes", nil)
+	req.Header.Set("X-API-Key", strings.Repeat("x", 32))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	checkProblem(t, response, "unauthorized", "Invalid API key")
+}
+
+func TestScopes(t *testing.T) {
+	reader := auth.APIKey{Name: "reader", Key: strings.Repeat("r", 32), Scopes: []string{auth.ScopeRecipesRead}}
+	scoped := application.App{APIKeys: []auth.APIKey{reader}}
+	scoped.InitializeWithStore(recipes.NewMemoryStore())
+
+	req, _ := http.NewRequest("POST", "/v1/recipes", bytes.NewBufferString(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`))
+	req.Header.Set("X-API-Key", reader.Key)
+	response := serve(&scoped, req)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	checkProblem(t, response, "forbidden", "The recipes:write scope is required")
+
+	// users may rate recipes, but not moderate the ratings of others
+	clearTables()
+	addRecipes(1)
+	token := newUserToken(t)
+	response = postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":3}`, token)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating/1", "", token)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	checkProblem(t, response, "forbidden", "The ratings:moderate scope is required")
+}
+
+func TestAPIKeyCannotRate(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	req, _ := http.NewRequest("POST", "/v1/recipes/1/rating", bytes.NewBufferString(`{"rating":3}`))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	checkProblem(t, response, "forbidden", "")
+}
+
+func TestRSATokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "recipes-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	privateFile := filepath.Join(dir, "private.pem")
+	writePEM(t, privateFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicFile := filepath.Join(dir, "public.pem")
+	writePEM(t, publicFile, "PUBLIC KEY", publicDER)
+
+	privateKey, err := auth.LoadRSAPrivateKey(privateFile)
+	if err != nil {
+		t.Fatalf("Expected the private key to load. Got '%v'", err)
+	}
+	publicKey, err := auth.LoadRSAPublicKey(publicFile)
+	if err != nil {
+		t.Fatalf("Expected the public key to load. Got '%v'", err)
+	}
+
+	// another service signs tokens with the private key, which this one
+	// verifies with the public key
+	issuer := auth.NewRSASigner(privateKey)
+	verifier := application.App{TrustedKeys: []*auth.Signer{auth.NewRSAVerifier(publicKey)}}
+	verifier.InitializeWithStore(recipes.NewMemoryStore())
+
+	token, err := issuer.Issue(auth.Claims{Subject: "1", UserID: 1, Scope: auth.ScopeRecipesWrite}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", "/v1/recipes", bytes.NewBufferString(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	response := serve(&verifier, req)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// the HMAC signer of this service must not accept an RS256 token
+	req, _ = http.NewRequest("POST", "/v1/recipes", bytes.NewBufferString(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	checkProblem(t, response, "unauthorized", "Invalid or expired token")
+
+	expired, _ := issuer.Issue(auth.Claims{Subject: "1", UserID: 1, Scope: auth.ScopeRecipesWrite}, -time.Minute)
+	if _, err := verifier.Tokens.Verify(expired); err != auth.ErrInvalidToken {
+		t.Errorf("Expected an RS256 token to be invalid for an HS256 signer. Got '%v'", err)
+	}
+	if _, err := auth.NewRSAVerifier(publicKey).Verify(expired); err != auth.ErrExpiredToken {
+		t.Errorf("Expected '%v'. Got '%v'", auth.ErrExpiredToken, err)
+	}
+	if _, err := auth.NewRSAVerifier(publicKey).Sign(auth.Claims{}); err != auth.ErrCannotSign {
+		t.Errorf("Expected '%v'. Got '%v'", auth.ErrCannotSign, err)
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	file, err := ioutil.TempFile("", "recipes-api-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`[{"name":"importer","key":"` + strings.Repeat("i", 32) + `","scopes":["recipes:write"]}]`)
+	file.Close()
+
+	keys, err := auth.LoadAPIKeys(file.Name())
+	if err != nil {
+		t.Fatalf("Expected the API keys to load. Got '%v'", err)
+	}
+	if len(keys) != 1 || keys[0].Name != "importer" || len(keys[0].Scopes) != 1 {
+		t.Errorf("Expected the importer key. Got '%v'", keys)
+	}
+
+	ioutil.WriteFile(file.Name(), []byte(`[{"name":"short","key":"abc","scopes":[]}]`), 0600)
+	if _, err := auth.LoadAPIKeys(file.Name()); err == nil {
+		t.Error("Expected a short API key to be rejected")
+	}
+}
+
+func TestLoginTokenScopes(t *testing.T) {
+	clearTables()
+	token := newUserToken(t)
+
+	claims, err := app.Tokens.Verify(token)
+	if err != nil {
+		t.Fatalf("Expected the login token to verify. Got '%v'", err)
+	}
+	if claims.Scope != "recipes:read recipes:write ratings:write" {
+		t.Errorf("Expected the user scopes. Got '%v'", claims.Scope)
+	}
+	if claims.Subject != strconv.Itoa(claims.UserID) {
+		t.Errorf("Expected the subject to be the user ID. Got '%v'", claims.Subject)
+	}
+}
+
+// serve sends a request to an app other than the one shared by the tests.
+func serve(a *application.App, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func writePEM(t *testing.T, file string, blockType string, der []byte) {
+	b := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	// local import
+	"client"
+	"recipes"
+)
+
+// newClient returns a client of the app, served by an httptest server,
+// which uses the admin API key.
+func newClient() (*client.Client, func()) {
+	server := httptest.NewServer(app.Router)
+	c := client.New(server.URL)
+	c.APIKey = adminKey.Key
+	c.Retry = client.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return c, server.Close
+}
+
+// flakyServer answers each request with the status of the next of statuses,
+// as a problem document with code, and then with an empty recipe. It
+// counts the requests it is sent.
+func flakyServer(code string, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n <= len(statuses) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.Header().Set("X-Request-ID", "flaky")
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte(`{"type":"about:blank","status":` + strconv.Itoa(statuses[n-1]) +
+				`,"code":"` + code + `","detail":"Try again"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"soup","preptime":0.5,"difficulty":1,"vegetarian":true}`))
+	}))
+	return server, &requests
+}
+
+func TestClientRecipes(t *testing.T) {
+	clearTables()
+	c, stop := newClient()
+	defer stop()
+	ctx := context.Background()
+
+	r := recipes.Recipe{Name: "pancakes", PrepTime: 0.3, Difficulty: 1, Vegetarian: true,
+		Ingredients: []recipes.Ingredient{{Name: "flour", Quantity: 100, Unit: "g"}},
+		Steps:       []recipes.Step{{Text: "Whisk"}, {Text: "Fry"}}}
+	if err := c.CreateRecipe(ctx, &r); err != nil {
+		t.Fatalf("Expected the recipe to be created. Got '%v'", err)
+	}
+	if r.ID == 0 || len(r.Ingredients) != 1 || r.Ingredients[0].ID == 0 || len(r.Steps) != 2 {
+		t.Errorf("Expected the created recipe. Got '%v'", r)
+	}
+
+	got, err := c.GetRecipe(ctx, r.ID)
+	if err != nil || got.Name != "pancakes" || len(got.Steps) != 2 {
+		t.Errorf("Expected the recipe. Got '%v', '%v'", got, err)
+	}
+
+	r.Difficulty, r.Ingredients, r.Steps = 2, nil, nil
+	if err := c.UpdateRecipe(ctx, &r); err != nil || r.Difficulty != 2 {
+		t.Errorf("Expected the recipe to be updated. Got '%v', '%v'", r, err)
+	}
+	patched, err := c.PatchRecipe(ctx, r.ID, map[string]interface{}{"name": "crepes"})
+	if err != nil || patched.Name != "crepes" || patched.Difficulty != 2 {
+		t.Errorf("Expected the recipe to be renamed. Got '%v', '%v'", patched, err)
+	}
+
+	i := recipes.Ingredient{RecipeID: r.ID, Name: "milk", Quantity: 300, Unit: "ml"}
+	if err := c.AddIngredient(ctx, &i); err != nil || i.ID == 0 {
+		t.Errorf("Expected the ingredient to be added. Got '%v', '%v'", i, err)
+	}
+	s := recipes.Step{RecipeID: r.ID, Text: "Serve"}
+	if err := c.AddStep(ctx, &s); err != nil || s.Position != 3 {
+		t.Errorf("Expected the step to be added. Got '%v', '%v'", s, err)
+	}
+
+	if err := c.DeleteRecipe(ctx, r.ID); err != nil {
+		t.Errorf("Expected the recipe to be deleted. Got '%v'", err)
+	}
+	_, err = c.GetRecipe(ctx, r.ID)
+	if !client.IsNotFound(err) {
+		t.Fatalf("Expected the recipe not to be found. Got '%v'", err)
+	}
+	if e := err.(*client.Error); e.StatusCode != http.StatusNotFound || e.Detail != "Recipe not found" || e.RequestID == "" {
+		t.Errorf("Expected the problem document. Got '%#v'", e)
+	}
+}
+
+func TestClientUpdateKeepsIngredientsAndSteps(t *testing.T) {
+	clearTables()
+	c, stop := newClient()
+	defer stop()
+	ctx := context.Background()
+
+	r := recipes.Recipe{Name: "pancakes", PrepTime: 0.3, Difficulty: 1, Vegetarian: true,
+		Ingredients: []recipes.Ingredient{{Name: "flour", Quantity: 100, Unit: "g"}},
+		Steps:       []recipes.Step{{Text: "Whisk"}, {Text: "Fry"}}}
+	if err := c.CreateRecipe(ctx, &r); err != nil {
+		t.Fatalf("Expected the recipe to be created. Got '%v'", err)
+	}
+
+	r.Name = "crepes"
+	r.Ingredients = []recipes.Ingredient{{Name: "milk", Quantity: 300, Unit: "ml"}}
+	r.Steps = nil
+	if err := c.UpdateRecipe(ctx, &r); err != nil || r.Name != "crepes" {
+		t.Fatalf("Expected the recipe to be updated. Got '%v', '%v'", r, err)
+	}
+	got, err := c.GetRecipe(ctx, r.ID)
+	if err != nil {
+		t.Fatalf("Expected the recipe. Got '%v'", err)
+	}
+	for _, updated := range []*recipes.Recipe{&r, got} {
+		if len(updated.Ingredients) != 1 || updated.Ingredients[0].Name != "flour" {
+			t.Errorf("Expected the ingredients to be kept. Got '%v'", updated.Ingredients)
+		}
+		if len(updated.Steps) != 2 || updated.Steps[0].Text != "Whisk" || updated.Steps[1].Text != "Fry" {
+			t.Errorf("Expected the steps to be kept. Got '%v'", updated.Steps)
+		}
+	}
+}
+
+func TestClientValidationError(t *testing.T) {
+	clearTables()
+	c, stop := newClient()
+	defer stop()
+
+	err := c.CreateRecipe(context.Background(), &recipes.Recipe{Name: "soup", Difficulty: 4})
+	e, ok := err.(*client.Error)
+	if !ok || e.Code != client.CodeValidationFailed {
+		t.Fatalf("Expected a validation error. Got '%v'", err)
+	}
+	if len(e.Fields) != 1 || e.Fields[0].Field != "difficulty" || e.Fields[0].Code != "too_large" {
+		t.Errorf("Expected the difficulty to be too large. Got '%v'", e.Fields)
+	}
+}
+
+func TestClientPagination(t *testing.T) {
+	clearTables()
+	addRecipes(25)
+	c, stop := newClient()
+	defer stop()
+
+	page, err := c.ListRecipes(context.Background(), client.PageOptions{Count: 10})
+	if err != nil || len(page.Recipes) != 10 || page.Page.Next == "" || page.Page.Prev != "" {
+		t.Fatalf("Expected the first page of 10. Got '%v', '%v'", page, err)
+	}
+
+	it := c.AllRecipes(context.Background(), 10)
+	var ids []int
+	for it.Next() {
+		ids = append(ids, it.Recipe().ID)
+	}
+	if it.Err() != nil {
+		t.Fatalf("Expected every page. Got '%v'", it.Err())
+	}
+	if len(ids) != 25 {
+		t.Fatalf("Expected '25' recipes. Got '%d'", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("Expected the recipes in ID order. Got '%v'", ids)
+			break
+		}
+	}
+}
+
+func TestClientSearchAndRate(t *testing.T) {
+	clearTables()
+	addRecipes(3)
+	c, stop := newClient()
+	defer stop()
+	ctx := context.Background()
+
+	if _, err := c.Register(ctx, "client user", "correct horse"); err != nil {
+		t.Fatalf("Expected the user to be registered. Got '%v'", err)
+	}
+	token, err := c.Login(ctx, "client user", "correct horse")
+	if err != nil || token.TokenType != "Bearer" {
+		t.Fatalf("Expected a token. Got '%v', '%v'", token, err)
+	}
+	c.Token = token.AccessToken
+	if u, err := c.CurrentUser(ctx); err != nil || u.Username != "client user" || u.Role != "author" {
+		t.Errorf("Expected the current user. Got '%v', '%v'", u, err)
+	}
+
+	if _, err := c.Rate(ctx, 2, 5); err != nil {
+		t.Fatalf("Expected the recipe to be rated. Got '%v'", err)
+	}
+	if rr, err := c.Rate(ctx, 2, 4); err != nil || rr.Rating != 4 {
+		t.Errorf("Expected the rating to be replaced. Got '%v', '%v'", rr, err)
+	}
+	if st, err := c.GetRatingStats(ctx, 2); err != nil || st.Count != 1 || st.AvgRating != 4 {
+		t.Errorf("Expected one rating of 4. Got '%v', '%v'", st, err)
+	}
+
+	it := c.SearchAll(ctx, recipes.RecipeSearch{Sort: "-rating"}, 2)
+	var found []recipes.RecipeRated
+	for it.Next() {
+		found = append(found, it.Recipe())
+	}
+	if it.Err() != nil || len(found) != 3 || found[0].ID != 2 || found[0].RatingCount != 1 {
+		t.Errorf("Expected the rated recipe first of '3'. Got '%v', '%v'", found, it.Err())
+	}
+
+	_, err = c.Search(ctx, recipes.RecipeSearch{Sort: "spiciness"}, client.PageOptions{})
+	if !client.HasCode(err, client.CodeValidationFailed) {
+		t.Errorf("Expected the sort to be rejected. Got '%v'", err)
+	}
+	if err := c.WithdrawRating(ctx, 2); err != nil {
+		t.Errorf("Expected the rating to be withdrawn. Got '%v'", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	server, requests := flakyServer(client.CodeUnavailable, http.StatusServiceUnavailable, http.StatusBadGateway)
+	defer server.Close()
+	c := client.New(server.URL)
+	c.Retry = client.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	if _, err := c.GetRecipe(context.Background(), 1); err != nil {
+		t.Errorf("Expected the read to be retried. Got '%v'", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected '3' requests. Got '%d'", *requests)
+	}
+}
+
+func TestClientRetriesExhausted(t *testing.T) {
+	server, requests := flakyServer(client.CodeUnavailable, 503, 503, 503, 503, 503)
+	defer server.Close()
+	c := client.New(server.URL)
+	c.Retry = client.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	_, err := c.GetRecipe(context.Background(), 1)
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != 503 || e.RequestID != "flaky" {
+		t.Errorf("Expected the last error. Got '%v'", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected '3' requests. Got '%d'", *requests)
+	}
+}
+
+func TestClientCreateNotRetried(t *testing.T) {
+	server, requests := flakyServer(client.CodeUnavailable, http.StatusServiceUnavailable)
+	defer server.Close()
+	c := client.New(server.URL)
+	c.Retry = client.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	err := c.CreateRecipe(context.Background(), &recipes.Recipe{Name: "soup", Difficulty: 1})
+	if !client.HasCode(err, client.CodeUnavailable) || *requests != 1 {
+		t.Errorf("Expected a create to be tried once. Got '%v' after '%d' requests", err, *requests)
+	}
+
+	// the service rolled back a transaction which conflicted with another
+	server, requests = flakyServer(client.CodeRetryable, http.StatusServiceUnavailable)
+	defer server.Close()
+	c.BaseURL = server.URL
+	if err := c.CreateRecipe(context.Background(), &recipes.Recipe{Name: "soup", Difficulty: 1}); err != nil || *requests != 2 {
+		t.Errorf("Expected a retryable create to be retried. Got '%v' after '%d' requests", err, *requests)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	c := client.New(server.URL)
+	c.Timeout = 20 * time.Millisecond
+	c.Retry = client.RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	start := time.Now()
+	if _, err := c.GetRecipe(context.Background(), 1); err == nil {
+		t.Error("Expected the request to time out")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Expected each attempt to be abandoned after '20ms'. Got '%v'", d)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected '2' attempts. Got '%d'", n)
+	}
+
+	// the deadline of the caller is not extended by retries
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	c.Timeout, c.Retry.MaxRetries = 0, 10
+	start = time.Now()
+	if _, err := c.GetRecipe(ctx, 1); err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected the request to stop at the caller's deadline. Got '%v' after '%v'", err, time.Since(start))
+	}
+}
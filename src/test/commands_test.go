@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	// local import
+	"commands"
+	"migrations"
+	"recipes"
+)
+
+// runCommand runs an administrative command against the app's store,
+// with in as its standard input, and returns what it wrote.
+func runCommand(in string, args ...string) (string, string, error) {
+	var out, errOut bytes.Buffer
+	env := commands.Env{DB: app.DB, Store: app.Store, In: strings.NewReader(in), Out: &out, Err: &errOut}
+	err := commands.Run(context.Background(), env, args)
+	return out.String(), errOut.String(), err
+}
+
+func TestCommandRecipes(t *testing.T) {
+	clearTables()
+
+	out, _, err := runCommand(`{"name": "pancakes", "preptime": 0.3, "difficulty": 1, "vegetarian": true,
+		"ingredients": [{"name": "flour", "quantity": 100, "unit": "g"}], "steps": [{"text": "Fry"}]}`,
+		"recipes", "create")
+	if err != nil || !strings.Contains(out, `"name": "pancakes"`) {
+		t.Fatalf("Expected the recipe to be created. Got '%s', '%v'", out, err)
+	}
+
+	out, _, err = runCommand("", "recipes", "get", "1")
+	if err != nil || !strings.Contains(out, `"flour"`) || !strings.Contains(out, `"Fry"`) {
+		t.Errorf("Expected the recipe with its ingredients and steps. Got '%s', '%v'", out, err)
+	}
+
+	addRecipes(3)
+	out, errOut, err := runCommand("", "recipes", "list", "-count", "2")
+	if err != nil {
+		t.Fatalf("Expected the recipes to be listed. Got '%v'", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 3 || !strings.Contains(lines[1], "pancakes") {
+		t.Errorf("Expected a heading and '2' recipes. Got '%s'", out)
+	}
+	i := strings.Index(errOut, "-cursor ")
+	if i < 0 {
+		t.Fatalf("Expected the cursor of the next page. Got '%s'", errOut)
+	}
+	out, _, err = runCommand("", "recipes", "list", "-cursor", strings.TrimSpace(errOut[i+len("-cursor "):]))
+	if err != nil || !strings.Contains(out, "Recipe 1") || strings.Contains(out, "pancakes") {
+		t.Errorf("Expected the next page. Got '%s', '%v'", out, err)
+	}
+
+	if out, _, err = runCommand("", "recipes", "delete", "1"); err != nil || out != "Deleted recipe 1\n" {
+		t.Errorf("Expected the recipe to be deleted. Got '%s', '%v'", out, err)
+	}
+	_, _, err = runCommand("", "recipes", "get", "1")
+	if err == nil || err.Error() != "recipe 1 not found" {
+		t.Errorf("Expected the recipe not to be found. Got '%v'", err)
+	}
+}
+
+func TestCommandCreateInvalidRecipe(t *testing.T) {
+	clearTables()
+
+	_, _, err := runCommand(`{"name": "soup", "difficulty": 4}`, "recipes", "create")
+	if _, ok := err.(*recipes.ValidationError); !ok {
+		t.Errorf("Expected a validation error. Got '%v'", err)
+	}
+	_, _, err = runCommand(`{"name": "soup", "difficulty": 1, "spiciness": 3}`, "recipes", "create")
+	if err == nil || !strings.Contains(err.Error(), "spiciness") {
+		t.Errorf("Expected the unknown field to be rejected. Got '%v'", err)
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	_, errOut, err := runCommand("", "recipes", "cook")
+	if err != commands.ErrUsage || !strings.Contains(errOut, "recipes list") {
+		t.Errorf("Expected the usage. Got '%s', '%v'", errOut, err)
+	}
+	_, errOut, err = runCommand("", "recipes", "get")
+	if err != commands.ErrUsage || !strings.Contains(errOut, "Usage: recipes get <id>") {
+		t.Errorf("Expected the usage of recipes get. Got '%s', '%v'", errOut, err)
+	}
+	if _, _, err = runCommand("", "recipes", "delete", "pancakes"); err != commands.ErrUsage {
+		t.Errorf("Expected the recipe ID to be rejected. Got '%v'", err)
+	}
+	for _, count := range []string{"0", "-5"} {
+		_, errOut, err = runCommand("", "recipes", "list", "-count", count)
+		if err != commands.ErrUsage || !strings.Contains(errOut, "-count must be at least 1") {
+			t.Errorf("Expected the count '%s' to be rejected. Got '%s', '%v'", count, errOut, err)
+		}
+	}
+	if !commands.IsServe(nil) || !commands.IsServe([]string{"serve"}) || commands.IsServe([]string{"seed"}) {
+		t.Error("Expected the API to be served with no command, or serve")
+	}
+}
+
+func TestCommandSeed(t *testing.T) {
+	clearTables()
+
+	out, _, err := runCommand("", "seed")
+	if err != nil || strings.Count(out, "Created recipe") != 4 {
+		t.Fatalf("Expected '4' sample recipes. Got '%s', '%v'", out, err)
+	}
+	r := recipes.Recipe{ID: 1}
+	if err := app.Store.GetRecipe(context.Background(), &r); err != nil || len(r.Steps) != 3 || r.PrepTime < 0.89 || r.PrepTime > 0.91 {
+		t.Errorf("Expected the pancakes with their steps and derived preptime. Got '%v', '%v'", r, err)
+	}
+
+	if _, _, err := runCommand("", "seed"); err == nil {
+		t.Error("Expected the samples not to be added again")
+	}
+	if out, _, err := runCommand("", "seed", "-force"); err != nil || strings.Count(out, "Created recipe") != 4 {
+		t.Errorf("Expected the samples to be forced. Got '%s', '%v'", out, err)
+	}
+}
+
+func TestCommandSetRole(t *testing.T) {
+	clearTables()
+	postJSON(t, "POST", "/v1/users", `{"username":"carol","password":"correct horse"}`, "")
+
+	out, _, err := runCommand("", "users", "set-role", "carol", "moderator")
+	if err != nil || out != "User carol is now a moderator\n" {
+		t.Fatalf("Expected carol to be a moderator. Got '%s', '%v'", out, err)
+	}
+	u := recipes.User{Username: "carol"}
+	if err := app.Store.GetUserByName(context.Background(), &u); err != nil || u.Role != recipes.RoleModerator {
+		t.Errorf("Expected the role to be stored. Got '%v', '%v'", u, err)
+	}
+
+	if _, _, err := runCommand("", "users", "set-role", "carol", "chef"); err == nil {
+		t.Error("Expected the role to be rejected")
+	}
+	if _, _, err := runCommand("", "users", "set-role", "dave", "admin"); err == nil || err.Error() != "user dave not found" {
+		t.Errorf("Expected dave not to be found. Got '%v'", err)
+	}
+}
+
+func TestCommandRecomputeRatings(t *testing.T) {
+	clearTables()
+	addRecipes(2)
+	addRecipeRatings(1, 3)
+
+	out, _, err := runCommand("", "ratings", "recompute")
+	if err != nil || !strings.HasPrefix(out, "Recomputed the rating statistics of") {
+		t.Errorf("Expected the statistics to be recomputed. Got '%s', '%v'", out, err)
+	}
+}
+
+func TestCommandMigrate(t *testing.T) {
+	if app.DB == nil {
+		if _, _, err := runCommand("", "migrate", "status"); err == nil {
+			t.Error("Expected the migrate commands to need a database")
+		}
+		return
+	}
+
+	out, _, err := runCommand("", "migrate", "up")
+	if err != nil || !strings.Contains(out, "up to date") {
+		t.Errorf("Expected the schema to be up to date. Got '%s', '%v'", out, err)
+	}
+	out, _, err = runCommand("", "migrate", "status")
+	if err != nil || strings.Contains(out, "pending") || strings.Count(out, "\n") != len(migrations.All())+1 {
+		t.Errorf("Expected every migration to be applied. Got '%s', '%v'", out, err)
+	}
+}
+
+func TestCommandReindexRecipes(t *testing.T) {
+	clearTables()
+	addRecipes(3)
+
+	out, _, err := runCommand("", "recipes", "reindex")
+	if err != nil || out != "Indexed the names of 3 recipes\n" {
+		t.Errorf("Expected '3' recipes to be indexed. Got '%s', '%v'", out, err)
+	}
+	req, _ := http.NewRequest("GET", "/v1/recipes/search?q=recipe", nil)
+	checkIDs(t, "q=recipe", []int{1, 2, 3}, searchIDs(t, req))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	// local import
+	"application"
+	"recipes"
+)
+
+// unreachableDB returns a database handle for a port nothing listens on.
+func unreachableDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("postgres", "postgres://nobody@127.0.0.1:1/recipes?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestHealthz(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	response := executeAnonymous(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	response := executeAnonymous(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["status"] != "ready" {
+		t.Errorf("Expected status 'ready'. Got '%v'", m["status"])
+	}
+}
+
+func TestReadyzWithoutDatabase(t *testing.T) {
+	down := application.App{DB: unreachableDB(t), PingTimeout: time.Second}
+	down.InitializeWithStore(recipes.NewMemoryStore())
+	defer down.Close()
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	response := serve(&down, req)
+
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+	var r struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &r)
+	if r.Status != "unavailable" || r.Checks["database"] != "unreachable" || r.Checks["migrations"] != "unknown" {
+		t.Errorf("Expected the database check to fail. Got '%s'", response.Body.String())
+	}
+	// the driver's error, naming the host, is only logged
+	if strings.Contains(response.Body.String(), "127.0.0.1") {
+		t.Errorf("Expected the cause not to be shown. Got '%s'", response.Body.String())
+	}
+
+	// the process is still alive
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	response = serve(&down, req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestWaitForDB(t *testing.T) {
+	db := unreachableDB(t)
+	defer db.Close()
+
+	start := time.Now()
+	err := application.WaitForDB(db, 500*time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected an error from waiting for an unreachable database")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected to give up after about 500ms. Took %v", elapsed)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCreateRecipeWithIngredients(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"pancakes","preptime":20,"difficulty":1,"vegetarian":true,"ingredients":[` +
+		`{"name":"flour","quantity":100,"unit":"g"},` +
+		`{"name":"eggs","quantity":2,"notes":"large"}]}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	req, err = http.NewRequest("GET", "/v1/recipes/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m struct {
+		Ingredients []map[string]interface{} `json:"ingredients"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &m)
+
+	if len(m.Ingredients) != 2 {
+		t.Fatalf("Expected '2' ingredients. Got '%v'", len(m.Ingredients))
+	}
+	if m.Ingredients[0]["name"] != "flour" || m.Ingredients[0]["unit"] != "g" || m.Ingredients[0]["quantity"] != 100.0 {
+		t.Errorf("Expected '100 g flour'. Got '%v'", m.Ingredients[0])
+	}
+	if m.Ingredients[1]["notes"] != "large" {
+		t.Errorf("Expected ingredient notes to be 'large'. Got '%v'", m.Ingredients[1]["notes"])
+	}
+	// recipe_id is compared to 1.0 because JSON unmarshaling converts numbers to
+	//     floats (float64), when the target is a map[string]interface{}
+	if m.Ingredients[1]["recipe_id"] != 1.0 {
+		t.Errorf("Expected ingredient recipe ID to be '1'. Got '%v'", m.Ingredients[1]["recipe_id"])
+	}
+}
+
+func TestIngredients(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	payload := []byte(`{"name":"salt","quantity":1,"unit":"pinch"}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes/1/ingredients", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["ingredient_id"] != 1.0 {
+		t.Errorf("Expected ingredient ID to be '1'. Got '%v'", m["ingredient_id"])
+	}
+
+	payload = []byte(`{"name":"salt","quantity":2,"unit":"pinch","notes":"to taste"}`)
+
+	req, err = http.NewRequest("PUT", "/v1/recipes/1/ingredients/1", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PUT): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, err = http.NewRequest("GET", "/v1/recipes/1/ingredients", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var mm []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &mm)
+	if len(mm) != 1 {
+		t.Fatalf("Expected '1' ingredient. Got '%v'", len(mm))
+	}
+	if mm[0]["quantity"] != 2.0 || mm[0]["notes"] != "to taste" {
+		t.Errorf("Expected the ingredient to be updated. Got '%v'", mm[0])
+	}
+
+	req, err = http.NewRequest("DELETE", "/v1/recipes/1/ingredients/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (DELETE): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, err = http.NewRequest("GET", "/v1/recipes/1/ingredients/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (Second GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+func TestIngredientsOfNonExistentRecipe(t *testing.T) {
+	clearTables()
+
+	req, err := http.NewRequest("GET", "/v1/recipes/11/ingredients", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	payload := []byte(`{"name":"salt","quantity":1,"unit":"pinch"}`)
+
+	req, err = http.NewRequest("POST", "/v1/recipes/11/ingredients", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
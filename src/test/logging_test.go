@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	// local import
+	"application"
+	"logging"
+	"recipes"
+)
+
+// failingStore fails every call to read a recipe.
+type failingStore struct {
+	recipes.RecipeStore
+}
+
+func (failingStore) GetRecipe(ctx context.Context, r *recipes.Recipe) error {
+	return errors.New("pq: relation \"recipes\" does not exist")
+}
+
+// logEntries parses the JSON lines written to a logger.
+func logEntries(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("Expected a JSON log entry. Got '%s'", line)
+		}
+		entries = append(entries, m)
+	}
+	return entries
+}
+
+func TestAccessLog(t *testing.T) {
+	var b bytes.Buffer
+	logged := application.App{Log: logging.New(&b, logging.Info)}
+	logged.InitializeWithStore(recipes.NewMemoryStore())
+	b.Reset()
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/7", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	req.RemoteAddr = "192.0.2.1:1234"
+	response := serve(&logged, req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	if id := response.Header().Get("X-Request-ID"); id != "abc-123" {
+		t.Errorf("Expected the request ID 'abc-123' to be kept. Got '%s'", id)
+	}
+
+	entries := logEntries(t, &b)
+	if len(entries) != 1 {
+		t.Fatalf("Expected '1' log entry. Got '%d'", len(entries))
+	}
+	e := entries[0]
+	for k, v := range map[string]interface{}{
+		"level":       "info",
+		"msg":         "request",
+		"request_id":  "abc-123",
+		"method":      "GET",
+		"route":       "/v1/recipes/{id:[0-9]+}",
+		"path":        "/v1/recipes/7",
+		"status":      float64(404),
+		"code":        "not_found",
+		"remote_addr": "192.0.2.1:1234",
+	} {
+		if e[k] != v {
+			t.Errorf("Expected %s to be '%v'. Got '%v'", k, v, e[k])
+		}
+	}
+	if e["bytes"].(float64) != float64(response.Body.Len()) {
+		t.Errorf("Expected bytes to be '%d'. Got '%v'", response.Body.Len(), e["bytes"])
+	}
+	if _, ok := e["duration_ms"].(float64); !ok {
+		t.Errorf("Expected a duration. Got '%v'", e["duration_ms"])
+	}
+}
+
+func TestInvalidRequestIDReplaced(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/v1/recipes", nil)
+	req.Header.Set("X-Request-ID", "not valid\"")
+	response := executeRequest(req)
+
+	if id := response.Header().Get("X-Request-ID"); id == "" || id == "not valid\"" {
+		t.Errorf("Expected a new request ID. Got '%s'", id)
+	}
+}
+
+func TestStoreErrorsLoggedWithRequestID(t *testing.T) {
+	var b bytes.Buffer
+	logged := application.App{Log: logging.New(&b, logging.Warn)}
+	logged.InitializeWithStore(failingStore{recipes.NewMemoryStore()})
+	b.Reset()
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/7", nil)
+	req.Header.Set("X-Request-ID", "req-500")
+	response := serve(&logged, req)
+
+	checkResponseCode(t, http.StatusInternalServerError, response.Code)
+
+	entries := logEntries(t, &b)
+	if len(entries) != 2 {
+		t.Fatalf("Expected '2' log entries. Got '%d': %s", len(entries), b.String())
+	}
+	// the store logs the failure first, then the request is logged
+	store, access := entries[0], entries[1]
+	if store["msg"] != "store operation failed" || store["operation"] != "GetRecipe" || store["request_id"] != "req-500" {
+		t.Errorf("Expected the store error with the request ID. Got '%v'", store)
+	}
+	if access["level"] != "error" || access["request_id"] != "req-500" || access["error"] != store["error"] {
+		t.Errorf("Expected the request logged as an error, with its cause. Got '%v'", access)
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	var b bytes.Buffer
+	l := logging.New(&b, logging.Warn).With("component", "test")
+	l.Info("hidden")
+	l.Warn("shown", "n", 1)
+
+	entries := logEntries(t, &b)
+	if len(entries) != 1 || entries[0]["msg"] != "shown" || entries[0]["component"] != "test" || entries[0]["n"] != float64(1) {
+		t.Errorf("Expected only the warning, with its fields. Got '%s'", b.String())
+	}
+
+	if _, err := logging.ParseLevel("DEBUG"); err != nil {
+		t.Errorf("Expected 'DEBUG' to be a level. Got '%v'", err)
+	}
+	if _, err := logging.ParseLevel("verbose"); err == nil {
+		t.Error("Expected 'verbose' not to be a level")
+	}
+}
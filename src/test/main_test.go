@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"mime/multipart"
@@ -9,19 +10,42 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	// local import
 	"application"
+	"auth"
+	"config"
+	"logging"
+	"recipes"
+	// GitHub packages
+	"golang.org/x/crypto/bcrypt"
 )
 
 var app application.App
 
+// adminKey is sent by executeRequest, and grants every scope.
+var adminKey = auth.APIKey{Name: "tests", Key: strings.Repeat("k", 32), Scopes: []string{
+	auth.ScopeRecipesRead, auth.ScopeRecipesWrite, auth.ScopeRatingsWrite, auth.ScopeRatingsModerate,
+	auth.ScopeUsersAdmin}}
+
+// TestMain runs the tests against CockroachDB when COCKROACH_DB is set,
+// otherwise against an in-memory store.
 func TestMain(m *testing.M) {
-	app = application.App{}
-	app.Initialize(
-		os.Getenv("COCKROACH_USER"),
-		os.Getenv("COCKROACH_DB"))
-	ensureTablesExist()
+	// hashing at the default cost would make registering users slow
+	recipes.PasswordCost = bcrypt.MinCost
+	// only the failures of the tests are of interest, not every request
+	logging.SetDefault(logging.New(os.Stderr, logging.Warn))
+	app = application.App{AutoMigrate: true, APIKeys: []auth.APIKey{adminKey}}
+	if os.Getenv("COCKROACH_DB") != "" {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.Initialize(cfg)
+	} else {
+		app.InitializeWithStore(recipes.NewMemoryStore())
+	}
 	code := m.Run()
 	clearTables()
 	os.Exit(code)
@@ -38,8 +62,10 @@ func TestEmptyTables(t *testing.T) {
 
 	checkResponseCode(t, http.StatusOK, response.Code)
 
-	if body := response.Body.String(); body != "[]" {
-		t.Errorf("Expected an empty array. Got %s", body)
+	var page recipePage
+	json.Unmarshal(response.Body.Bytes(), &page)
+	if page.Data == nil || len(page.Data) != 0 || page.Page.Next != "" || page.Page.Prev != "" {
+		t.Errorf("Expected an empty page. Got %s", response.Body.String())
 	}
 }
 
@@ -53,12 +79,31 @@ func TestGetNonExistentRecipe(t *testing.T) {
 	response := executeRequest(req)
 
 	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "Recipe not found")
+}
 
-	var m map[string]string
-	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Recipe not found" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Recipe not found'. Got '%s'", m["error"])
+func TestInvalidRecipeID(t *testing.T) {
+	clearTables()
+
+	req, err := http.NewRequest("GET", "/v1/recipes/abc", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "")
+
+	payload := []byte(`{"name":`)
+
+	req, err = http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
 	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	checkProblem(t, response, "bad_request", "Invalid request payload")
 }
 
 func TestCreateRecipe(t *testing.T) {
@@ -226,7 +271,17 @@ func TestDeleteRecipe(t *testing.T) {
 	checkResponseCode(t, http.StatusNotFound, response.Code)
 }
 
+// executeRequest serves a request, as the admin API key unless the request
+// has its own credentials.
 func executeRequest(req *http.Request) *httptest.ResponseRecorder {
+	if req.Header.Get("Authorization") == "" && req.Header.Get("X-API-Key") == "" {
+		req.Header.Set("X-API-Key", adminKey.Key)
+	}
+	return executeAnonymous(req)
+}
+
+// executeAnonymous serves a request without adding any credentials.
+func executeAnonymous(req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	app.Router.ServeHTTP(rr, req)
 	return rr
@@ -238,20 +293,59 @@ func checkResponseCode(t *testing.T, expected, actual int) {
 	}
 }
 
-func ensureTablesExist() {
-	if _, err := app.DB.Exec(recipesTableCreationQuery); err != nil {
-		log.Fatal(err)
+// checkProblem checks that the response is an RFC 7807 problem with the
+// specified code and detail, and returns the problem.
+func checkProblem(t *testing.T, response *httptest.ResponseRecorder, code string, detail string) map[string]interface{} {
+	if ct := response.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Errorf("Expected Content-Type to be 'application/problem+json'. Got '%s'", ct)
 	}
-	if _, err := app.DB.Exec(ratingsTableCreationQuery); err != nil {
-		log.Fatal(err)
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["code"] != code {
+		t.Errorf("Expected the 'code' key of the problem to be set to '%s'. Got '%v'", code, m["code"])
 	}
+	if detail != "" && m["detail"] != detail {
+		t.Errorf("Expected the 'detail' key of the problem to be set to '%s'. Got '%v'", detail, m["detail"])
+	}
+	// the status is compared to a float64 because JSON unmarshaling converts numbers to
+	//     floats (float64), when the target is a map[string]interface{}
+	if m["status"] != float64(response.Code) {
+		t.Errorf("Expected the 'status' key of the problem to be set to '%d'. Got '%v'", response.Code, m["status"])
+	}
+	if id := response.Header().Get("X-Request-ID"); id == "" || m["request_id"] != id {
+		t.Errorf("Expected the 'request_id' key of the problem to be set to '%s'. Got '%v'", id, m["request_id"])
+	}
+	return m
+}
+
+// recipePage is a page of a recipe listing.
+type recipePage struct {
+	Data []map[string]interface{} `json:"data"`
+	Page struct {
+		Size  int    `json:"size"`
+		Count int    `json:"count"`
+		Next  string `json:"next"`
+		Prev  string `json:"prev"`
+	} `json:"page"`
 }
 
 func clearTables() {
+	if app.DB == nil {
+		app.Store = recipes.NewMemoryStore()
+		return
+	}
 	app.DB.Exec("DELETE FROM recipes")
+	app.DB.Exec("DELETE FROM recipe_words")
 	app.DB.Exec("ALTER SEQUENCE recipes_id_seq RESTART WITH 1")
 	app.DB.Exec("DELETE FROM recipe_ratings")
+	app.DB.Exec("DELETE FROM recipe_rating_stats")
 	app.DB.Exec("ALTER SEQUENCE recipe_ratings_rating_id_seq RESTART WITH 1")
+	app.DB.Exec("DELETE FROM ingredients")
+	app.DB.Exec("ALTER SEQUENCE ingredients_ingredient_id_seq RESTART WITH 1")
+	app.DB.Exec("DELETE FROM steps")
+	app.DB.Exec("ALTER SEQUENCE steps_step_id_seq RESTART WITH 1")
+	app.DB.Exec("DELETE FROM users")
+	app.DB.Exec("ALTER SEQUENCE users_user_id_seq RESTART WITH 1")
 }
 
 func TestAddRating(t *testing.T) {
@@ -273,6 +367,7 @@ func TestAddRating(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error on http.NewRequest (2nd POST): %s", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+newUserToken(t))
 	response = executeRequest(req)
 
 	checkResponseCode(t, http.StatusCreated, response.Code)
@@ -306,6 +401,7 @@ func TestSearch(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error on http.NewRequest (2nd POST): %s", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+newUserToken(t))
 	response = executeRequest(req)
 
 	checkResponseCode(t, http.StatusCreated, response.Code)
@@ -325,6 +421,8 @@ func TestSearch(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error on http.NewRequest (3rd POST): %s", err)
 	}
+	// a second user, as each user's rating replaces their previous one
+	req.Header.Set("Authorization", "Bearer "+newUserToken(t))
 	response = executeRequest(req)
 
 	checkResponseCode(t, http.StatusCreated, response.Code)
@@ -340,7 +438,6 @@ func TestSearch(t *testing.T) {
 	var bb bytes.Buffer
 	mw := multipart.NewWriter(&bb)
 	mw.WriteField("count", "1")
-	mw.WriteField("start", "0")
 	mw.WriteField("preptime", "50.0")
 	mw.Close()
 
@@ -354,10 +451,12 @@ func TestSearch(t *testing.T) {
 
 	checkResponseCode(t, http.StatusOK, response.Code)
 
-	var mm []map[string]interface{}
-	json.Unmarshal(response.Body.Bytes(), &mm)
-	// only want the first one
-	m = mm[0]
+	var page recipePage
+	json.Unmarshal(response.Body.Bytes(), &page)
+	if len(page.Data) != 1 {
+		t.Fatalf("Expected '1' recipe. Got '%v'", len(page.Data))
+	}
+	m = page.Data[0]
 
 	if m["name"] != "test recipe" {
 		t.Errorf("Expected recipe name to be 'test recipe'. Got '%v'", m["name"])
@@ -393,7 +492,6 @@ func TestSearch(t *testing.T) {
 
 	mw = multipart.NewWriter(&bb)
 	mw.WriteField("count", "10")
-	mw.WriteField("start", "1")
 	mw.Close()
 
 	req, err = http.NewRequest("POST", "/v1/recipes/search", &bb)
@@ -406,16 +504,16 @@ func TestSearch(t *testing.T) {
 
 	checkResponseCode(t, http.StatusOK, response.Code)
 
-	json.Unmarshal(response.Body.Bytes(), &mm)
+	page = recipePage{}
+	json.Unmarshal(response.Body.Bytes(), &page)
 
 	// Search page limit
-	if len(mm) != 10 {
-		t.Errorf("Expected '10' recipes. Got '%v'", len(mm))
+	if len(page.Data) != 10 || page.Page.Next == "" {
+		t.Errorf("Expected '10' recipes and a next page. Got '%v' and '%s'", len(page.Data), page.Page.Next)
 	}
 
 	mw = multipart.NewWriter(&bb)
-	mw.WriteField("count", "10")
-	mw.WriteField("start", "1")
+	mw.WriteField("count", "2")
 	mw.WriteField("preptime", "30.0")
 	mw.Close()
 
@@ -429,11 +527,28 @@ func TestSearch(t *testing.T) {
 
 	checkResponseCode(t, http.StatusOK, response.Code)
 
-	json.Unmarshal(response.Body.Bytes(), &mm)
+	page = recipePage{}
+	json.Unmarshal(response.Body.Bytes(), &page)
 
 	// Search page limit
-	if len(mm) != 2 {
-		t.Errorf("Expected '2' recipes. Got '%v'", len(mm))
+	if len(page.Data) != 2 || page.Page.Next == "" {
+		t.Errorf("Expected '2' recipes and a next page. Got '%v' and '%s'", len(page.Data), page.Page.Next)
+	}
+
+	req, err = http.NewRequest("GET", "/v1/recipes/search?preptime=30&count=2&cursor="+page.Page.Next, nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	page = recipePage{}
+	json.Unmarshal(response.Body.Bytes(), &page)
+
+	// 3 recipes are quicker than 30, the first two were on the first page
+	if len(page.Data) != 1 || page.Page.Next != "" || page.Page.Prev == "" {
+		t.Errorf("Expected '1' recipe and only a previous page. Got '%s'", response.Body.String())
 	}
 }
 
@@ -442,8 +557,12 @@ func addRecipes(count int) {
 		count = 1
 	}
 	for i := 0; i < count; i++ {
-		app.DB.Exec("INSERT INTO recipes(name, preptime, difficulty, vegetarian) VALUES($1, $2, $3, $4)",
-			"Recipe "+strconv.Itoa(i), (i+1.0)*10, i%3+1, true)
+		app.Store.CreateRecipe(context.Background(), &recipes.Recipe{
+			Name:       "Recipe " + strconv.Itoa(i),
+			PrepTime:   float32(i+1) * 10,
+			Difficulty: i%3 + 1,
+			Vegetarian: true,
+		})
 	}
 }
 
@@ -456,24 +575,45 @@ func addRecipeRatings(recipe int, count int) {
 	}
 }
 
+// raters counts the users created to rate recipes, to give each a unique name.
+var raters int
+
+// addRecipeRating adds a rating of a recipe by a new user.
 func addRecipeRating(recipe int, rating int) {
-	app.DB.Exec("INSERT INTO recipe_ratings(recipe_id, rating) VALUES($1, $2)", recipe, rating)
+	raters++
+	u := recipes.User{Username: "rater " + strconv.Itoa(raters)}
+	app.Store.CreateUser(context.Background(), &u)
+	app.Store.AddRecipeRating(context.Background(), &recipes.RecipeRating{RecipeID: recipe, UserID: u.ID, Rating: rating})
 }
 
-const recipesTableCreationQuery = `CREATE TABLE IF NOT EXISTS recipes
-(
-	id BIGSERIAL,
-	name TEXT NOT NULL,
-	preptime FLOAT(4) NOT NULL DEFAULT 0.0,
-	difficulty NUMERIC(1) NOT NULL CHECK (difficulty > 0) CHECK (difficulty < 4) DEFAULT 0,
-	vegetarian BOOLEAN NOT NULL DEFAULT false,
-	CONSTRAINT recipes_pkey PRIMARY KEY (id)
-)`
-
-const ratingsTableCreationQuery = `CREATE TABLE IF NOT EXISTS recipe_ratings
-(
-	recipe_id BIGINT REFERENCES recipes(id) ON DELETE CASCADE,
-	rating_id BIGSERIAL,
-	rating SMALLINT NOT NULL CHECK (rating > 0) CHECK (rating < 6) DEFAULT 0,
-	PRIMARY KEY (recipe_id, rating_id)
-)`
+func TestModifyNonExistentRecipe(t *testing.T) {
+	clearTables()
+
+	payload := `{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`
+
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		req, err := http.NewRequest(method, "/v1/recipes/9999", bytes.NewBufferString(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (%s): %s", method, err)
+		}
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusNotFound, response.Code)
+	}
+}
+
+func TestRateNonExistentRecipe(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"rating":3}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes/9999/rating", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+newUserToken(t))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "Recipe not found")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	// local import
+	"application"
+	"metrics"
+	"recipes"
+)
+
+func TestMetrics(t *testing.T) {
+	observed := application.App{}
+	observed.InitializeWithStore(recipes.NewMemoryStore())
+
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/v1/recipes"},
+		{"GET", "/v1/recipes/7"},
+		{"GET", "/v1/recipes/8"},
+		{"GET", "/no/such/path"},
+		{"PUT", "/v1/recipes"},
+	} {
+		req, _ := http.NewRequest(r.method, r.path, nil)
+		serve(&observed, req)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	response := serve(&observed, req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	if ct := response.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format. Got '%s'", ct)
+	}
+
+	body := response.Body.String()
+	for _, expected := range []string{
+		// by route template, not by path
+		`http_requests_total{method="GET",route="/v1/recipes/{id:[0-9]+}",status="404"} 2`,
+		`http_requests_total{method="GET",route="/v1/recipes",status="200"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="PUT",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/v1/recipes/{id:[0-9]+}"} 2`,
+		`recipes_store_operation_duration_seconds_count{operation="GetRecipe",result="not_found"} 2`,
+		`recipes_store_operation_duration_seconds_count{operation="GetRecipes",result="ok"} 1`,
+		"# TYPE recipes_transactions_total counter",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain '%s'. Got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestHistogramFormat(t *testing.T) {
+	r := metrics.NewRegistry()
+	h := r.NewHistogramVec("test_seconds", "A test histogram.", []float64{0.1, 1}, "path")
+	h.Observe(0.05, `a"b`)
+	h.Observe(0.5, `a"b`)
+	h.Observe(5, `a"b`)
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{path="a\"b",le="0.1"} 1
+test_seconds_bucket{path="a\"b",le="1"} 2
+test_seconds_bucket{path="a\"b",le="+Inf"} 3
+test_seconds_sum{path="a\"b"} 5.55
+test_seconds_count{path="a\"b"} 3
+`
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	// local import
+	"migrations"
+)
+
+// TestMigrationFiles checks that the migrations built into the program are
+// those of the SQL files, split into their statements.
+func TestMigrationFiles(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("..", "migrations", "sql", "*.sql"))
+	if err != nil || len(names) == 0 {
+		t.Fatalf("Expected the SQL files of the migrations. Got '%v', '%v'", names, err)
+	}
+	all := migrations.All()
+	if len(names) != 2*len(all) {
+		t.Errorf("Expected an up and a down file for each of the '%d' migrations. Got '%d' files; run go generate in migrations", len(all), len(names))
+	}
+	for _, m := range all {
+		for suffix, stmts := range map[string][]string{"up": m.Up, "down": m.Down} {
+			name := fmt.Sprintf("%04d_%s.%s.sql", m.Version, m.Name, suffix)
+			content, err := ioutil.ReadFile(filepath.Join("..", "migrations", "sql", name))
+			if err != nil {
+				t.Errorf("Expected %s. Got '%v'", name, err)
+				continue
+			}
+			if ends := strings.Count(string(content), ";\n"); ends != len(stmts) {
+				t.Errorf("Expected the '%d' statements of %s; run go generate in migrations. Got '%d'", ends, name, len(stmts))
+			}
+			for _, stmt := range stmts {
+				if stmt == "" || strings.HasSuffix(stmt, ";") || !strings.Contains(string(content), stmt+";") {
+					t.Errorf("Expected a statement of %s; run go generate in migrations. Got '%s'", name, stmt)
+				}
+			}
+		}
+	}
+
+	// the rating statistics are created, then filled, in separate statements
+	if m := all[5]; m.Name != "create_recipe_rating_stats" || len(m.Up) != 2 ||
+		!strings.HasPrefix(m.Up[0], "CREATE TABLE") || !strings.Contains(m.Up[1], "INSERT INTO") {
+		t.Errorf("Expected the rating statistics to be created in '2' statements. Got '%v'", m.Up)
+	}
+}
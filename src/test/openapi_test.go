@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	// GitHub packages
+	"github.com/gorilla/mux"
+)
+
+// routeVariable matches a variable of a route template, such as {id:[0-9]+},
+// whose OpenAPI form is {id}.
+var routeVariable = regexp.MustCompile(`\{([a-z_]+)(:[^}]*)?\}`)
+
+func specPath(template string) string {
+	return routeVariable.ReplaceAllString(template, "{$1}")
+}
+
+// openAPISpec is a parsed OpenAPI document.
+type openAPISpec map[string]interface{}
+
+func loadOpenAPISpec(t *testing.T) openAPISpec {
+	req, _ := http.NewRequest("GET", "/v1/openapi.json", nil)
+	response := executeAnonymous(req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var spec openAPISpec
+	if err := json.Unmarshal(response.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Expected the OpenAPI document to be JSON. Got '%v'", err)
+	}
+	if v, _ := spec["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Fatalf("Expected an OpenAPI 3 document. Got '%v'", spec["openapi"])
+	}
+	return spec
+}
+
+// resolve follows a $ref to another part of the document.
+func (spec openAPISpec) resolve(t *testing.T, node map[string]interface{}) map[string]interface{} {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node
+	}
+	var target interface{} = map[string]interface{}(spec)
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, _ := target.(map[string]interface{})
+		target = m[key]
+	}
+	resolved, ok := target.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected '%s' to resolve", ref)
+	}
+	return spec.resolve(t, resolved)
+}
+
+// operation returns the path item and operation for a method and path, or nil.
+func (spec openAPISpec) operation(method string, path string) (map[string]interface{}, map[string]interface{}) {
+	paths, _ := spec["paths"].(map[string]interface{})
+	item, _ := paths[path].(map[string]interface{})
+	op, _ := item[strings.ToLower(method)].(map[string]interface{})
+	return item, op
+}
+
+// check reports where value does not match schema. It handles the parts of
+// JSON Schema which the document uses.
+func (spec openAPISpec) check(t *testing.T, at string, schema map[string]interface{}, value interface{}) {
+	schema = spec.resolve(t, schema)
+	if value == nil {
+		if schema["nullable"] != true && len(schema) > 0 {
+			t.Errorf("%s: Expected a value. Got 'null'", at)
+		}
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == value
+		}
+		if !found {
+			t.Errorf("%s: Expected one of '%v'. Got '%v'", at, enum, value)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: Expected an object. Got '%v'", at, value)
+			return
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if _, ok := m[r.(string)]; !ok {
+				t.Errorf("%s: Expected the required member '%s'. Got '%v'", at, r, value)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for k, v := range m {
+			if p, ok := properties[k].(map[string]interface{}); ok {
+				spec.check(t, at+"."+k, p, v)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				spec.check(t, at+"."+k, additional, v)
+			} else if schema["additionalProperties"] == false {
+				t.Errorf("%s: Expected no member '%s'", at, k)
+			}
+		}
+	case "array":
+		a, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: Expected an array. Got '%v'", at, value)
+			return
+		}
+		if min, ok := schema["minItems"].(float64); ok && float64(len(a)) < min {
+			t.Errorf("%s: Expected at least '%v' items. Got '%d'", at, min, len(a))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(a)) > max {
+			t.Errorf("%s: Expected at most '%v' items. Got '%d'", at, max, len(a))
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, v := range a {
+			spec.check(t, fmt.Sprintf("%s[%d]", at, i), items, v)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: Expected a string. Got '%v'", at, value)
+		}
+	case "integer":
+		if f, ok := value.(float64); !ok || f != math.Trunc(f) {
+			t.Errorf("%s: Expected an integer. Got '%v'", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: Expected a number. Got '%v'", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: Expected a boolean. Got '%v'", at, value)
+		}
+	}
+}
+
+// checkResponse checks a response against the operation of the route which
+// served it, and records that the operation has been exercised. Client
+// errors must be documented; server errors may be left to the default.
+func (spec openAPISpec) checkResponse(t *testing.T, req *http.Request, response *httptest.ResponseRecorder, exercised map[string]bool) {
+	var match mux.RouteMatch
+	if !app.Router.Match(req, &match) || match.Route == nil {
+		t.Errorf("Expected a route for %s %s", req.Method, req.URL.Path)
+		return
+	}
+	template, _ := match.Route.GetPathTemplate()
+	path := specPath(template)
+	at := req.Method + " " + path
+	_, op := spec.operation(req.Method, path)
+	if op == nil {
+		t.Errorf("Expected %s to be documented", at)
+		return
+	}
+	exercised[at] = true
+
+	responses, _ := op["responses"].(map[string]interface{})
+	documented, ok := responses[strconv.Itoa(response.Code)].(map[string]interface{})
+	if !ok && response.Code >= http.StatusInternalServerError {
+		documented, ok = responses["default"].(map[string]interface{})
+	}
+	if !ok {
+		t.Errorf("%s: Expected the status '%d' to be documented", at, response.Code)
+		return
+	}
+	documented = spec.resolve(t, documented)
+	mediaType, _, _ := mime.ParseMediaType(response.Header().Get("Content-Type"))
+	content, _ := documented["content"].(map[string]interface{})
+	media, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		t.Errorf("%s: Expected the '%d' response's type '%s' to be documented", at, response.Code, mediaType)
+		return
+	}
+	if mediaType != "application/json" && mediaType != "application/problem+json" {
+		return
+	}
+	var body interface{}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Errorf("%s: Expected a JSON body. Got '%s'", at, response.Body.String())
+		return
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	spec.check(t, fmt.Sprintf("%s %d", at, response.Code), schema, body)
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+
+	registered := map[string]bool{}
+	app.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// a path prefix, such as that of /v1
+			return nil
+		}
+		path := specPath(template)
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			item, op := spec.operation(method, path)
+			if op == nil {
+				t.Errorf("Expected %s %s to be documented", method, path)
+				continue
+			}
+			parameters := map[string]bool{}
+			for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+				l, _ := list.([]interface{})
+				for _, p := range l {
+					p := spec.resolve(t, p.(map[string]interface{}))
+					if p["in"] == "path" {
+						parameters[p["name"].(string)] = true
+					}
+				}
+			}
+			for _, v := range routeVariable.FindAllStringSubmatch(template, -1) {
+				if !parameters[v[1]] {
+					t.Errorf("Expected the path parameter '%s' of %s %s to be documented", v[1], method, path)
+				}
+			}
+		}
+		return nil
+	})
+
+	paths, _ := spec["paths"].(map[string]interface{})
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			if m := strings.ToUpper(method) + " " + path; !registered[m] {
+				t.Errorf("Expected the documented %s to be a route", m)
+			}
+		}
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	clearTables()
+	spec := loadOpenAPISpec(t)
+	exercised := map[string]bool{}
+	moderator := newUserTokenWithRole(t, "moderator")
+
+	// do sends a request, with the admin API key unless a token is given,
+	// and checks the response against the document.
+	do := func(method string, url string, contentType string, payload string, token string) interface{} {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(payload))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		var response *httptest.ResponseRecorder
+		switch token {
+		case "":
+			response = executeRequest(req)
+		case "anonymous":
+			response = executeAnonymous(req)
+		default:
+			req.Header.Set("Authorization", "Bearer "+token)
+			response = executeRequest(req)
+		}
+		spec.checkResponse(t, req, response, exercised)
+		var body interface{}
+		json.Unmarshal(response.Body.Bytes(), &body)
+		return body
+	}
+	id := func(body interface{}, key string) string {
+		m, _ := body.(map[string]interface{})
+		f, _ := m[key].(float64)
+		return strconv.Itoa(int(f))
+	}
+
+	do("GET", "/healthz", "", "", "anonymous")
+	do("GET", "/readyz", "", "", "anonymous")
+	do("GET", "/metrics", "", "", "anonymous")
+	do("GET", "/v1/openapi.json", "", "", "anonymous")
+	do("GET", "/v1/docs", "", "", "anonymous")
+
+	user := do("POST", "/v1/users", "application/json", `{"username":"openapi","password":"correct horse"}`, "anonymous")
+	do("POST", "/v1/users", "application/json", `{"username":"openapi","password":"correct horse"}`, "anonymous")
+	do("POST", "/v1/users", "application/json", `{"username":"openapi","password":"short"}`, "anonymous")
+	do("POST", "/v1/users/login", "application/json", `{"username":"openapi","password":"correct horse"}`, "anonymous")
+	do("POST", "/v1/users/login", "application/json", `{"username":"openapi","password":"wrong horse"}`, "anonymous")
+	do("PUT", "/v1/users/"+id(user, "user_id")+"/role", "application/json", `{"role":"author"}`, "")
+	do("PUT", "/v1/users/"+id(user, "user_id")+"/role", "application/json", `{"role":"chef"}`, "")
+	author := login(t, "openapi", "correct horse")
+	do("GET", "/v1/users/me", "", "", author)
+
+	recipe := do("POST", "/v1/recipes", "application/json", `{"name":"pancakes","preptime":0.3,"difficulty":1,"vegetarian":true,`+
+		`"ingredients":[{"name":"flour","quantity":100,"unit":"g"},{"name":"eggs","quantity":2,"notes":"large"}],`+
+		`"steps":[{"text":"Whisk","duration":0.1},{"text":"Fry","timer":60}]}`, author)
+	recipeURL := "/v1/recipes/" + id(recipe, "id")
+	do("POST", "/v1/recipes", "application/json", `{"name":"","difficulty":4}`, author)
+	do("POST", "/v1/recipes", "application/json", `{"name":`, author)
+	do("POST", "/v1/recipes", "application/json", `{"name":"toast","difficulty":1}`, "anonymous")
+	do("GET", "/v1/recipes?count=1", "", "", "anonymous")
+	do("GET", "/v1/recipes?count=0.5", "", "", "anonymous")
+	do("GET", "/v1/recipes?cursor=nonsense", "", "", "anonymous")
+	do("GET", recipeURL, "", "", "anonymous")
+	do("GET", "/v1/recipes/999999", "", "", "anonymous")
+	do("PUT", recipeURL, "application/json", `{"name":"pancakes","preptime":0.4,"difficulty":2,"vegetarian":true}`, author)
+	do("PUT", recipeURL, "application/json", `{"name":"pancakes","difficulty":2}`, newUserToken(t))
+	do("PATCH", recipeURL, "application/merge-patch+json", `{"name":"crepes"}`, author)
+	do("PATCH", recipeURL, "application/json-patch+json", `[{"op":"test","path":"/difficulty","value":3}]`, author)
+	do("PATCH", recipeURL, "text/plain", `name=crepes`, author)
+
+	ingredient := do("POST", recipeURL+"/ingredients", "application/json", `{"name":"milk","quantity":300,"unit":"ml"}`, author)
+	ingredientURL := recipeURL + "/ingredients/" + id(ingredient, "ingredient_id")
+	do("GET", recipeURL+"/ingredients", "", "", "anonymous")
+	do("GET", ingredientURL, "", "", "anonymous")
+	do("PUT", ingredientURL, "application/json", `{"name":"milk","quantity":250,"unit":"ml","notes":"semi-skimmed"}`, author)
+	do("DELETE", ingredientURL, "", "", author)
+	do("GET", ingredientURL, "", "", "anonymous")
+
+	step := do("POST", recipeURL+"/steps", "application/json", `{"text":"Serve","duration":0.05}`, author)
+	stepURL := recipeURL + "/steps/" + id(step, "step_id")
+	steps, _ := do("GET", recipeURL+"/steps", "", "", "anonymous").([]interface{})
+	var order []string
+	for i := len(steps) - 1; i >= 0; i-- {
+		order = append(order, id(steps[i], "step_id"))
+	}
+	do("PUT", recipeURL+"/steps/order", "application/json", `{"step_ids":[`+strings.Join(order, ",")+`]}`, author)
+	do("PUT", recipeURL+"/steps/order", "application/json", `{"step_ids":[`+order[0]+`]}`, author)
+	do("PUT", stepURL, "application/json", `{"text":"Serve warm","timer":30}`, author)
+	do("DELETE", stepURL, "", "", author)
+
+	rating := do("POST", recipeURL+"/rating", "application/json", `{"rating":4}`, author)
+	do("POST", recipeURL+"/rating", "application/json", `{"rating":5}`, author)
+	do("POST", recipeURL+"/rating", "application/json", `{"rating":6}`, author)
+	do("POST", recipeURL+"/rating", "application/json", `{"rating":4}`, "")
+	do("GET", recipeURL+"/rating", "", "", "anonymous")
+	do("DELETE", recipeURL+"/rating", "", "", author)
+	do("DELETE", recipeURL+"/rating", "", "", author)
+	rating = do("POST", recipeURL+"/rating", "application/json", `{"rating":3}`, author)
+	do("DELETE", recipeURL+"/rating/"+id(rating, "rating_id"), "", "", author)
+	do("DELETE", recipeURL+"/rating/"+id(rating, "rating_id"), "", "", moderator)
+
+	do("GET", "/v1/recipes/search?q=crepes&vegetarian=true&ingredients=flour&sort=-rating", "", "", "anonymous")
+	do("GET", "/v1/recipes/search?sort=spiciness", "", "", "anonymous")
+	do("POST", "/v1/recipes/search?count=5", "application/json", `{"q":"crepes","min_rating":0,"exclude_ingredients":["nuts"]}`, "anonymous")
+	var form bytes.Buffer
+	fw := multipart.NewWriter(&form)
+	fw.WriteField("q", "crepes")
+	fw.WriteField("ingredients", "flour")
+	fw.WriteField("max_difficulty", "3")
+	fw.Close()
+	do("POST", "/v1/recipes/search", fw.FormDataContentType(), form.String(), "anonymous")
+	do("POST", "/v1/recipes/search", "application/x-www-form-urlencoded", "min_difficulty=hard", "anonymous")
+
+	do("DELETE", recipeURL, "", "", author)
+	do("DELETE", recipeURL, "", "", author)
+
+	paths, _ := spec["paths"].(map[string]interface{})
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			if m := strings.ToUpper(method) + " " + path; method != "parameters" && !exercised[m] {
+				t.Errorf("Expected the responses of %s to be checked", m)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>; rel="(next|prev)"`)
+
+// getPage gets a page of a listing, and returns its IDs and the URLs
+// of the pages either side from the Link header.
+func getPage(t *testing.T, url string) ([]int, map[string]string) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	return readPage(t, executeRequest(req))
+}
+
+// readPage returns the IDs of a page of a listing, and the URLs of the
+// pages either side from the Link header.
+func readPage(t *testing.T, response *httptest.ResponseRecorder) ([]int, map[string]string) {
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var page recipePage
+	json.Unmarshal(response.Body.Bytes(), &page)
+	ids := []int{}
+	for _, r := range page.Data {
+		// the id is converted from float64 because JSON unmarshaling converts
+		//     numbers to floats, when the target is a map[string]interface{}
+		id, _ := r["id"].(float64)
+		ids = append(ids, int(id))
+	}
+	if page.Page.Count != len(ids) {
+		t.Errorf("Expected the page count to be '%d'. Got '%d'", len(ids), page.Page.Count)
+	}
+
+	links := map[string]string{}
+	for _, match := range linkPattern.FindAllStringSubmatch(response.Header().Get("Link"), -1) {
+		links[match[2]] = match[1]
+	}
+	if (links["next"] != "") != (page.Page.Next != "") || (links["prev"] != "") != (page.Page.Prev != "") {
+		t.Errorf("Expected the Link header to match the page cursors. Got '%s'", response.Header().Get("Link"))
+	}
+	return ids, links
+}
+
+func TestPaginateRecipes(t *testing.T) {
+	clearTables()
+	addRecipes(7)
+
+	ids, links := getPage(t, "/v1/recipes?count=3")
+	checkIDs(t, "page 1", []int{1, 2, 3}, ids)
+	if links["prev"] != "" {
+		t.Errorf("Expected no previous page. Got '%s'", links["prev"])
+	}
+
+	// a recipe added between pages is neither skipped nor repeated
+	addRecipes(1)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "page 2", []int{4, 5, 6}, ids)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "page 3", []int{7, 8}, ids)
+	if links["next"] != "" {
+		t.Errorf("Expected no next page. Got '%s'", links["next"])
+	}
+
+	ids, links = getPage(t, links["prev"])
+	checkIDs(t, "page 2 again", []int{4, 5, 6}, ids)
+
+	ids, links = getPage(t, links["prev"])
+	checkIDs(t, "page 1 again", []int{1, 2, 3}, ids)
+	if links["prev"] != "" {
+		t.Errorf("Expected no previous page. Got '%s'", links["prev"])
+	}
+}
+
+func TestPaginateSortedSearch(t *testing.T) {
+	clearTables()
+	addRecipes(6)
+	// ratings of 4, 4, 4, 2, 0 and 0, so that pages split ties
+	addRecipeRating(1, 4)
+	addRecipeRating(2, 4)
+	addRecipeRating(3, 4)
+	addRecipeRating(4, 2)
+
+	ids, links := getPage(t, "/v1/recipes/search?sort=-rating&count=2")
+	checkIDs(t, "page 1", []int{1, 2}, ids)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "page 2", []int{3, 4}, ids)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "page 3", []int{5, 6}, ids)
+
+	ids, _ = getPage(t, links["prev"])
+	checkIDs(t, "page 2 again", []int{3, 4}, ids)
+}
+
+func TestPaginatePostedSearch(t *testing.T) {
+	clearTables()
+	addSearchRecipes()
+
+	// the criteria are only in the body, so the links must carry them
+	req, err := http.NewRequest("POST", "/v1/recipes/search?count=1",
+		bytes.NewBufferString(`{"q":"chicken","min_preptime":20.5,"sort":"name"}`))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ids, links := readPage(t, executeRequest(req))
+	checkIDs(t, "posted page 1", []int{3}, ids)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "posted page 2", []int{4}, ids)
+	if links["next"] != "" {
+		t.Errorf("Expected no next page of the filtered search. Got '%s'", links["next"])
+	}
+
+	var form bytes.Buffer
+	fw := multipart.NewWriter(&form)
+	fw.WriteField("vegetarian", "false")
+	fw.WriteField("sort", "-preptime")
+	fw.WriteField("count", "1")
+	fw.Close()
+	req, err = http.NewRequest("POST", "/v1/recipes/search", &form)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("Content-Type", fw.FormDataContentType())
+	ids, links = readPage(t, executeRequest(req))
+	checkIDs(t, "form page 1", []int{4}, ids)
+
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "form page 2", []int{3}, ids)
+	if links["next"] != "" {
+		t.Errorf("Expected no next page of the filtered search. Got '%s'", links["next"])
+	}
+	ids, _ = getPage(t, links["prev"])
+	checkIDs(t, "form page 1 again", []int{4}, ids)
+}
+
+func TestInvalidPage(t *testing.T) {
+	clearTables()
+	addRecipes(3)
+
+	req, err := http.NewRequest("GET", "/v1/recipes?cursor=not-a-cursor", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	checkProblem(t, response, "bad_request", "Invalid cursor")
+
+	// a cursor from a listing in another order
+	_, links := getPage(t, "/v1/recipes?count=1")
+
+	req, err = http.NewRequest("GET", "/v1/recipes/search?sort=name&"+links["next"][len("/v1/recipes?"):], nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	checkProblem(t, response, "bad_request", "Invalid cursor")
+
+	req, err = http.NewRequest("GET", "/v1/recipes?count=101", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"count": "too_large"})
+}
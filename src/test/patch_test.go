@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	// local import
+	"application"
+	"auth"
+	"logging"
+	"recipes"
+)
+
+func patchRecipe(t *testing.T, contentType string, payload string) map[string]interface{} {
+	req, err := http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PATCH): %s", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	return m
+}
+
+func TestMergePatchRecipe(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	m := patchRecipe(t, "application/merge-patch+json", `{"name":"x"}`)
+
+	if m["name"] != "x" {
+		t.Errorf("Expected the name to change to 'x'. Got '%v'", m["name"])
+	}
+	// the following are the values set by addRecipes
+	if m["preptime"] != 10.0 {
+		t.Errorf("Expected the preptime to remain '10'. Got '%v'", m["preptime"])
+	}
+	if m["difficulty"] != 1.0 {
+		t.Errorf("Expected the difficulty to remain '1'. Got '%v'", m["difficulty"])
+	}
+	if m["vegetarian"] != true {
+		t.Errorf("Expected vegetarian to remain 'true'. Got '%v'", m["vegetarian"])
+	}
+
+	m = patchRecipe(t, "application/json; charset=utf-8", `{"vegetarian":false}`)
+
+	if m["name"] != "x" || m["vegetarian"] != false {
+		t.Errorf("Expected only vegetarian to change. Got '%v'", m)
+	}
+}
+
+func TestJSONPatchRecipe(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	m := patchRecipe(t, "application/json-patch+json", `[
+		{"op":"test","path":"/name","value":"Recipe 0"},
+		{"op":"replace","path":"/preptime","value":12.5},
+		{"op":"copy","from":"/name","path":"/name"},
+		{"op":"replace","path":"/difficulty","value":3}
+	]`)
+
+	if m["name"] != "Recipe 0" {
+		t.Errorf("Expected the name to remain 'Recipe 0'. Got '%v'", m["name"])
+	}
+	if m["preptime"] != 12.5 {
+		t.Errorf("Expected the preptime to change to '12.5'. Got '%v'", m["preptime"])
+	}
+	if m["difficulty"] != 3.0 {
+		t.Errorf("Expected the difficulty to change to '3'. Got '%v'", m["difficulty"])
+	}
+	if m["vegetarian"] != true {
+		t.Errorf("Expected vegetarian to remain 'true'. Got '%v'", m["vegetarian"])
+	}
+}
+
+func TestInvalidPatchRecipe(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	for _, tc := range []struct {
+		contentType string
+		payload     string
+		code        int
+	}{
+		{"application/json-patch+json", `[{"op":"test","path":"/name","value":"other"}]`, http.StatusConflict},
+		{"application/json-patch+json", `[{"op":"remove","path":"/name"}]`, http.StatusUnprocessableEntity},
+		{"application/json-patch+json", `[{"op":"add","path":"/owner","value":"me"}]`, http.StatusUnprocessableEntity},
+		{"application/json-patch+json", `{"op":"replace"}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"name":null}`, http.StatusUnprocessableEntity},
+		{"application/merge-patch+json", `{"difficulty":"hard"}`, http.StatusUnprocessableEntity},
+		{"application/merge-patch+json", `{"name":`, http.StatusBadRequest},
+		{"text/plain", `name=x`, http.StatusUnsupportedMediaType},
+	} {
+		req, err := http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(tc.payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (PATCH): %s", err)
+		}
+		req.Header.Set("Content-Type", tc.contentType)
+		response := executeRequest(req)
+
+		if response.Code != tc.code {
+			t.Errorf("Expected response code %d for %s %s. Got %d", tc.code, tc.contentType, tc.payload, response.Code)
+		}
+	}
+
+	// the expected type is named as in JSON rather than in Go
+	for payload, detail := range map[string]string{
+		`{"preptime":"long"}`: "Invalid patch: preptime must be a JSON number",
+		`{"difficulty":true}`: "Invalid patch: difficulty must be a JSON number",
+		`{"vegetarian":1}`:    "Invalid patch: vegetarian must be a JSON boolean",
+		`{"name":["x"]}`:      "Invalid patch: name must be a JSON string",
+	} {
+		req, _ := http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+		checkProblem(t, response, "validation_failed", detail)
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	response := executeRequest(req)
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["name"] != "Recipe 0" || m["difficulty"] != 1.0 {
+		t.Errorf("Expected the recipe to be unchanged. Got '%v'", m)
+	}
+}
+
+func TestPutReplacesRecipe(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	payload := []byte(`{"name":"x","difficulty":2}`)
+
+	req, err := http.NewRequest("PUT", "/v1/recipes/1", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PUT): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["preptime"] != 0.0 || m["vegetarian"] != false {
+		t.Errorf("Expected PUT to replace the whole recipe. Got '%v'", m)
+	}
+}
+
+// TestConcurrentJSONPatches increments the preptime of a recipe from many
+// goroutines at once, each guarding its change with a test operation and
+// trying again if it fails, so that no increment may be lost.
+func TestConcurrentJSONPatches(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 0; attempt < 1000; attempt++ {
+				req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+				var r struct {
+					PrepTime float64 `json:"preptime"`
+				}
+				json.Unmarshal(executeRequest(req).Body.Bytes(), &r)
+
+				payload := fmt.Sprintf(`[{"op":"test","path":"/preptime","value":%g},{"op":"replace","path":"/preptime","value":%g}]`,
+					r.PrepTime, r.PrepTime+1)
+				req, _ = http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(payload))
+				req.Header.Set("Content-Type", "application/json-patch+json")
+				response := executeRequest(req)
+				if response.Code == http.StatusOK {
+					return
+				}
+				if response.Code != http.StatusConflict && response.Code != http.StatusServiceUnavailable {
+					t.Errorf("Expected the patch to succeed or conflict. Got '%d'", response.Code)
+					return
+				}
+			}
+			t.Error("Expected the patch to succeed within 1000 attempts")
+		}()
+	}
+	wg.Wait()
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	var r struct {
+		PrepTime float64 `json:"preptime"`
+	}
+	json.Unmarshal(executeRequest(req).Body.Bytes(), &r)
+	if r.PrepTime != 10+writers {
+		t.Errorf("Expected every increment to be kept, for a preptime of '%d'. Got '%v'", 10+writers, r.PrepTime)
+	}
+}
+
+// TestRejectedPatchNotLoggedAsFailure checks that a patch which does not
+// apply is logged and counted as rejected by the store, rather than as a
+// failure of it.
+func TestRejectedPatchNotLoggedAsFailure(t *testing.T) {
+	var b bytes.Buffer
+	logged := application.App{Log: logging.New(&b, logging.Warn), APIKeys: []auth.APIKey{adminKey}}
+	logged.InitializeWithStore(recipes.NewMemoryStore())
+	logged.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "soup", Difficulty: 1})
+	b.Reset()
+
+	for _, p := range []struct {
+		payload string
+		status  int
+	}{
+		{`[{"op":"test","path":"/name","value":"stew"}]`, http.StatusConflict},
+		{`[{"op":"replace","path":"/difficulty","value":7}]`, http.StatusUnprocessableEntity},
+	} {
+		req, _ := http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(p.payload))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+		req.Header.Set("X-API-Key", adminKey.Key)
+		checkResponseCode(t, p.status, serve(&logged, req).Code)
+	}
+
+	for _, e := range logEntries(t, &b) {
+		if e["level"] == "error" || (e["msg"] != "store operation rejected" && e["msg"] != "request") {
+			t.Errorf("Expected the patches to be logged as rejected. Got '%v'", e)
+		}
+	}
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	body := serve(&logged, req).Body.String()
+	if expected := `recipes_store_operation_duration_seconds_count{operation="PatchRecipe",result="rejected"} 2`; !strings.Contains(body, expected) {
+		t.Errorf("Expected the metrics to contain '%s'. Got:\n%s", expected, body)
+	}
+}
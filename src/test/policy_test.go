@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+const testRecipe = `{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`
+
+// createOwnedRecipe creates a recipe as the holder of a token, and returns its path.
+func createOwnedRecipe(t *testing.T, token string) string {
+	response := postJSON(t, "POST", "/v1/recipes", testRecipe, token)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["owner_id"] == nil {
+		t.Errorf("Expected the recipe to have an owner. Got '%s'", response.Body.String())
+	}
+	id, _ := m["id"].(float64)
+	return "/v1/recipes/" + strconv.Itoa(int(id))
+}
+
+func TestRecipeOwnership(t *testing.T) {
+	clearTables()
+	owner := newUserToken(t)
+	other := newUserToken(t)
+	recipe := createOwnedRecipe(t, owner)
+
+	// only the owner may change the recipe and its ingredients and steps
+	for _, tc := range []struct {
+		method  string
+		path    string
+		payload string
+	}{
+		{"PUT", recipe, testRecipe},
+		{"PATCH", recipe, `{"name":"renamed"}`},
+		{"POST", recipe + "/ingredients", `{"name":"milk","quantity":300,"unit":"ml"}`},
+		{"POST", recipe + "/steps", `{"text":"whisk"}`},
+		{"DELETE", recipe, ""},
+	} {
+		response := postJSON(t, tc.method, tc.path, tc.payload, other)
+		checkResponseCode(t, http.StatusForbidden, response.Code)
+		checkProblem(t, response, "forbidden", "Only the owner of a recipe, or a moderator, may change it")
+
+		response = postJSON(t, tc.method, tc.path, tc.payload, owner)
+		if response.Code != http.StatusOK && response.Code != http.StatusCreated {
+			t.Errorf("Expected the owner to be allowed to %s %s. Got %d", tc.method, tc.path, response.Code)
+		}
+	}
+}
+
+func TestOwnerIsKeptOnUpdate(t *testing.T) {
+	clearTables()
+	owner := newUserToken(t)
+	recipe := createOwnedRecipe(t, owner)
+
+	response := postJSON(t, "PUT", recipe,
+		`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true,"owner_id":999}`, owner)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["owner_id"] == 999.0 {
+		t.Errorf("Expected the owner not to change. Got '%s'", response.Body.String())
+	}
+}
+
+func TestModeratorMayChangeAnyRecipe(t *testing.T) {
+	clearTables()
+	// recipes created before there were owners may only be changed by moderators
+	addRecipes(1)
+	author := newUserToken(t)
+	moderator := newUserTokenWithRole(t, "moderator")
+
+	response := postJSON(t, "PATCH", "/v1/recipes/1", `{"name":"renamed"}`, author)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	response = postJSON(t, "PATCH", "/v1/recipes/1", `{"name":"renamed"}`, moderator)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	recipe := createOwnedRecipe(t, author)
+	response = postJSON(t, "DELETE", recipe, "", moderator)
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestViewerMayNotCreateRecipes(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+	viewer := newUserTokenWithRole(t, "viewer")
+
+	response := postJSON(t, "POST", "/v1/recipes", testRecipe, viewer)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	checkProblem(t, response, "forbidden", "")
+
+	// but may still rate them
+	response = postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":4}`, viewer)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestModerateRatings(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+	author := newUserToken(t)
+	moderator := newUserTokenWithRole(t, "moderator")
+
+	response := postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":1}`, author)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating/1", "", author)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating/1", "", moderator)
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestSetUserRole(t *testing.T) {
+	clearTables()
+	admin := newUserTokenWithRole(t, "admin")
+	moderator := newUserTokenWithRole(t, "moderator")
+	newUserToken(t)
+
+	response := postJSON(t, "PUT", "/v1/users/3/role", `{"role":"moderator"}`, moderator)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	response = postJSON(t, "PUT", "/v1/users/3/role", `{"role":"superuser"}`, admin)
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	checkProblem(t, response, "validation_failed", "")
+
+	response = postJSON(t, "PUT", "/v1/users/99/role", `{"role":"viewer"}`, admin)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	response = postJSON(t, "PUT", "/v1/users/3/role", `{"role":"viewer"}`, admin)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["role"] != "viewer" {
+		t.Errorf("Expected role 'viewer'. Got '%v'", m["role"])
+	}
+}
+
+func TestDemotionTakesEffectAtOnce(t *testing.T) {
+	clearTables()
+	addRecipes(2)
+	author := newUserToken(t)
+	moderator := newUserTokenWithRole(t, "moderator")
+
+	response := postJSON(t, "PATCH", "/v1/recipes/1", `{"name":"renamed"}`, moderator)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	response = postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":1}`, author)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	response = postJSON(t, "GET", "/v1/users/me", "", moderator)
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	id, _ := m["user_id"].(float64)
+	req, _ := http.NewRequest("PUT", "/v1/users/"+strconv.Itoa(int(id))+"/role", bytes.NewBufferString(`{"role":"viewer"}`))
+	checkResponseCode(t, http.StatusOK, executeRequest(req).Code)
+
+	// the token still claims the moderator role, but the stored role is used
+	response = postJSON(t, "PATCH", "/v1/recipes/2", `{"name":"renamed"}`, moderator)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating/1", "", moderator)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	response = postJSON(t, "POST", "/v1/recipes", testRecipe, moderator)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+}
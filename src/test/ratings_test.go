@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	// local import
+	"recipes"
+)
+
+// getRatingStats gets the rating statistics of a recipe.
+func getRatingStats(t *testing.T, recipe string) recipes.RatingStats {
+	req, err := http.NewRequest("GET", "/v1/recipes/"+recipe+"/rating", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var st recipes.RatingStats
+	json.Unmarshal(response.Body.Bytes(), &st)
+	return st
+}
+
+func checkRatingStats(t *testing.T, expected recipes.RatingStats, actual recipes.RatingStats) {
+	if expected != actual {
+		t.Errorf("Expected rating statistics %+v. Got %+v", expected, actual)
+	}
+}
+
+func TestRatingStats(t *testing.T) {
+	clearTables()
+	addRecipes(2)
+
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 1}, getRatingStats(t, "1"))
+
+	addRecipeRating(1, 5)
+	addRecipeRating(1, 4)
+	addRecipeRating(1, 4)
+	addRecipeRating(1, 2)
+	addRecipeRating(2, 1)
+
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 1, Count: 4, Sum: 15, AvgRating: 3.75,
+		Histogram: [5]int{0, 1, 0, 2, 1}}, getRatingStats(t, "1"))
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 2, Count: 1, Sum: 1, AvgRating: 1,
+		Histogram: [5]int{1, 0, 0, 0, 0}}, getRatingStats(t, "2"))
+
+	req, err := http.NewRequest("GET", "/v1/recipes/9999/rating", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "Recipe not found")
+}
+
+func TestDeleteRating(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+	addRecipeRating(1, 5)
+	addRecipeRating(1, 2)
+
+	req, err := http.NewRequest("DELETE", "/v1/recipes/1/rating/2", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (DELETE): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 1, Count: 1, Sum: 5, AvgRating: 5,
+		Histogram: [5]int{0, 0, 0, 0, 1}}, getRatingStats(t, "1"))
+
+	// the rating has gone, and the search reads the updated average
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "Rating not found")
+
+	req, err = http.NewRequest("GET", "/v1/recipes/search?min_rating=4", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var page recipePage
+	json.Unmarshal(response.Body.Bytes(), &page)
+	if len(page.Data) != 1 || page.Data[0]["avg_rating"] != 5.0 || page.Data[0]["rating_count"] != 1.0 {
+		t.Errorf("Expected recipe 1 with an average rating of '5' from '1' rating. Got '%s'", response.Body.String())
+	}
+}
+
+func TestRecomputeRatingStats(t *testing.T) {
+	clearTables()
+	addRecipes(3)
+	addRecipeRatings(1, 7)
+	addRecipeRating(3, 4)
+	app.Store.DeleteRecipeRating(context.Background(), &recipes.RecipeRating{RecipeID: 1, ID: 1})
+
+	before := getRatingStats(t, "1")
+	n, err := app.Store.RecomputeRatingStats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the rating statistics to be recomputed. Got '%v'", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected '2' rated recipes. Got '%v'", n)
+	}
+	checkRatingStats(t, before, getRatingStats(t, "1"))
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 1, Count: 6, Sum: 17, AvgRating: float32(17) / 6,
+		Histogram: [5]int{1, 2, 1, 1, 1}}, before)
+	checkRatingStats(t, recipes.RatingStats{RecipeID: 2}, getRatingStats(t, "2"))
+}
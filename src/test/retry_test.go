@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	// local import
+	"application"
+	"auth"
+	"recipes"
+	// GitHub packages
+	"github.com/lib/pq"
+)
+
+// contendedDriver is a database/sql driver which records the statements it is
+// sent and fails the first `failures` INSERTs with a CockroachDB retry error.
+type contendedDriver struct {
+	mu         sync.Mutex
+	failures   int
+	statements []string
+}
+
+func (d *contendedDriver) Open(name string) (driver.Conn, error) { return &contendedConn{d}, nil }
+
+func (d *contendedDriver) exec(query string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, query)
+	if strings.HasPrefix(query, "INSERT") && d.failures > 0 {
+		d.failures--
+		return &pq.Error{Code: "40001", Message: "restart transaction"}
+	}
+	return nil
+}
+
+type contendedConn struct{ d *contendedDriver }
+
+func (c *contendedConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *contendedConn) Close() error { return nil }
+func (c *contendedConn) Begin() (driver.Tx, error) {
+	return &contendedTx{c.d}, c.d.exec("BEGIN")
+}
+func (c *contendedConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), c.d.exec(query)
+}
+func (c *contendedConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	if err := c.d.exec(query); err != nil {
+		return nil, err
+	}
+	return &contendedRows{}, nil
+}
+
+type contendedTx struct{ d *contendedDriver }
+
+func (t *contendedTx) Commit() error   { return t.d.exec("COMMIT") }
+func (t *contendedTx) Rollback() error { return t.d.exec("ROLLBACK") }
+
+// contendedRows returns a single row holding the id 42.
+type contendedRows struct{ done bool }
+
+func (r *contendedRows) Columns() []string { return []string{"id"} }
+func (r *contendedRows) Close() error      { return nil }
+func (r *contendedRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+	return nil
+}
+
+var contended = &contendedDriver{}
+
+func init() {
+	sql.Register("contended", contended)
+}
+
+func contendedStore(failures int) *recipes.SQLStore {
+	contended.mu.Lock()
+	contended.failures = failures
+	contended.statements = nil
+	contended.mu.Unlock()
+
+	db, _ := sql.Open("contended", "")
+	store := recipes.NewSQLStore(db)
+	store.Retry = recipes.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return store
+}
+
+func TestTransactionRetry(t *testing.T) {
+	store := contendedStore(2)
+	before := recipes.GetTxStats()
+
+	r := recipes.Recipe{Name: "test recipe", PrepTime: 0.1, Difficulty: 2, Vegetarian: true}
+	if err := store.CreateRecipe(context.Background(), &r); err != nil {
+		t.Fatalf("Expected the transaction to be retried. Got '%v'", err)
+	}
+	if r.ID != 42 {
+		t.Errorf("Expected recipe ID to be '42'. Got '%v'", r.ID)
+	}
+
+	expected := []string{
+		"BEGIN",
+		"SAVEPOINT cockroach_restart",
+		"INSERT",
+		"ROLLBACK TO SAVEPOINT cockroach_restart",
+		"INSERT",
+		"ROLLBACK TO SAVEPOINT cockroach_restart",
+		"INSERT INTO recipes",
+		"INSERT INTO recipe_words",
+		"RELEASE SAVEPOINT cockroach_restart",
+		"COMMIT",
+	}
+	if len(contended.statements) != len(expected) {
+		t.Fatalf("Expected statements %v. Got %v", expected, contended.statements)
+	}
+	for i, statement := range contended.statements {
+		if !strings.HasPrefix(statement, expected[i]) {
+			t.Errorf("Expected statement %d to be '%s'. Got '%s'", i, expected[i], statement)
+		}
+	}
+
+	after := recipes.GetTxStats()
+	if after.Retries-before.Retries != 2 {
+		t.Errorf("Expected '2' retries. Got '%v'", after.Retries-before.Retries)
+	}
+}
+
+func TestTransactionRetriesExhausted(t *testing.T) {
+	store := contendedStore(10)
+	before := recipes.GetTxStats()
+
+	rr := recipes.RecipeRating{RecipeID: 1, Rating: 3}
+	_, err := store.AddRecipeRating(context.Background(), &rr)
+	if !recipes.IsRetryable(err) {
+		t.Fatalf("Expected a retryable error. Got '%v'", err)
+	}
+	if last := contended.statements[len(contended.statements)-1]; last != "ROLLBACK" {
+		t.Errorf("Expected the transaction to be rolled back. Got '%s'", last)
+	}
+
+	after := recipes.GetTxStats()
+	if after.Retries-before.Retries != 3 {
+		t.Errorf("Expected '3' retries. Got '%v'", after.Retries-before.Retries)
+	}
+	if after.Exhausted-before.Exhausted != 1 {
+		t.Errorf("Expected '1' exhausted transaction. Got '%v'", after.Exhausted-before.Exhausted)
+	}
+}
+
+func TestRetriesExhaustedResponse(t *testing.T) {
+	contendedApp := application.App{APIKeys: []auth.APIKey{adminKey}}
+	contendedApp.InitializeWithStore(contendedStore(10))
+
+	payload := []byte(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("X-API-Key", adminKey.Key)
+	response := httptest.NewRecorder()
+	contendedApp.Router.ServeHTTP(response, req)
+
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+	m := checkProblem(t, response, "retryable", "")
+	if strings.Contains(response.Body.String(), "restart transaction") {
+		t.Errorf("Expected the database error not to be sent to the client. Got '%v'", m)
+	}
+	if response.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
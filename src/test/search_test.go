@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	// local import
+	"recipes"
+)
+
+// addSearchRecipes adds recipes with a spread of names, ingredients and ratings.
+func addSearchRecipes() {
+	app.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "Boiled Egg", PrepTime: 10, Difficulty: 1, Vegetarian: true,
+		Ingredients: []recipes.Ingredient{{Name: "Egg", Quantity: 1}}})
+	app.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "Egg Fried Rice", PrepTime: 25, Difficulty: 2, Vegetarian: true,
+		Ingredients: []recipes.Ingredient{{Name: "egg", Quantity: 2}, {Name: "rice", Quantity: 200}}})
+	app.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "Chicken Fried Rice", PrepTime: 30, Difficulty: 2, Vegetarian: false,
+		Ingredients: []recipes.Ingredient{{Name: "chicken", Quantity: 1}, {Name: "rice", Quantity: 200}}})
+	app.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "Roast Chicken", PrepTime: 90, Difficulty: 3, Vegetarian: false,
+		Ingredients: []recipes.Ingredient{{Name: "chicken", Quantity: 1}}})
+
+	addRecipeRating(1, 2)
+	addRecipeRating(2, 5)
+	addRecipeRating(2, 4)
+	addRecipeRating(3, 3)
+}
+
+// searchIDs runs a search and returns the IDs of the recipes found, in order.
+func searchIDs(t *testing.T, req *http.Request) []int {
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var page recipePage
+	json.Unmarshal(response.Body.Bytes(), &page)
+	ids := []int{}
+	for _, r := range page.Data {
+		// the id is converted from float64 because JSON unmarshaling converts
+		//     numbers to floats, when the target is a map[string]interface{}
+		id, _ := r["id"].(float64)
+		ids = append(ids, int(id))
+	}
+	return ids
+}
+
+func checkIDs(t *testing.T, search string, expected []int, actual []int) {
+	if len(expected) != len(actual) {
+		t.Errorf("Expected search '%s' to find %v. Got %v", search, expected, actual)
+		return
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("Expected search '%s' to find %v. Got %v", search, expected, actual)
+			return
+		}
+	}
+}
+
+func TestSearchQueryParams(t *testing.T) {
+	clearTables()
+	addSearchRecipes()
+
+	searches := []struct {
+		query    string
+		expected []int
+	}{
+		{"", []int{1, 2, 3, 4}},
+		{"q=fried+RICE", []int{2, 3}},
+		{"q=chicken&sort=-preptime", []int{4, 3}},
+		{"min_difficulty=2&max_difficulty=2", []int{2, 3}},
+		{"vegetarian=false", []int{3, 4}},
+		{"min_rating=3", []int{2, 3}},
+		{"min_preptime=25&max_preptime=30", []int{2, 3}},
+		{"preptime=30", []int{1, 2}},
+		{"ingredients=Rice&ingredients=egg", []int{2}},
+		{"exclude_ingredients=chicken", []int{1, 2}},
+		{"ingredients=rice&exclude_ingredients=egg", []int{3}},
+		{"sort=-rating", []int{2, 3, 1, 4}},
+		{"sort=name", []int{1, 3, 2, 4}},
+		{"sort=preptime&count=2", []int{1, 2}},
+	}
+	for _, s := range searches {
+		req, err := http.NewRequest("GET", "/v1/recipes/search?"+s.query, nil)
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (GET): %s", err)
+		}
+		checkIDs(t, s.query, s.expected, searchIDs(t, req))
+	}
+}
+
+func TestSearchJSON(t *testing.T) {
+	clearTables()
+	addSearchRecipes()
+
+	payload := `{"q":"rice","vegetarian":true,"min_rating":4,"exclude_ingredients":["chicken"],"sort":"-rating"}`
+
+	req, err := http.NewRequest("POST", "/v1/recipes/search", bytes.NewBufferString(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	checkIDs(t, payload, []int{2}, searchIDs(t, req))
+}
+
+func TestSearchInvalid(t *testing.T) {
+	clearTables()
+
+	req, err := http.NewRequest("GET", "/v1/recipes/search?sort=price&min_rating=high", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"min_rating": "invalid_type"})
+
+	req, err = http.NewRequest("POST", "/v1/recipes/search", bytes.NewBufferString(`{"sort":"price","max_difficulty":4}`))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m = checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"max_difficulty": "too_large", "sort": "not_allowed"})
+}
+
+func TestSearchNonFiniteNumber(t *testing.T) {
+	clearTables()
+
+	for _, query := range []string{"min_preptime=NaN", "max_preptime=Inf", "min_rating=-Infinity&preptime=nan"} {
+		req, err := http.NewRequest("GET", "/v1/recipes/search?"+query, nil)
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (GET): %s", err)
+		}
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+		checkProblem(t, response, "bad_request", "")
+	}
+	req, _ := http.NewRequest("GET", "/v1/recipes/search?min_preptime=NaN", nil)
+	checkProblem(t, executeRequest(req), "bad_request", "min_preptime must be a finite number")
+}
+
+func TestSearchRelevance(t *testing.T) {
+	clearTables()
+	for _, name := range []string{"Tomato soup", "Roast tomato and basil soup", "Tomatoes on toast", "Pancakes", "Chicken soup"} {
+		app.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: name, Difficulty: 1})
+	}
+
+	searches := []struct {
+		query    string
+		expected []int
+	}{
+		// the name which is most nearly the query comes first
+		{"q=tomato+soup", []int{1, 2}},
+		// plurals are folded, in the query and the names
+		{"q=tomatoes", []int{1, 3, 2}},
+		{"q=pancake", []int{4}},
+		// a query word may begin a word of the name, but not end it
+		{"q=tom", []int{1, 3, 2}},
+		{"q=cakes", []int{}},
+		{"q=soup&sort=name", []int{5, 2, 1}},
+	}
+	for _, s := range searches {
+		req, err := http.NewRequest("GET", "/v1/recipes/search?"+s.query, nil)
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (GET): %s", err)
+		}
+		checkIDs(t, s.query, s.expected, searchIDs(t, req))
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/search?q=tomato+soup", nil)
+	var page recipePage
+	json.Unmarshal(executeRequest(req).Body.Bytes(), &page)
+	if len(page.Data) != 2 || page.Data[0]["relevance"] != 1.0 || page.Data[1]["relevance"] != 0.4 {
+		t.Errorf("Expected relevances of '1' and '0.4'. Got '%v'", page.Data)
+	}
+
+	// the pages of a search by relevance follow on from each other
+	ids, links := getPage(t, "/v1/recipes/search?q=tomatoes&count=2")
+	checkIDs(t, "page 1", []int{1, 3}, ids)
+	ids, links = getPage(t, links["next"])
+	checkIDs(t, "page 2", []int{2}, ids)
+	ids, _ = getPage(t, links["prev"])
+	checkIDs(t, "page 1 again", []int{1, 3}, ids)
+}
+
+func TestSearchIndexFollowsWrites(t *testing.T) {
+	clearTables()
+	r := recipes.Recipe{Name: "Pea soup", Difficulty: 1}
+	app.Store.CreateRecipe(context.Background(), &r)
+
+	r.Name = "Lentil stew"
+	if err := app.Store.UpdateRecipe(context.Background(), &r); err != nil {
+		t.Fatalf("Expected the recipe to be updated. Got '%v'", err)
+	}
+	for query, expected := range map[string][]int{"q=soup": {}, "q=lentils": {1}} {
+		req, _ := http.NewRequest("GET", "/v1/recipes/search?"+query, nil)
+		checkIDs(t, query, expected, searchIDs(t, req))
+	}
+
+	app.Store.DeleteRecipe(context.Background(), &r)
+	req, _ := http.NewRequest("GET", "/v1/recipes/search?q=stew", nil)
+	checkIDs(t, "q=stew", []int{}, searchIDs(t, req))
+}
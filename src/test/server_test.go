@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+	// local import
+	"application"
+	"config"
+	"recipes"
+)
+
+// startServer serves an app with a route which takes delay to respond,
+// and returns the address it listens on and the result of Serve.
+func startServer(t *testing.T, ctx context.Context, delay time.Duration, shutdownTimeout time.Duration) (string, <-chan error) {
+	a := application.App{Server: config.ServerConfig{ShutdownTimeout: config.Duration{Duration: shutdownTimeout}}}
+	a.InitializeWithStore(recipes.NewMemoryStore())
+	a.Router.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte("done"))
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, l)
+	}()
+	return l.Addr().String(), served
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, served := startServer(t, ctx, 200*time.Millisecond, 5*time.Second)
+
+	response, err := http.Get("http://" + addr + "/v1/recipes")
+	if err != nil {
+		t.Fatalf("Expected the server to be serving. Got '%v'", err)
+	}
+	response.Body.Close()
+	checkResponseCode(t, http.StatusOK, response.StatusCode)
+
+	// a request in flight when the shutdown begins is allowed to finish
+	finished := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			finished <- 0
+			return
+		}
+		response.Body.Close()
+		finished <- response.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	checkResponseCode(t, http.StatusOK, <-finished)
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown. Got '%v'", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Serve to return after the shutdown")
+	}
+
+	if _, err := http.Get("http://" + addr + "/v1/recipes"); err == nil {
+		t.Error("Expected new connections to be refused after the shutdown")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, served := startServer(t, ctx, 2*time.Second, 50*time.Millisecond)
+
+	go http.Get("http://" + addr + "/slow")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Expected an error when requests outlive the shutdown timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Serve to give up on the slow request at the deadline")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"toast","difficulty":1,"vegetarian":true,"derive_preptime":true}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST recipe): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	for _, step := range []string{
+		`{"text":"slice the bread","duration":1}`,
+		`{"text":"toast the bread","duration":3,"timer":180}`,
+		`{"text":"butter the toast"}`,
+	} {
+		req, err = http.NewRequest("POST", "/v1/recipes/1/steps", bytes.NewBufferString(step))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (POST step): %s", err)
+		}
+		response = executeRequest(req)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+	}
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	// the position is compared to 3.0 because JSON unmarshaling converts numbers to
+	//     floats (float64), when the target is a map[string]interface{}
+	if m["position"] != 3.0 {
+		t.Errorf("Expected step position to be '3'. Got '%v'", m["position"])
+	}
+
+	checkRecipePrepTime(t, 4.0)
+
+	payload = []byte(`{"step_ids":[2,1,3]}`)
+
+	req, err = http.NewRequest("PUT", "/v1/recipes/1/steps/order", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PUT order): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var mm []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &mm)
+	if len(mm) != 3 || mm[0]["text"] != "toast the bread" || mm[0]["position"] != 1.0 {
+		t.Errorf("Expected 'toast the bread' to be the first step. Got '%v'", mm)
+	}
+
+	payload = []byte(`{"text":"toast the bread until golden","duration":5}`)
+
+	req, err = http.NewRequest("PUT", "/v1/recipes/1/steps/2", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PUT step): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	checkRecipePrepTime(t, 6.0)
+
+	req, err = http.NewRequest("DELETE", "/v1/recipes/1/steps/2", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (DELETE step): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	checkRecipePrepTime(t, 1.0)
+
+	req, err = http.NewRequest("GET", "/v1/recipes/1/steps", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET steps): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &mm)
+	if len(mm) != 2 || mm[0]["step_id"] != 1.0 || mm[0]["position"] != 1.0 || mm[1]["position"] != 2.0 {
+		t.Errorf("Expected the remaining steps to be renumbered. Got '%v'", mm)
+	}
+}
+
+func TestInvalidStepOrder(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	for _, step := range []string{`{"text":"one"}`, `{"text":"two"}`} {
+		req, _ := http.NewRequest("POST", "/v1/recipes/1/steps", bytes.NewBufferString(step))
+		executeRequest(req)
+	}
+
+	for _, order := range []string{`{"step_ids":[1]}`, `{"step_ids":[1,1]}`, `{"step_ids":[1,3]}`} {
+		req, err := http.NewRequest("PUT", "/v1/recipes/1/steps/order", bytes.NewBufferString(order))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (PUT order): %s", err)
+		}
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestReorderStepsInvalidPayload(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	req, _ := http.NewRequest("PUT", "/v1/recipes/1/steps/order", bytes.NewBufferString(`{"step_ids":[1],"reverse":true}`))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"reverse": "unknown_field"})
+
+	req, _ = http.NewRequest("PUT", "/v1/recipes/1/steps/order", bytes.NewBufferString(`{"step_ids":`))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	checkProblem(t, response, "bad_request", "Invalid request payload")
+}
+
+func checkRecipePrepTime(t *testing.T, expected float64) {
+	req, err := http.NewRequest("GET", "/v1/recipes/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET recipe): %s", err)
+	}
+	response := executeRequest(req)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["preptime"] != expected {
+		t.Errorf("Expected recipe preptime to be '%v'. Got '%v'", expected, m["preptime"])
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	// local import
+	"application"
+	"auth"
+	"config"
+	"recipes"
+)
+
+// slowStore reads recipes from a database which never answers, returning
+// once the context of the call is done, as the driver does.
+type slowStore struct {
+	recipes.RecipeStore
+}
+
+func (slowStore) GetRecipe(ctx context.Context, r *recipes.Recipe) error {
+	<-ctx.Done()
+	return errors.New("pq: canceling statement due to user request")
+}
+
+func (slowStore) GetRecipes(ctx context.Context, p recipes.Page) ([]recipes.Recipe, recipes.PageInfo, error) {
+	<-ctx.Done()
+	return nil, recipes.PageInfo{}, errors.New("pq: canceling statement due to user request")
+}
+
+func TestStoreTimeout(t *testing.T) {
+	limited := application.App{APIKeys: []auth.APIKey{adminKey},
+		StoreTimeout: 20 * time.Millisecond}
+	limited.InitializeWithStore(slowStore{recipes.NewMemoryStore()})
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	start := time.Now()
+	response := serve(&limited, req)
+
+	checkResponseCode(t, http.StatusGatewayTimeout, response.Code)
+	checkProblem(t, response, "timeout", "The database did not respond in time")
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected the request to be abandoned after '20ms'. Got '%v'", d)
+	}
+}
+
+func TestStoreTimeoutOfOperation(t *testing.T) {
+	limited := application.App{APIKeys: []auth.APIKey{adminKey},
+		StoreTimeout:  time.Hour,
+		StoreTimeouts: map[string]time.Duration{"GetRecipes": 20 * time.Millisecond}}
+	limited.InitializeWithStore(slowStore{recipes.NewMemoryStore()})
+
+	req, _ := http.NewRequest("GET", "/v1/recipes", nil)
+	response := serve(&limited, req)
+
+	checkResponseCode(t, http.StatusGatewayTimeout, response.Code)
+	checkProblem(t, response, "timeout", "")
+}
+
+func TestCancelledRequest(t *testing.T) {
+	limited := application.App{APIKeys: []auth.APIKey{adminKey}}
+	limited.InitializeWithStore(slowStore{recipes.NewMemoryStore()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	response := serve(&limited, req.WithContext(ctx))
+
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+	checkProblem(t, response, "unavailable", "The request was cancelled")
+}
+
+func TestStoreTimeoutsConfig(t *testing.T) {
+	withEnv(map[string]string{
+		"COCKROACH_USER": "root",
+		"COCKROACH_DB":   "recipes",
+		"STORE_TIMEOUT":  "2s",
+		"STORE_TIMEOUTS": "GetRecipe=500ms, GetRecipesRated=0",
+	}, func() {
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Expected a valid configuration. Got '%v'", err)
+		}
+		if cfg.Store.Timeout.Duration != 2*time.Second {
+			t.Errorf("Expected the timeout to be '2s'. Got '%v'", cfg.Store.Timeout)
+		}
+		timeouts := cfg.Store.TimeoutMap()
+		if timeouts["GetRecipe"] != 500*time.Millisecond {
+			t.Errorf("Expected the timeout of GetRecipe to be '500ms'. Got '%v'", timeouts["GetRecipe"])
+		}
+		if d, ok := timeouts["GetRecipesRated"]; !ok || d != 0 {
+			t.Errorf("Expected GetRecipesRated not to be limited. Got '%v'", d)
+		}
+	})
+
+	withEnv(map[string]string{
+		"COCKROACH_USER": "root",
+		"COCKROACH_DB":   "recipes",
+		"STORE_TIMEOUTS": "GetRecipe",
+	}, func() {
+		if _, err := config.Load(); err == nil {
+			t.Error("Expected 'GetRecipe' to be an invalid STORE_TIMEOUTS")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	// local import
+	"application"
+	"auth"
+	"recipes"
+	"tracing"
+)
+
+// span is a span as written by the stdout and file exporters.
+type span struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Attributes   map[string]interface{} `json:"attributes"`
+}
+
+// tracedApp returns an app using store whose spans are written to b.
+func tracedApp(store recipes.RecipeStore, b *bytes.Buffer) *application.App {
+	a := &application.App{APIKeys: []auth.APIKey{adminKey},
+		Tracer: tracing.NewTracer("tests", tracing.NewWriterExporter(b), nil)}
+	a.InitializeWithStore(store)
+	return a
+}
+
+// exportedSpans flushes the tracer of an app and parses its spans.
+func exportedSpans(t *testing.T, a *application.App, b *bytes.Buffer) []span {
+	a.Tracer.Flush()
+	var spans []span
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var s span
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Fatalf("Expected a JSON span. Got '%s'", line)
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+func findSpan(t *testing.T, spans []span, name string) span {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("Expected a span named '%s'. Got '%v'", name, spans)
+	return span{}
+}
+
+func TestTraceparent(t *testing.T) {
+	h := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := tracing.ParseTraceparent(h)
+	if err != nil {
+		t.Fatalf("Expected a valid traceparent. Got '%v'", err)
+	}
+	if !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the IDs to be parsed. Got '%v'", sc)
+	}
+	if sc.Traceparent() != h {
+		t.Errorf("Expected '%s'. Got '%s'", h, sc.Traceparent())
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := tracing.ParseTraceparent(invalid); err == nil {
+			t.Errorf("Expected '%s' to be invalid", invalid)
+		}
+	}
+	if _, err := tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("Expected a later version to be accepted. Got '%v'", err)
+	}
+}
+
+func TestRequestSpans(t *testing.T) {
+	var b bytes.Buffer
+	traced := tracedApp(recipes.NewMemoryStore(), &b)
+	traced.Store.CreateRecipe(context.Background(), &recipes.Recipe{Name: "soup", PrepTime: 0.5, Difficulty: 1})
+
+	req, _ := http.NewRequest("GET", "/v1/recipes/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response := serve(traced, req)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	spans := exportedSpans(t, traced, &b)
+	server := findSpan(t, spans, "GET /v1/recipes/{id:[0-9]+}")
+	if server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the span to continue the caller's trace. Got '%v'", server)
+	}
+	if server.Kind != "server" || server.Attributes["http.status_code"] != float64(200) {
+		t.Errorf("Expected a server span with the status code. Got '%v'", server)
+	}
+	for _, name := range []string{"recipes.GetRecipe", "encode JSON"} {
+		if child := findSpan(t, spans, name); child.ParentSpanID != server.SpanID || child.TraceID != server.TraceID {
+			t.Errorf("Expected '%s' to be a child of the request span. Got '%v'", name, child)
+		}
+	}
+}
+
+func TestUntracedRequestStartsTrace(t *testing.T) {
+	var b bytes.Buffer
+	traced := tracedApp(recipes.NewMemoryStore(), &b)
+
+	req, _ := http.NewRequest("GET", "/no/such/path", nil)
+	serve(traced, req)
+
+	server := findSpan(t, exportedSpans(t, traced, &b), "GET unmatched")
+	if server.TraceID == "" || server.ParentSpanID != "" {
+		t.Errorf("Expected a new trace. Got '%v'", server)
+	}
+}
+
+func TestQuerySpans(t *testing.T) {
+	var b bytes.Buffer
+	traced := tracedApp(contendedStore(1), &b)
+
+	payload := []byte(`{"name":"test recipe","preptime":0.1,"difficulty":2,"vegetarian":true}`)
+	req, _ := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	req.Header.Set("X-API-Key", adminKey.Key)
+	serve(traced, req)
+
+	spans := exportedSpans(t, traced, &b)
+	operation := findSpan(t, spans, "recipes.CreateRecipe")
+	tx := findSpan(t, spans, "transaction")
+	if tx.ParentSpanID != operation.SpanID || tx.Attributes["db.attempts"] != float64(2) {
+		t.Errorf("Expected a transaction of 2 attempts under the store operation. Got '%v'", tx)
+	}
+
+	var inserts int
+	for _, s := range spans {
+		// the words of the recipe's name are indexed after it is inserted
+		if s.Name != "INSERT" || strings.HasPrefix(s.Attributes["db.statement"].(string), "INSERT INTO recipe_words") {
+			continue
+		}
+		inserts++
+		if s.ParentSpanID != tx.SpanID || !strings.HasPrefix(s.Attributes["db.statement"].(string), "INSERT INTO recipes") {
+			t.Errorf("Expected the INSERT under the transaction. Got '%v'", s)
+		}
+	}
+	if inserts != 2 {
+		t.Errorf("Expected '2' INSERT spans, one for each attempt. Got '%d'", inserts)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected an authorized post to /v1/traces. Got '%s'", req.URL.Path)
+		}
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer collector.Close()
+
+	tracer := tracing.NewTracer("tests", tracing.NewOTLPExporter(collector.URL, map[string]string{"Authorization": "Bearer secret"}), func(err error) {
+		t.Errorf("Expected the spans to be exported. Got '%v'", err)
+	})
+	_, s := tracer.Start(context.Background(), "GET /v1/recipes", tracing.Server)
+	s.SetAttributes("http.status_code", 200)
+	s.End()
+	tracer.Flush()
+
+	var export struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value map[string]interface{}
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID    string `json:"traceId"`
+					Name       string
+					Kind       int
+					Attributes []struct {
+						Key   string
+						Value map[string]interface{}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(body, &export); err != nil || len(export.ResourceSpans) != 1 {
+		t.Fatalf("Expected an OTLP export request. Got '%s'", body)
+	}
+	rs := export.ResourceSpans[0]
+	if a := rs.Resource.Attributes; len(a) != 1 || a[0].Key != "service.name" || a[0].Value["stringValue"] != "tests" {
+		t.Errorf("Expected the service name. Got '%v'", a)
+	}
+	span := rs.ScopeSpans[0].Spans[0]
+	if span.Name != "GET /v1/recipes" || span.Kind != 2 || span.TraceID != s.SpanContext().TraceID.String() {
+		t.Errorf("Expected the server span. Got '%v'", span)
+	}
+	if a := span.Attributes; len(a) != 1 || a[0].Value["intValue"] != "200" {
+		t.Errorf("Expected an integer attribute. Got '%v'", a)
+	}
+	tracer.Shutdown(context.Background())
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// users counts the users registered by newUserToken, to give each a unique name.
+var users int
+
+// postJSON sends a JSON payload, with a bearer token if one is given and
+// anonymously otherwise.
+func postJSON(t *testing.T, method string, url string, payload string, token string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (%s): %s", method, err)
+	}
+	if token == "" {
+		return executeAnonymous(req)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return executeRequest(req)
+}
+
+// login logs in and returns the access token.
+func login(t *testing.T, username string, password string) string {
+	response := postJSON(t, "POST", "/v1/users/login",
+		`{"username":"`+username+`","password":"`+password+`"}`, "")
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["token_type"] != "Bearer" {
+		t.Errorf("Expected token type to be 'Bearer'. Got '%v'", m["token_type"])
+	}
+	token, _ := m["access_token"].(string)
+	return token
+}
+
+// newUserToken registers a new user, and returns a token for them.
+func newUserToken(t *testing.T) string {
+	return newUserTokenWithRole(t, "")
+}
+
+// newUserTokenWithRole registers a new user, gives them a role (unless it
+// is empty) as the admin API key, and returns a token for them.
+func newUserTokenWithRole(t *testing.T, role string) string {
+	users++
+	username := "user " + strconv.Itoa(users)
+	response := postJSON(t, "POST", "/v1/users", `{"username":"`+username+`","password":"correct horse"}`, "")
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	if role != "" {
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+		id, _ := m["user_id"].(float64)
+		req, _ := http.NewRequest("PUT", "/v1/users/"+strconv.Itoa(int(id))+"/role",
+			bytes.NewBufferString(`{"role":"`+role+`"}`))
+		response = executeRequest(req)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+	}
+	return login(t, username, "correct horse")
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	clearTables()
+
+	response := postJSON(t, "POST", "/v1/users", `{"username":"alice","password":"correct horse"}`, "")
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["username"] != "alice" || m["user_id"] == nil {
+		t.Errorf("Expected user 'alice' with an ID. Got '%s'", response.Body.String())
+	}
+	if _, ok := m["password_hash"]; ok || bytes.Contains(response.Body.Bytes(), []byte("horse")) {
+		t.Errorf("Expected the password not to be returned. Got '%s'", response.Body.String())
+	}
+
+	response = postJSON(t, "POST", "/v1/users", `{"username":"alice","password":"battery staple"}`, "")
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+	checkProblem(t, response, "conflict", "Username is already taken")
+
+	response = postJSON(t, "POST", "/v1/users", `{"username":"bob","password":"short"}`, "")
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m = checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"password": "too_short"})
+
+	// 40 characters, but 80 bytes, which is too long for bcrypt
+	response = postJSON(t, "POST", "/v1/users", `{"username":"bob","password":"`+strings.Repeat("é", 40)+`"}`, "")
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m = checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"password": "too_long"})
+
+	response = postJSON(t, "POST", "/v1/users", `{"username":"bob","password":"`+strings.Repeat("é", 36)+`"}`, "")
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	for _, payload := range []string{
+		`{"username":"alice","password":"battery staple"}`,
+		`{"username":"nobody","password":"correct horse"}`,
+	} {
+		response = postJSON(t, "POST", "/v1/users/login", payload, "")
+
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+		checkProblem(t, response, "unauthorized", "Invalid username or password")
+	}
+
+	token := login(t, "alice", "correct horse")
+
+	req, err := http.NewRequest("GET", "/v1/users/me", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["username"] != "alice" {
+		t.Errorf("Expected the current user to be 'alice'. Got '%v'", m["username"])
+	}
+}
+
+func TestRateWithoutToken(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	for _, token := range []string{"", "not.a.token"} {
+		response := postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":3}`, token)
+
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+		checkProblem(t, response, "unauthorized", "")
+		if response.Header().Get("WWW-Authenticate") == "" {
+			t.Error("Expected a WWW-Authenticate header")
+		}
+	}
+}
+
+func TestRatingUpsertAndWithdraw(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+	token := newUserToken(t)
+	other := newUserToken(t)
+
+	response := postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":2}`, token)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	var first map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &first)
+
+	// rating again replaces the rating, rather than adding another
+	response = postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":5}`, token)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+	var second map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &second)
+	if second["rating_id"] != first["rating_id"] || second["user_id"] != first["user_id"] {
+		t.Errorf("Expected the rating to be updated in place. Got '%v' then '%v'", first, second)
+	}
+
+	response = postJSON(t, "POST", "/v1/recipes/1/rating", `{"rating":4}`, other)
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	st := getRatingStats(t, "1")
+	if st.Count != 2 || st.Sum != 9 || st.Histogram != [5]int{0, 0, 0, 1, 1} {
+		t.Errorf("Expected '2' ratings of 4 and 5. Got %+v", st)
+	}
+
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating", "", token)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	response = postJSON(t, "DELETE", "/v1/recipes/1/rating", "", token)
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+	checkProblem(t, response, "not_found", "Rating not found")
+
+	st = getRatingStats(t, "1")
+	if st.Count != 1 || st.Sum != 4 {
+		t.Errorf("Expected only the other user's rating of 4. Got %+v", st)
+	}
+}
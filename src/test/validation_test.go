@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+// checkFieldErrors checks that a problem lists exactly the expected
+// codes for the expected fields.
+func checkFieldErrors(t *testing.T, m map[string]interface{}, expected map[string]string) {
+	errs, _ := m["errors"].([]interface{})
+	if len(errs) != len(expected) {
+		t.Errorf("Expected %d field errors. Got '%v'", len(expected), m["errors"])
+	}
+	for _, e := range errs {
+		fe, _ := e.(map[string]interface{})
+		field, _ := fe["field"].(string)
+		if code, ok := expected[field]; !ok || fe["code"] != code {
+			t.Errorf("Expected field '%s' to have code '%s'. Got '%v'", field, code, fe["code"])
+		}
+		if fe["message"] == "" {
+			t.Errorf("Expected field '%s' to have a message. Got '%v'", field, fe)
+		}
+	}
+}
+
+func TestCreateInvalidRecipe(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":" ","preptime":-1,"difficulty":4,"vegetarian":true,
+		"ingredients":[{"name":"egg","quantity":-2}],"steps":[{"text":"Boil","timer":0}]}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{
+		"name":                    "required",
+		"preptime":                "too_small",
+		"difficulty":              "too_large",
+		"ingredients[0].quantity": "too_small",
+		"steps[0].timer":          "too_small",
+	})
+
+	req, err = http.NewRequest("GET", "/v1/recipes/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (GET): %s", err)
+	}
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+func TestCreateRecipeNameTooLong(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"` + string(bytes.Repeat([]byte("x"), 201)) + `","preptime":10,"difficulty":1}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"name": "too_long"})
+}
+
+func TestCreateRecipeUnknownField(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"x","preptime":10,"difficulty":1,"serves":4,
+		"ingredients":[{"name":"egg","quantity":2,"colour":"brown"}]}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{
+		"serves":                "unknown_field",
+		"ingredients[0].colour": "unknown_field",
+	})
+}
+
+func TestCreateRecipeInvalidType(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"x","preptime":"ten","difficulty":1}`)
+
+	req, err := http.NewRequest("POST", "/v1/recipes", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (POST): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"preptime": "invalid_type"})
+}
+
+func TestModifyRecipeInvalid(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	payload := []byte(`{"name":"x","preptime":10,"difficulty":0}`)
+
+	req, err := http.NewRequest("PUT", "/v1/recipes/1", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PUT): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"difficulty": "too_small"})
+}
+
+func TestPatchRecipeInvalid(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+
+	req, err := http.NewRequest("PATCH", "/v1/recipes/1", bytes.NewBufferString(`{"name":""}`))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (PATCH): %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	m := checkProblem(t, response, "validation_failed", "")
+	checkFieldErrors(t, m, map[string]string{"name": "required"})
+}
+
+func TestAddInvalidRating(t *testing.T) {
+	clearTables()
+	addRecipes(1)
+	token := newUserToken(t)
+
+	for _, rating := range []string{"0", "6"} {
+		payload := []byte(`{"rating":` + rating + `}`)
+
+		req, err := http.NewRequest("POST", "/v1/recipes/1/rating", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (POST): %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+		checkProblem(t, response, "validation_failed", "")
+	}
+}
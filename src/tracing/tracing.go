@@ -0,0 +1,422 @@
+// Package tracing records OpenTelemetry spans and exports them, either in
+// the OTLP/HTTP JSON encoding to a collector or as JSON lines to a file.
+// Trace context is propagated with the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace, which is made up of spans.
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span which is propagated to other processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether neither ID is all zeros.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceparentHeader is the W3C Trace Context header.
+const TraceparentHeader = "traceparent"
+
+// Traceparent formats the span context as a version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ErrInvalidTraceparent is returned for a traceparent header which is
+// malformed, or holds an all zero ID.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a traceparent header. Versions after 00 are
+// accepted, as the specification requires, if they start with its fields.
+func ParseTraceparent(h string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	var version [1]byte
+	if len(parts) < 4 || !decodeHex(version[:], parts[0]) || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return sc, ErrInvalidTraceparent
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes exactly len(dst) bytes of lower case hex.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || s != strings.ToLower(s) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Kind is the role of a span in a trace, as numbered by OTLP.
+type Kind int
+
+// The kinds of span which are recorded.
+const (
+	Internal Kind = 1
+	Server   Kind = 2
+	Client   Kind = 3
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Server:
+		return "server"
+	case Client:
+		return "client"
+	}
+	return "internal"
+}
+
+// Attribute is a key and a string, bool, integer or float value.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a timed operation within a trace. The methods of a nil *Span do
+// nothing, so that code does not need to check whether it is traced.
+type Span struct {
+	tracer *Tracer
+
+	mu         sync.Mutex
+	name       string
+	kind       Kind
+	context    SpanContext
+	parent     SpanID
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	failed     bool
+	message    string
+}
+
+// SpanContext returns the IDs of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetName renames the span, for a name which is only known once the
+// operation has run, such as the route of a request.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes adds key/value pairs to the span.
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		s.attributes = append(s.attributes, Attribute{fmt.Sprint(keyvals[i]), keyvals[i+1]})
+	}
+}
+
+// SetError marks the span as failed, with the message of err.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.message = err.Error()
+}
+
+// End records the end of the span, and queues it for export if its trace
+// is sampled. Calls after the first do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	data := SpanData{
+		Name:        s.name,
+		Kind:        s.kind,
+		SpanContext: s.context,
+		Parent:      s.parent,
+		Start:       s.start,
+		End:         s.end,
+		Attributes:  append([]Attribute(nil), s.attributes...),
+		Failed:      s.failed,
+		Message:     s.message,
+	}
+	s.mu.Unlock()
+	if s.context.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// SpanData is a span which has ended, as it is exported.
+type SpanData struct {
+	Name string
+	Kind Kind
+	SpanContext
+	// Parent is all zeros for the root span of a trace
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	// Failed is set, along with the error message, by Span.SetError
+	Failed  bool
+	Message string
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+// ContextWithSpan returns a context carrying a span, which becomes the
+// parent of the spans started from the context.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey, s)
+}
+
+// SpanFromContext returns the span carried by a context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey).(*Span)
+	return s
+}
+
+// ContextWithRemoteParent returns a context carrying the span context of
+// a caller in another process, which becomes the parent of the next span
+// started from the context.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// Extract returns a context carrying the span context of the traceparent
+// header of a request, if it has a valid one.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Inject sets the traceparent header of an outgoing request from the span
+// carried by a context, if there is one.
+func Inject(ctx context.Context, h http.Header) {
+	if sc := SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		h.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Start starts a span which is a child of the span carried by ctx, using
+// the same tracer. If ctx carries no span, the operation is not being
+// traced, and ctx is returned along with a nil span.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind)
+}
+
+// Tracer starts spans and exports those which have ended, in batches.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	onError  func(error)
+
+	mu       sync.RWMutex
+	stopped  bool
+	queue    chan SpanData
+	flushReq chan chan struct{}
+	done     chan struct{}
+}
+
+// Exporter sends spans which have ended to a collector, or writes them out.
+type Exporter interface {
+	Export(ctx context.Context, service string, spans []SpanData) error
+}
+
+// batchSize is the most spans exported at once; spans are also exported
+// every batchInterval, and any which do not fit in the queue are dropped.
+const (
+	batchSize     = 512
+	batchInterval = 5 * time.Second
+	queueSize     = 2048
+)
+
+// NewTracer returns a tracer which exports the spans of service to e.
+// Errors from exporting are passed to onError, if it is not nil.
+func NewTracer(service string, e Exporter, onError func(error)) *Tracer {
+	t := &Tracer{
+		service:  service,
+		exporter: e,
+		onError:  onError,
+		queue:    make(chan SpanData, queueSize),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span. Its parent is the span carried by ctx if there is
+// one, then the remote parent carried by ctx if there is one; otherwise
+// the span starts a new, sampled, trace. A nil Tracer starts nil spans.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.context.TraceID = parent.context.TraceID
+		s.context.Sampled = parent.context.Sampled
+		s.parent = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey).(SpanContext); ok {
+		s.context.TraceID = remote.TraceID
+		s.context.Sampled = remote.Sampled
+		s.parent = remote.SpanID
+	} else {
+		rand.Read(s.context.TraceID[:])
+		s.context.Sampled = true
+	}
+	rand.Read(s.context.SpanID[:])
+	return ContextWithSpan(ctx, s), s
+}
+
+func (t *Tracer) enqueue(s SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.stopped {
+		return
+	}
+	select {
+	case t.queue <- s:
+	default:
+		// the exporter is not keeping up
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(context.Background(), t.service, batch); err != nil && t.onError != nil {
+			t.onError(err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case s, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			if batch = append(batch, s); len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-t.flushReq:
+			// take whatever has been queued so far
+			for n := len(t.queue); n > 0; n-- {
+				batch = append(batch, <-t.queue)
+			}
+			export()
+			close(flushed)
+		}
+	}
+}
+
+// Flush exports the spans which have ended, and waits for the export.
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	flushed := make(chan struct{})
+	select {
+	case t.flushReq <- flushed:
+		<-flushed
+	case <-t.done:
+	}
+}
+
+// Shutdown exports the spans which have ended and stops the tracer, waiting
+// until ctx is done for the export. Spans which end afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}